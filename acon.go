@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"actool/client"
+)
+
+// aconRequest 結構體描述一次開啟空調的請求，亦作為守護進程 POST /ac/on 的請求體
+type aconRequest struct {
+	Minutes int    `json:"minutes,omitempty"` // 開啟後 N 分鐘自動關閉 (--acon N)
+	Until   string `json:"until,omitempty"`   // 立即開啟，於 HH:MM 自動關閉 (--timer HH:MM)
+	In      string `json:"in,omitempty"`      // 延遲開啟，例如 "20m"
+	At      string `json:"at,omitempty"`      // 於 HH:MM 開啟
+	For     string `json:"for,omitempty"`     // 開啟後持續多久自動關閉，例如 "2h"
+
+	Settings []acSetting `json:"settings,omitempty"` // 開啟後依次套用的設定，來自配置中的 TEMP、MODE、WIND
+}
+
+// timed 方法用於判斷請求是否帶有定時參數
+func (r aconRequest) timed() bool {
+	return r.Minutes > 0 || r.Until != "" || r.In != "" || r.At != "" || r.For != ""
+}
+
+// aconPlan 結構體為解析後的開啟計劃
+type aconPlan struct {
+	StartAt        time.Time     // 延遲開啟的時間，零值表示立即開啟
+	StartLabel     string        // 延遲開啟的描述
+	For            time.Duration // 開啟後持續多久自動關閉，0 為不自動關閉
+	OffAt          time.Time     // 立即開啟時自動關閉的時間 (由 Until 指定)
+	OffDescription string        // 自動關閉定時器的描述
+	Settings       []acSetting   // 開啟後依次套用的設定
+}
+
+// parseAconArgs 函數用於解析 /acon 與 --acon 的參數
+// 支持 "<分鐘>"、"--in <時長>"、"--at <HH:MM>"、"--for <時長>"，時長可為 "20m"、"2h" 或分鐘數
+func parseAconArgs(args []string) (aconRequest, error) {
+	var req aconRequest
+	for i := 0; i < len(args); i++ {
+		arg := strings.ToLower(args[i])
+		switch arg {
+		case "--in", "--at", "--for":
+			if i+1 >= len(args) {
+				return req, fmt.Errorf("%s 需要參數", arg)
+			}
+			i++
+			switch arg {
+			case "--in":
+				req.In = args[i]
+			case "--at":
+				req.At = args[i]
+			case "--for":
+				req.For = args[i]
+			}
+		default:
+			minutes, err := strconv.Atoi(arg)
+			if err != nil || minutes <= 0 || req.Minutes != 0 {
+				return req, fmt.Errorf("定時分鐘數無效：%q，請輸入正整數", args[i])
+			}
+			req.Minutes = minutes
+		}
+	}
+	return req, nil
+}
+
+// parseFlexibleDuration 函數用於解析時長，純數字視為分鐘
+func parseFlexibleDuration(s string) (time.Duration, error) {
+	if minutes, err := strconv.Atoi(s); err == nil {
+		if minutes <= 0 {
+			return 0, fmt.Errorf("時長必須為正數：%q", s)
+		}
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("時長格式無效：%q，請使用 20m、2h 或 1h30m 等格式", s)
+	}
+	return d, nil
+}
+
+// plan 方法用於校驗請求並計算開啟計劃
+func (r aconRequest) plan(now time.Time) (aconPlan, error) {
+	p := aconPlan{Settings: r.Settings}
+	for _, s := range r.Settings {
+		if err := s.validate(); err != nil {
+			return p, err
+		}
+	}
+	if r.Minutes < 0 {
+		return p, fmt.Errorf("定時分鐘數無效，請輸入正整數")
+	}
+	if r.In != "" && r.At != "" {
+		return p, fmt.Errorf("--in 與 --at 不能同時使用")
+	}
+	if r.Minutes > 0 && r.For != "" {
+		return p, fmt.Errorf("分鐘數與 --for 不能同時使用")
+	}
+	if r.Until != "" && (r.In != "" || r.At != "" || r.For != "" || r.Minutes > 0) {
+		return p, fmt.Errorf("指定關閉時間時不能同時指定其他定時參數")
+	}
+
+	switch {
+	case r.In != "":
+		delay, err := parseFlexibleDuration(r.In)
+		if err != nil {
+			return p, err
+		}
+		p.StartAt = now.Add(delay)
+		p.StartLabel = "延遲" + formatDuration(delay)
+	case r.At != "":
+		startAt, err := parseClockTime(r.At, now)
+		if err != nil {
+			return p, fmt.Errorf("時間格式無效：%q，請使用 HH:MM 格式", r.At)
+		}
+		p.StartAt = startAt
+		p.StartLabel = "指定時間 " + r.At
+	}
+
+	switch {
+	case r.Minutes > 0:
+		p.For = time.Duration(r.Minutes) * time.Minute
+		p.OffDescription = fmt.Sprintf("%d分鐘", r.Minutes)
+	case r.For != "":
+		d, err := parseFlexibleDuration(r.For)
+		if err != nil {
+			return p, err
+		}
+		p.For = d
+		p.OffDescription = formatDuration(d)
+	case r.Until != "":
+		offAt, err := parseClockTime(r.Until, now)
+		if err != nil {
+			return p, fmt.Errorf("時間格式無效：%q，請使用 HH:MM 格式", r.Until)
+		}
+		p.OffAt = offAt
+		p.OffDescription = "指定時間 " + r.Until
+	}
+	return p, nil
+}
+
+// Delayed 方法用於判斷是否為延遲開啟
+func (p aconPlan) Delayed() bool {
+	return !p.StartAt.IsZero()
+}
+
+// startAC 函數用於按計劃開啟空調
+// 立即開啟時發送指令並按需設定自動關閉，延遲開啟時僅排程一個開啟動作
+// 返回的 result 在延遲開啟時為 nil，action 為新排程的定時動作 (若有)
+func startAC(ctx context.Context, c *client.Client, deviceNo string, timers *scheduler, p aconPlan) (*client.OperateResult, *scheduledAction, error) {
+	if p.Delayed() {
+		a := scheduleStart(timers, p)
+		return nil, &a, nil
+	}
+
+	result, err := c.Switch(ctx, deviceNo, client.CommandAirOpen)
+	if err != nil {
+		return result, nil, err
+	}
+	applySettings(ctx, c, deviceNo, p.Settings)
+	offAt := p.OffAt
+	if p.For > 0 {
+		offAt = time.Now().Add(p.For)
+	}
+	if offAt.IsZero() {
+		return result, nil, nil
+	}
+	a := timers.SetAutoOff(offAt, p.OffDescription)
+	return result, &a, nil
+}
+
+// scheduleStart 函數用於按延遲開啟的計劃排程一個開啟動作
+func scheduleStart(timers *scheduler, p aconPlan) scheduledAction {
+	description := p.StartLabel
+	if p.For > 0 {
+		description += "，持續" + formatDuration(p.For)
+	}
+	return timers.Schedule(scheduledAction{Command: client.CommandAirOpen, At: p.StartAt, Duration: p.For, Description: description, Settings: p.Settings})
+}
+
+// printScheduledAction 函數用於輸出新排程的定時動作
+func printScheduledAction(a scheduledAction) {
+	if structuredOutput() {
+		emit(a)
+		return
+	}
+	fmt.Fprintf(msgOut, "已設定定時器 #%d：將在 %s %s (%s)。\n",
+		a.ID, a.At.Local().Format("2006-01-02 15:04:05"), commandLabel(a.Command), a.Description)
+}
+
+// printActions 函數用於輸出所有待執行的定時動作及剩餘時間
+func printActions(actions []scheduledAction) {
+	if len(actions) == 0 {
+		fmt.Fprintln(msgOut, "定時器狀態：未啟用。")
+		return
+	}
+	for _, a := range actions {
+		remaining := time.Until(a.At)
+		if remaining <= 0 {
+			fmt.Fprintf(msgOut, "定時器 #%d：已過期，等待%s。\n", a.ID, commandLabel(a.Command))
+			continue
+		}
+		hours := int(remaining.Hours())
+		minutes := int(remaining.Minutes()) % 60
+		seconds := int(remaining.Seconds()) % 60
+		fmt.Fprintf(msgOut, "定時器 #%d：啟用中，將於 %02d時%02d分%02d秒 後%s (%s，在 %s)。\n",
+			a.ID, hours, minutes, seconds, commandLabel(a.Command), a.Description, a.At.Local().Format("01-02 15:04:05"))
+	}
+}
+
+// runAcon 函數用於執行開啟請求並輸出結果，返回新排程的定時動作 (若有)
+// 參數無效時返回 errUsage；指令已發送但未能確認時，仍返回定時動作及 errUnconfirmed
+func runAcon(ctx context.Context, c *client.Client, deviceNo string, timers *scheduler, req aconRequest) (*scheduledAction, error) {
+	p, err := req.plan(time.Now())
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if p.Delayed() {
+		fmt.Fprintln(msgOut, "\n正在設定延遲開啟...")
+	} else {
+		fmt.Fprintln(msgOut, "\n正在開啟空調...")
+	}
+	result, action, err := startAC(ctx, c, deviceNo, timers, p)
+	if err != nil {
+		fmt.Fprintf(msgOut, "空調操作失敗: %v\n", err)
+		if result != nil {
+			fmt.Fprintf(msgOut, "回應狀態碼：%d\n", result.StatusCode)
+		}
+		return nil, err
+	}
+	if result != nil {
+		printOperateResult(result)
+	}
+	if action != nil {
+		printScheduledAction(*action)
+	}
+	return action, verifyResult(result)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"actool/client"
+)
+
+// acSetting 結構體描述一次溫度、模式或風速的調整，亦作為守護進程 POST /ac/settings 的請求體
+type acSetting struct {
+	Kind  string `json:"kind"`  // temp、mode 或 wind
+	Value string `json:"value"` // 例如 "26"、"cool"、"low"
+}
+
+// parseSettingKind 函數用於判斷命令是否為設定調整，並返回對應的 Kind
+func parseSettingKind(command string) (string, bool) {
+	switch kind := strings.TrimLeft(strings.ToLower(command), "/-"); kind {
+	case "temp", "mode", "wind":
+		return kind, true
+	}
+	return "", false
+}
+
+// validate 方法用於在發送請求前校驗設定的格式，溫度範圍須待獲取設備信息後校驗
+func (s acSetting) validate() error {
+	switch s.Kind {
+	case "temp":
+		if temp, err := strconv.ParseFloat(s.Value, 64); err != nil || math.IsNaN(temp) || math.IsInf(temp, 0) {
+			return fmt.Errorf("%w：溫度 %q 不是有效的數字", client.ErrInvalidSetting, s.Value)
+		}
+		return nil
+	case "mode":
+		_, err := client.ParseMode(s.Value)
+		return err
+	case "wind":
+		_, err := client.ParseWind(s.Value)
+		return err
+	}
+	return fmt.Errorf("%w：未知的設定項 %q", client.ErrInvalidSetting, s.Kind)
+}
+
+// apply 方法用於重新獲取最新設備狀態後發送設定指令
+func (s acSetting) apply(ctx context.Context, c *client.Client, deviceNo string) (*client.OperateResult, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	deviceInfo, statusCode, err := c.GetDevice(ctx, deviceNo)
+	if err != nil {
+		return &client.OperateResult{StatusCode: statusCode}, fmt.Errorf("獲取設備信息失敗: %w", err)
+	}
+
+	switch s.Kind {
+	case "temp":
+		temp, _ := strconv.ParseFloat(s.Value, 64)
+		return c.SetTemp(ctx, deviceInfo, temp)
+	case "mode":
+		mode, _ := client.ParseMode(s.Value)
+		return c.SetMode(ctx, deviceInfo, mode)
+	default:
+		wind, _ := client.ParseWind(s.Value)
+		return c.SetWind(ctx, deviceInfo, wind)
+	}
+}
+
+// String 方法用於返回設定的中文描述，例如 "溫度 26°C"
+func (s acSetting) String() string {
+	switch s.Kind {
+	case "temp":
+		return fmt.Sprintf("溫度 %s°C", s.Value)
+	case "mode":
+		mode, _ := client.ParseMode(s.Value)
+		return "模式 " + client.ModeLabel(mode)
+	case "wind":
+		wind, _ := client.ParseWind(s.Value)
+		return "風速 " + client.WindLabel(wind)
+	}
+	return s.Kind + " " + s.Value
+}
+
+// printSettingUsage 函數用於輸出設定命令的用法
+func printSettingUsage(kind, prefix string) {
+	switch kind {
+	case "temp":
+		fmt.Fprintf(msgOut, "用法：%stemp <溫度>，例如 %stemp 26\n", prefix, prefix)
+	case "mode":
+		fmt.Fprintf(msgOut, "用法：%smode cool|heat|fan|dry\n", prefix)
+	case "wind":
+		fmt.Fprintf(msgOut, "用法：%swind low|mid|high|auto\n", prefix)
+	}
+}
+
+// adjustAC 函數用於調整空調的溫度、模式或風速，並輸出回應
+func adjustAC(ctx context.Context, c *client.Client, deviceNo string, s acSetting) error {
+	if err := s.validate(); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(msgOut, "\n正在將空調設定為%s...\n", s)
+	result, err := s.apply(ctx, c, deviceNo)
+	if err != nil {
+		fmt.Fprintf(msgOut, "空調設定失敗: %v\n", err)
+		if result != nil {
+			fmt.Fprintf(msgOut, "回應狀態碼：%d\n", result.StatusCode)
+		}
+		return err
+	}
+	printOperateResult(result)
+	return nil
+}
+
+// printFanInfo 函數用於輸出空調的當前運行狀態
+func printFanInfo(fan *client.DeviceFan) {
+	if fan == nil {
+		return
+	}
+	status := "關閉"
+	if fan.FanStatus == 1 {
+		status = "開啟"
+	}
+	minTemp, maxTemp := client.TempRange(fan)
+	fmt.Fprintf(msgOut, "空調狀態：%s\n", status)
+	fmt.Fprintf(msgOut, "設定溫度：%g°C (可調範圍 %g-%g°C，室溫 %g°C)\n", fan.TempSetting, minTemp, maxTemp, fan.CurrentTemp)
+	fmt.Fprintf(msgOut, "運行模式：%s\n", client.ModeLabel(fan.FanModel))
+	fmt.Fprintf(msgOut, "風   速：%s\n", client.WindLabel(fan.WindSpeed))
+}
+
+// applySettings 函數用於在開啟空調後依次套用默認設定，失敗時僅輸出錯誤
+func applySettings(ctx context.Context, c *client.Client, deviceNo string, settings []acSetting) {
+	for _, s := range settings {
+		if _, err := s.apply(ctx, c, deviceNo); err != nil {
+			fmt.Fprintf(msgOut, "套用默認設定 (%s) 失敗: %v\n", s, err)
+			continue
+		}
+		fmt.Fprintf(msgOut, "已套用默認設定：%s。\n", s)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"actool/client"
+)
+
+// TestSettingValidate 測試設定的格式校驗，溫度須為有限的數字
+func TestSettingValidate(t *testing.T) {
+	tests := []struct {
+		setting acSetting
+		ok      bool
+	}{
+		{acSetting{Kind: "temp", Value: "26"}, true},
+		{acSetting{Kind: "temp", Value: "26.5"}, true},
+		{acSetting{Kind: "temp", Value: "abc"}, false},
+		{acSetting{Kind: "temp", Value: "NaN"}, false},
+		{acSetting{Kind: "temp", Value: "nan"}, false},
+		{acSetting{Kind: "temp", Value: "Inf"}, false},
+		{acSetting{Kind: "temp", Value: "+Inf"}, false},
+		{acSetting{Kind: "temp", Value: "-Infinity"}, false},
+		{acSetting{Kind: "mode", Value: "cool"}, true},
+		{acSetting{Kind: "mode", Value: "turbo"}, false},
+		{acSetting{Kind: "wind", Value: "low"}, true},
+		{acSetting{Kind: "swing", Value: "on"}, false},
+	}
+	for _, tt := range tests {
+		err := tt.setting.validate()
+		if tt.ok != (err == nil) || (err != nil && !errors.Is(err, client.ErrInvalidSetting)) {
+			t.Errorf("%+v.validate() = %v", tt.setting, err)
+		}
+	}
+}
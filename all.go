@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"actool/client"
+)
+
+// deviceOutcome 結構體為 --all 時單個設備的執行結果
+type deviceOutcome struct {
+	Device    string                `json:"device"`
+	DeviceNo  string                `json:"deviceNo"`
+	Status    *daemonStatus         `json:"status,omitempty"`
+	Result    *client.OperateResult `json:"result,omitempty"`
+	Scheduled *scheduledAction      `json:"scheduled,omitempty"` // 開啟時按 ACON_DURATION 設定的自動關閉
+	Error     string                `json:"error,omitempty"`
+	Kind      string                `json:"kind,omitempty"`
+
+	err error
+}
+
+// deviceTarget 結構體為 --all 時操作的一個設備，守護進程正在運行時經由守護進程操作
+type deviceTarget struct {
+	profile deviceProfile
+	daemon  *daemonClient  // 該設備的守護進程，未運行時為 nil
+	session *deviceSession // 未經守護進程時使用的本地會話
+}
+
+// runAllDevices 函數用於對所有已配置的設備並發執行同一命令，並按配置順序輸出結果
+// 返回第一個失敗的設備的錯誤
+func runAllDevices(ctx context.Context, devices *deviceSet, socketPath string, args []string) error {
+	commandArg := strings.TrimPrefix(strings.ToLower(args[0]), "--")
+
+	var op func(ctx context.Context, t deviceTarget, out *deviceOutcome)
+	switch commandArg {
+	case "status":
+		op = statusOnTarget
+	case "acon", "acoff":
+		if len(args) > 1 {
+			fmt.Fprintln(msgOut, "錯誤: --all 不支持定時參數，請分別為每個設備設定定時器。")
+			return errUsage
+		}
+		command := client.CommandAirOpen
+		if commandArg == "acoff" {
+			command = client.CommandAirClose
+		}
+		op = func(ctx context.Context, t deviceTarget, out *deviceOutcome) {
+			switchOnTarget(ctx, t, command, out)
+		}
+	case "temp", "mode", "wind":
+		if len(args) < 2 {
+			printSettingUsage(commandArg, "--")
+			return errUsage
+		}
+		s := acSetting{Kind: commandArg, Value: args[1]}
+		if err := s.validate(); err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return err
+		}
+		op = func(ctx context.Context, t deviceTarget, out *deviceOutcome) {
+			adjustOnTarget(ctx, t, s, out)
+		}
+	default:
+		fmt.Fprintf(msgOut, "錯誤: --all 不支持 %s，僅支持 --status、--acon、--acoff、--temp、--mode 與 --wind。\n", args[0])
+		return errUsage
+	}
+
+	// 先依次準備各設備，恢復定時器時的輸出不會交錯
+	targets := make([]deviceTarget, 0, len(devices.profiles))
+	for _, p := range devices.profiles {
+		t := deviceTarget{profile: p}
+		if socketPath != "" {
+			t.daemon = dialDaemon(profileSocketPath(socketPath, p.Name))
+		}
+		if t.daemon == nil {
+			t.session = devices.open(ctx, p)
+		}
+		targets = append(targets, t)
+	}
+
+	fmt.Fprintf(msgOut, "\n正在對 %d 台設備執行 %s...\n", len(targets), args[0])
+	outcomes := make([]deviceOutcome, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		outcomes[i] = deviceOutcome{Device: t.profile.Name, DeviceNo: t.profile.DeviceNo}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op(ctx, t, &outcomes[i])
+			if outcomes[i].err == nil {
+				outcomes[i].err = verifyResult(outcomes[i].Result)
+			}
+			if err := outcomes[i].err; err != nil {
+				outcomes[i].Error = err.Error()
+				outcomes[i].Kind = errorKind(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var firstErr error
+	for _, out := range outcomes {
+		if out.err != nil {
+			firstErr = out.err
+			break
+		}
+	}
+	if structuredOutput() {
+		emit(outcomes)
+	} else {
+		for _, out := range outcomes {
+			printDeviceOutcome(out)
+		}
+	}
+	if err := waitForAutoOff(ctx, targets, outcomes); err != nil {
+		return err
+	}
+	return firstErr
+}
+
+// waitForAutoOff 函數用於等待本地會話中剛設定的自動關閉執行完畢
+// 未經守護進程時定時器在本進程中執行，立即退出會使空調一直開啟
+func waitForAutoOff(ctx context.Context, targets []deviceTarget, outcomes []deviceOutcome) error {
+	for i, t := range targets {
+		a := outcomes[i].Scheduled
+		if t.session == nil || a == nil {
+			continue
+		}
+		fmt.Fprintf(msgOut, "\n設備 %s 將在 %s 自動關閉，守護進程未運行，程式將保持運行直到定時器到期...\n", t.profile.Name, a.At.Format("15:04"))
+		if err := t.session.timers.WaitAction(ctx, a.ID); err != nil {
+			fmt.Fprintf(msgOut, "\n已停止等待，設備 %s 的空調將保持開啟。\n", t.profile.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// statusOnTarget 函數用於獲取設備信息及定時器狀態
+func statusOnTarget(ctx context.Context, t deviceTarget, out *deviceOutcome) {
+	if t.daemon != nil {
+		out.Status, out.err = t.daemon.Status(ctx)
+		return
+	}
+	device, statusCode, err := t.session.client.GetDevice(ctx, t.profile.DeviceNo)
+	out.Status = &daemonStatus{StatusCode: statusCode, Device: device, Actions: t.session.timers.Actions()}
+	out.err = err
+}
+
+// switchOnTarget 函數用於開關空調，開啟時套用設備的默認持續時間及設定，關閉時同時取消自動關閉的定時器
+func switchOnTarget(ctx context.Context, t deviceTarget, command string, out *deviceOutcome) {
+	if t.daemon != nil {
+		var resp *daemonOperateResponse
+		if command == client.CommandAirOpen {
+			resp, out.err = t.daemon.On(ctx, aconRequest{}) // 由守護進程補充設備的默認值
+		} else {
+			resp, out.err = t.daemon.Off(ctx)
+		}
+		if resp != nil {
+			out.Result, out.Scheduled = resp.Result, resp.Scheduled
+		}
+		return
+	}
+	if command == client.CommandAirClose {
+		out.Result, out.err = t.session.client.Switch(ctx, t.profile.DeviceNo, command)
+		if out.err == nil {
+			t.session.timers.CancelAutoOff()
+		}
+		return
+	}
+
+	p, err := t.profile.withDefaults(aconRequest{}).plan(time.Now())
+	if err != nil {
+		out.err = err
+		return
+	}
+	out.Result, out.Scheduled, out.err = startAC(ctx, t.session.client, t.profile.DeviceNo, t.session.timers, p)
+}
+
+// adjustOnTarget 函數用於調整空調的溫度、模式或風速
+func adjustOnTarget(ctx context.Context, t deviceTarget, s acSetting, out *deviceOutcome) {
+	if t.daemon != nil {
+		var resp *daemonOperateResponse
+		resp, out.err = t.daemon.Adjust(ctx, s)
+		if resp != nil {
+			out.Result = resp.Result
+		}
+		return
+	}
+	out.Result, out.err = s.apply(ctx, t.session.client, t.profile.DeviceNo)
+}
+
+// printDeviceOutcome 函數用於輸出單個設備的執行結果
+func printDeviceOutcome(out deviceOutcome) {
+	fmt.Fprintf(msgOut, "\n########## %s (%s) ##########\n", out.Device, out.DeviceNo)
+	switch {
+	case out.err != nil && !errors.Is(out.err, errUnconfirmed):
+		printDaemonError(out.err)
+		if out.Result != nil && out.Result.StatusCode != 0 {
+			fmt.Fprintf(msgOut, "回應狀態碼：%d\n", out.Result.StatusCode)
+		} else if out.Status != nil && out.Status.StatusCode != 0 {
+			fmt.Fprintf(msgOut, "回應狀態碼：%d\n", out.Status.StatusCode)
+		}
+	case out.Status != nil:
+		printDeviceInfo(out.Status.Device, out.Status.StatusCode, out.Status.Actions)
+	case out.Result != nil:
+		printOperateResult(out.Result)
+	}
+	if a := out.Scheduled; a != nil {
+		fmt.Fprintf(msgOut, "空調將在 %s 自動關閉 (%s)。\n", a.At.Format("15:04"), a.Description)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// TestSwitchOnTargetDefaults 測試 --all --acon 開啟空調時套用設備的默認持續時間及設定，關閉時取消自動關閉
+func TestSwitchOnTargetDefaults(t *testing.T) {
+	server := mockserver.New("D1")
+	s := newMockSession(t, server)
+	s.profile.AconDuration = "2h"
+	s.profile.Wind = "high"
+	s.client.ExperimentalSettings = true
+	target := deviceTarget{profile: s.profile, session: s}
+
+	var out deviceOutcome
+	switchOnTarget(context.Background(), target, client.CommandAirOpen, &out)
+	if out.err != nil {
+		t.Fatalf("開啟空調失敗: %v", out.err)
+	}
+	actions := s.timers.Actions()
+	if len(actions) != 1 || actions[0].Command != client.CommandAirClose || out.Scheduled == nil || out.Scheduled.ID != actions[0].ID {
+		t.Fatalf("定時動作為 %+v，應有一個自動關閉動作", actions)
+	}
+	if d := time.Until(actions[0].At); d < 119*time.Minute || d > 2*time.Hour {
+		t.Errorf("自動關閉時間在 %s 後，應約為 2 小時", d)
+	}
+	if !hasCommand(server, client.CommandAirWind) {
+		t.Error("未套用默認風速")
+	}
+
+	out = deviceOutcome{}
+	switchOnTarget(context.Background(), target, client.CommandAirClose, &out)
+	if out.err != nil {
+		t.Fatalf("關閉空調失敗: %v", out.err)
+	}
+	if actions := s.timers.Actions(); len(actions) != 0 {
+		t.Errorf("關閉後仍有定時動作 %+v", actions)
+	}
+}
+
+// TestRunAllDevicesAutoOff 測試守護進程未運行時 --all --acon 等待 ACON_DURATION 的自動關閉執行後才返回
+func TestRunAllDevicesAutoOff(t *testing.T) {
+	base := client.New("t", "x")
+	base.Verify.Timeout = 0
+	base.Retry.MaxAttempts = 1
+	devices := &deviceSet{base: base, sessions: make(map[string]*deviceSession)}
+	var servers []*mockserver.Server
+	for _, name := range []string{"a", "b"} {
+		server := mockserver.New("D" + name)
+		ts := httptest.NewServer(server)
+		t.Cleanup(ts.Close)
+		servers = append(servers, server)
+		devices.profiles = append(devices.profiles, deviceProfile{Name: name, DeviceNo: "D" + name, APIBaseURL: ts.URL, AconDuration: "200ms"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := runAllDevices(ctx, devices, "", []string{"--acon"}); err != nil {
+		t.Fatalf("runAllDevices: %v", err)
+	}
+	for _, server := range servers {
+		if !hasCommand(server, client.CommandAirOpen) || !hasCommand(server, client.CommandAirClose) {
+			t.Errorf("設備收到的指令為 %v，應先開啟後自動關閉", server.Commands())
+		}
+		if server.Device().DeviceFan.FanStatus != 0 {
+			t.Error("返回時空調仍開啟")
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"actool/client"
+)
+
+// readPromptLine 用於在需要用戶輸入時讀取一行，ctx 被取消 (Ctrl-C) 時立即返回
+// 互動模式下改為從互動模式讀取的輸入中獲取
+var readPromptLine = func(ctx context.Context) (string, error) {
+	return readLineContext(ctx, os.Stdin)
+}
+
+// promptKey 為 context 中標記請求是否由前台發出的鍵
+type promptKey struct{}
+
+// withPrompt 函數用於標記 ctx 下的請求由前台 (互動模式的輸入循環或單次命令) 發出，Token 過期時可提示用戶輸入
+func withPrompt(ctx context.Context, allowed bool) context.Context {
+	return context.WithValue(ctx, promptKey{}, allowed)
+}
+
+// canPrompt 函數用於判斷 ctx 下的請求是否可提示用戶輸入
+func canPrompt(ctx context.Context) bool {
+	allowed, _ := ctx.Value(promptKey{}).(bool)
+	return allowed
+}
+
+// tokenRenewer 結構體用於在 Token 過期時以微信 OAuth code 換取新的 Token，並更新配置中保存的 Token
+type tokenRenewer struct {
+	mu          sync.Mutex
+	config      *configFile
+	secrets     *secretResolver
+	interactive bool              // 是否可提示用戶輸入 OAuth code，守護進程中為 false
+	tokens      map[string]string // 各配置段最近換取的 Token，鍵為設備名稱，"" 為全局配置
+}
+
+// newTokenRenewer 函數用於創建 Token 更新器
+func newTokenRenewer(config *configFile, secrets *secretResolver, interactive bool) *tokenRenewer {
+	return &tokenRenewer{config: config, secrets: secrets, interactive: interactive, tokens: make(map[string]string)}
+}
+
+// tokenScope 函數用於返回設備 Token 所屬的配置段，設備未單獨設定 Token 時為全局配置 ""
+func tokenScope(p deviceProfile) string {
+	if p.Token == "" || p.Name == defaultProfileName {
+		return ""
+	}
+	return p.Name
+}
+
+// hook 方法用於返回 c 的 Reauth 回調
+// Token 過期時先檢查其他請求或其他進程 (例如 actool auth) 是否已換取了新的 Token，否則提示用戶重新授權
+// 只有前台的請求 (見 withPrompt) 會提示輸入，調度器等後台請求直接返回 ErrTokenExpired，以免搶走互動模式的輸入
+func (r *tokenRenewer) hook(c *client.Client, scope string) func(ctx context.Context, expired string) (string, error) {
+	return func(ctx context.Context, expired string) (string, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if token := r.tokens[scope]; token != "" && token != expired {
+			return token, nil
+		}
+		if token, err := r.stored(scope); err == nil && token != "" && token != expired {
+			r.tokens[scope] = token
+			fmt.Fprintln(msgOut, "Token 已過期，已改用配置中更新後的 Token。")
+			return token, nil
+		}
+		if c.AuthPath == "" {
+			fmt.Fprintln(msgOut, "Token 已過期。未設定 AUTH_PATH，無法以微信 OAuth code 重新授權，請手動更新 Token。")
+			return "", client.ErrTokenExpired
+		}
+		if !r.interactive {
+			fmt.Fprintln(msgOut, "Token 已過期，請執行 actool auth 以微信 OAuth code 重新授權。")
+			return "", client.ErrTokenExpired
+		}
+		if !canPrompt(ctx) {
+			fmt.Fprintln(msgOut, "\nToken 已過期，後台任務無法提示輸入，請輸入 /auth (或執行 actool --auth) 重新授權。")
+			return "", client.ErrTokenExpired
+		}
+		fmt.Fprintln(msgOut, "\nToken 已過期，需要重新授權。")
+		return r.renewLocked(ctx, c, scope, "")
+	}
+}
+
+// renew 方法用於以 input (回調地址或 code) 換取新的 Token 並保存，input 為空時提示用戶輸入
+func (r *tokenRenewer) renew(ctx context.Context, c *client.Client, scope, input string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.renewLocked(ctx, c, scope, input)
+}
+
+// renewLocked 方法用於換取並保存新的 Token，調用前須持有鎖
+func (r *tokenRenewer) renewLocked(ctx context.Context, c *client.Client, scope, input string) (string, error) {
+	if c.AuthPath == "" {
+		fmt.Fprintln(msgOut, "錯誤: 未設定 AUTH_PATH。hatch-api 換取 Token 的接口沒有公開文檔，請從網頁版的請求中確認其路徑後設定。")
+		return "", client.ErrNoAuthPath
+	}
+	if input == "" {
+		fmt.Fprintln(msgOut, "請在微信中重新打開宿舍空調頁面，複製地址欄中帶有 code= 的地址 (或只複製 code) 並粘貼到此處，直接按 Enter 取消：")
+		fmt.Fprint(msgOut, "授權地址> ")
+		line, err := readPromptLine(ctx)
+		if err == io.EOF {
+			fmt.Fprintln(msgOut)
+			return "", errors.New("未輸入授權地址")
+		}
+		if err != nil {
+			return "", err
+		}
+		if input = strings.TrimSpace(line); input == "" {
+			return "", errors.New("已取消重新授權")
+		}
+	}
+	code, state, err := client.ParseOAuthCode(input)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return "", err
+	}
+	token, err := c.ExchangeCode(ctx, code, state)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 換取 Token 失敗: %v\n", err)
+		fmt.Fprintln(msgOut, "OAuth code 只能使用一次且很快失效，請重新打開頁面獲取新的地址。")
+		return "", err
+	}
+	r.tokens[scope] = token
+	if where, err := r.persist(scope, token); err != nil {
+		fmt.Fprintf(msgOut, "已換取新的 Token，本次運行中有效，但未能自動保存: %v\n", err)
+	} else {
+		fmt.Fprintf(msgOut, "已換取新的 Token 並保存：%s。\n", where)
+	}
+	return token, nil
+}
+
+// source 方法用於返回 scope 的 Token 來源配置項及其值，全局配置中環境變數優先
+func (r *tokenRenewer) source(f *configFile, scope string) (key, value string, fromEnv bool) {
+	values := f.Global
+	if s := f.section(scope); s != nil {
+		values = s.Values
+	}
+	for _, k := range tokenKeys {
+		if scope == "" {
+			if v := os.Getenv(k); v != "" {
+				return k, v, true
+			}
+		}
+		if v := values[k]; v != "" {
+			return k, v, false
+		}
+	}
+	return "", "", false
+}
+
+// stored 方法用於重新讀取配置及密鑰庫，返回 scope 當前保存的 Token
+func (r *tokenRenewer) stored(scope string) (string, error) {
+	f := r.config
+	if f.Path != "" {
+		var err error
+		if f, err = loadConfigFile(f.Path); err != nil {
+			return "", err
+		}
+	}
+	key, value, _ := r.source(f, scope)
+	if key == "TOKEN_SECRET" {
+		r.secrets.reload()
+	}
+	return r.secrets.token(func(k string) string {
+		if k == key {
+			return value
+		}
+		return ""
+	})
+}
+
+// persist 方法用於將新的 Token 寫回其來源，返回更新的位置
+// Token 來自環境變數或 TOKEN_COMMAND 時無法自動更新，錯誤訊息只提示改用可更新的來源，不包含 Token 本身
+func (r *tokenRenewer) persist(scope, token string) (string, error) {
+	key, value, fromEnv := r.source(r.config, scope)
+	if fromEnv {
+		return "", fmt.Errorf("Token 來自 %s 環境變數，無法自動更新。請取消該環境變數，在配置中改用 TOKEN_SECRET (見 actool secret set) 或 TOKEN_FILE 後重新執行 actool auth", key)
+	}
+	switch key {
+	case "TOKEN":
+		if err := r.config.replaceValue(scope, "TOKEN", token); err != nil {
+			return "", err
+		}
+		return r.config.Path + " 中的 TOKEN", nil
+	case "TOKEN_FILE":
+		path := r.secrets.tokenFilePath(value)
+		if err := writeFileAtomic(path, []byte(token+"\n")); err != nil {
+			return "", err
+		}
+		return path, nil
+	case "TOKEN_SECRET":
+		if err := r.secrets.storeSecret(value, token); err != nil {
+			return "", err
+		}
+		return "密鑰庫中的 " + value, nil
+	case "TOKEN_COMMAND":
+		return "", errors.New("Token 由 TOKEN_COMMAND 提供，無法自動更新。請改用 TOKEN_SECRET (見 actool secret set) 或 TOKEN_FILE 後重新執行 actool auth")
+	}
+	return "", errors.New("配置中沒有 Token，請設定 TOKEN_SECRET (見 actool secret set) 或 TOKEN_FILE 後重新執行 actool auth")
+}
+
+// runAuth 函數用於處理 auth 命令：以回調地址或 code 為當前設備換取新的 Token，保存後查詢設備以確認其有效
+func runAuth(ctx context.Context, devices *deviceSet, session *deviceSession, args []string) error {
+	if devices.renewer == nil {
+		return errors.New("當前模式不支持重新授權")
+	}
+	c := session.client
+	token, err := devices.renewer.renew(ctx, c, tokenScope(session.profile), strings.Join(args, ""))
+	if err != nil {
+		return err
+	}
+	c.SetToken(token)
+	device, _, err := c.GetDevice(ctx, session.profile.DeviceNo)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 新的 Token 無法獲取設備信息: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(msgOut, "授權成功：%s %s %s\n", device.CampusTitle, device.BuildingTitle, device.RoomNo)
+	return nil
+}
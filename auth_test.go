@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"actool/client"
+)
+
+// TestRenewerPromptsOnlyInForeground 測試 Token 過期時只有前台的請求會提示輸入，後台請求直接返回 ErrTokenExpired
+func TestRenewerPromptsOnlyInForeground(t *testing.T) {
+	t.Setenv("TOKEN", "")
+	t.Setenv("TOKEN_FILE", "")
+	t.Setenv("TOKEN_COMMAND", "")
+	t.Setenv("TOKEN_SECRET", "")
+	renewer := newTokenRenewer(&configFile{Global: map[string]string{"TOKEN": "old"}}, newSecretResolver(""), true)
+	c := client.New("old", "x")
+	c.AuthPath = "/auth"
+	hook := renewer.hook(c, "")
+
+	prompts := 0
+	saved := readPromptLine
+	readPromptLine = func(ctx context.Context) (string, error) {
+		prompts++
+		return "", io.EOF
+	}
+	defer func() { readPromptLine = saved }()
+
+	ctx := context.Background()
+	for _, bg := range []context.Context{ctx, withPrompt(ctx, false), withPrompt(withPrompt(ctx, true), false)} {
+		if _, err := hook(bg, "old"); !errors.Is(err, client.ErrTokenExpired) {
+			t.Errorf("後台請求的錯誤為 %v，應為 ErrTokenExpired", err)
+		}
+	}
+	if prompts != 0 {
+		t.Fatalf("後台請求提示了 %d 次輸入", prompts)
+	}
+
+	if _, err := hook(withPrompt(ctx, true), "old"); err == nil {
+		t.Error("未輸入授權地址時應返回錯誤")
+	}
+	if prompts != 1 {
+		t.Errorf("前台請求提示了 %d 次輸入，應為 1 次", prompts)
+	}
+}
+
+// TestReadPromptLineCancel 測試等待輸入授權地址時 ctx 被取消 (Ctrl-C) 會立即返回
+func TestReadPromptLineCancel(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	saved := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = saved }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := readPromptLine(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("readPromptLine 返回 %v，應在 ctx 結束時返回", err)
+	}
+}
+
+// TestRenewerPersist 測試新的 Token 寫回 TOKEN_FILE，無法自動保存時的錯誤訊息不包含 Token
+func TestRenewerPersist(t *testing.T) {
+	for _, k := range tokenKeys {
+		t.Setenv(k, "")
+	}
+	const token = "new-secret-token-1234"
+	path := filepath.Join(t.TempDir(), "token")
+	renewer := newTokenRenewer(&configFile{Global: map[string]string{"TOKEN_FILE": path}}, newSecretResolver(""), true)
+	if where, err := renewer.persist("", token); err != nil || where != path {
+		t.Fatalf("persist = %q, %v，應寫入 %s", where, err, path)
+	}
+	if data, err := os.ReadFile(path); err != nil || strings.TrimSpace(string(data)) != token {
+		t.Errorf("TOKEN_FILE 的內容為 %q, %v", data, err)
+	}
+
+	tests := []struct {
+		name   string
+		env    string
+		global map[string]string
+	}{
+		{"環境變數", "TOKEN", map[string]string{}},
+		{"TOKEN_COMMAND", "", map[string]string{"TOKEN_COMMAND": "pass show actool"}},
+		{"沒有 Token", "", map[string]string{}},
+	}
+	for _, tt := range tests {
+		if tt.env != "" {
+			t.Setenv(tt.env, "old-token")
+		}
+		renewer := newTokenRenewer(&configFile{Global: tt.global}, newSecretResolver(""), true)
+		_, err := renewer.persist("", token)
+		if err == nil {
+			t.Errorf("%s: persist 應返回錯誤", tt.name)
+			continue
+		}
+		if strings.Contains(err.Error(), token) {
+			t.Errorf("%s: 錯誤訊息包含新的 Token: %v", tt.name, err)
+		}
+		if !strings.Contains(err.Error(), "actool auth") || !strings.Contains(err.Error(), "actool secret set") {
+			t.Errorf("%s: 錯誤訊息 %q 應提示改用 actool secret set 並重新執行 actool auth", tt.name, err)
+		}
+		if tt.env != "" {
+			t.Setenv(tt.env, "")
+		}
+	}
+}
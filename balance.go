@@ -0,0 +1,245 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"actool/client"
+)
+
+// defaultBalanceInterval 為檢查電費餘額的默認間隔
+const defaultBalanceInterval = 10 * time.Minute
+
+// balanceWatchConfig 結構體為電費餘額監控的配置，來自 BALANCE_* 及 NOTIFY_* 配置項
+type balanceWatchConfig struct {
+	Thresholds []float64     // 提醒閾值，由高到低排列
+	Floor      float64       // 大於 0 時，餘額不高於此值則強制關閉空調
+	Interval   time.Duration // 檢查的間隔
+	Notifiers  []notifier
+}
+
+// newBalanceWatchConfig 函數用於讀取電費餘額監控及提醒渠道的配置
+func newBalanceWatchConfig(setting func(string) string) (*balanceWatchConfig, error) {
+	for _, key := range []string{"BALANCE_ALERT", "BALANCE_FLOOR", "BALANCE_INTERVAL", "NOTIFY_WEBHOOK", "NOTIFY_SMTP", "NOTIFY_EMAIL_FROM", "NOTIFY_EMAIL_TO"} {
+		if value := setting(key); value != "" {
+			if err := validateConfigValue(key, value); err != nil {
+				return nil, fmt.Errorf("%s 無效: %w", key, err)
+			}
+		}
+	}
+	cfg := &balanceWatchConfig{Interval: defaultBalanceInterval, Notifiers: newNotifiers(setting)}
+	cfg.Thresholds, _ = parseThresholds(setting("BALANCE_ALERT"))
+	if floor := setting("BALANCE_FLOOR"); floor != "" {
+		cfg.Floor, _ = strconv.ParseFloat(floor, 64)
+	}
+	if interval := setting("BALANCE_INTERVAL"); interval != "" {
+		cfg.Interval, _ = parseFlexibleDuration(interval)
+	}
+	return cfg, nil
+}
+
+// enabled 方法用於判斷是否設定了提醒閾值或下限
+func (cfg *balanceWatchConfig) enabled() bool {
+	return cfg != nil && (len(cfg.Thresholds) > 0 || cfg.Floor > 0)
+}
+
+// parseThresholds 函數用於解析以逗號分隔的提醒閾值，返回由高到低排列的結果
+func parseThresholds(value string) ([]float64, error) {
+	var thresholds []float64
+	for _, item := range splitList(value) {
+		v, err := strconv.ParseFloat(item, 64)
+		if err != nil || !(v > 0) || math.IsInf(v, 0) { // !(v > 0) 同時排除 NaN
+			return nil, fmt.Errorf("%q 不是正數", item)
+		}
+		thresholds = append(thresholds, v)
+	}
+	slices.Sort(thresholds)
+	slices.Reverse(thresholds)
+	return slices.Compact(thresholds), nil
+}
+
+// balanceWatcher 結構體用於定期檢查設備的電費餘額，跌破閾值時發送提醒
+type balanceWatcher struct {
+	cfg     *balanceWatchConfig
+	session *deviceSession
+	alerted float64 // 已提醒過的最低閾值，為 +Inf 表示尚未提醒；充值回升到所有閾值以上後重置
+	atFloor bool    // 是否已就跌破下限發送過提醒
+}
+
+// startBalanceWatcher 函數用於在背景監控 s 對應設備的電費餘額，返回的函數用於停止監控
+// 未設定提醒閾值及下限時不做任何事
+func startBalanceWatcher(ctx context.Context, cfg *balanceWatchConfig, s *deviceSession) (stop func()) {
+	if !cfg.enabled() {
+		return func() {}
+	}
+	w := &balanceWatcher{cfg: cfg, session: s, alerted: math.Inf(1)}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// run 方法用於立即檢查一次，之後每隔 cfg.Interval 檢查，直到 ctx 被取消
+func (w *balanceWatcher) run(ctx context.Context) {
+	fmt.Fprintf(msgOut, "電費餘額監控已啟動，每%s檢查一次%s。\n", formatDuration(w.cfg.Interval), w.describe())
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// describe 方法用於返回閾值與下限的描述
+func (w *balanceWatcher) describe() string {
+	var s string
+	if len(w.cfg.Thresholds) > 0 {
+		s += "，提醒閾值 "
+		for i, t := range w.cfg.Thresholds {
+			if i > 0 {
+				s += "/"
+			}
+			s += formatMoney(t)
+		}
+	}
+	if w.cfg.Floor > 0 {
+		s += "，下限 " + formatMoney(w.cfg.Floor)
+	}
+	return s
+}
+
+// check 方法用於獲取餘額並按需發送提醒，跌破下限時強制關閉空調
+func (w *balanceWatcher) check(ctx context.Context) {
+	s := w.session
+	device, _, err := s.client.GetDevice(ctx, s.profile.DeviceNo)
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(msgOut, "電費餘額監控: 獲取設備信息失敗: %v\n", err)
+		}
+		return
+	}
+	balance := math.Round(device.Balance*100) / 100
+	alert := balanceAlert{DeviceNo: s.profile.DeviceNo, Room: device.BuildingTitle + " " + device.RoomNo, Balance: balance, Time: time.Now()}
+
+	// 只就跌破的最低閾值提醒一次，餘額繼續下降跌破更低的閾值時再提醒
+	if i := slices.IndexFunc(w.cfg.Thresholds, func(t float64) bool { return balance < t }); i >= 0 {
+		lowest := w.cfg.Thresholds[len(w.cfg.Thresholds)-1]
+		for _, t := range w.cfg.Thresholds[i:] {
+			if balance < t {
+				lowest = t
+			}
+		}
+		if lowest < w.alerted {
+			w.alerted = lowest
+			alert.Level, alert.Threshold = alertWarning, lowest
+			alert.Message = fmt.Sprintf("%s 的電費餘額為 %s，已低於 %s，請及時充值。", alert.Room, formatMoney(balance), formatMoney(lowest))
+			sendAlert(ctx, w.cfg.Notifiers, alert)
+		}
+	} else if !math.IsInf(w.alerted, 1) {
+		w.alerted = math.Inf(1)
+		alert.Level, alert.Threshold = alertRecovered, w.cfg.Thresholds[0]
+		alert.Message = fmt.Sprintf("%s 的電費餘額已回升到 %s。", alert.Room, formatMoney(balance))
+		sendAlert(ctx, w.cfg.Notifiers, alert)
+	}
+
+	if w.cfg.Floor <= 0 {
+		return
+	}
+	if balance > w.cfg.Floor {
+		w.atFloor = false
+		return
+	}
+	on := device.DeviceFan != nil && device.DeviceFan.FanStatus == 1
+	if w.atFloor && !on {
+		return
+	}
+	w.atFloor = true
+	alert.Level, alert.Threshold = alertFloor, w.cfg.Floor
+	alert.Message = fmt.Sprintf("%s 的電費餘額為 %s，不高於下限 %s，將拒絕開啟空調。", alert.Room, formatMoney(balance), formatMoney(w.cfg.Floor))
+	if on {
+		if _, err := s.client.Switch(ctx, s.profile.DeviceNo, client.CommandAirClose); err != nil {
+			alert.Message += fmt.Sprintf("\n強制關閉空調失敗: %v", err)
+		} else {
+			s.timers.CancelAutoOff()
+			alert.Message += "\n已強制關閉空調。"
+		}
+	}
+	sendAlert(ctx, w.cfg.Notifiers, alert)
+}
+
+// runBalanceCommand 函數用於處理 actool balance：顯示電費餘額及監控配置，--test 時通過所有渠道發送測試提醒
+func runBalanceCommand(ctx context.Context, devices *deviceSet, profile deviceProfile, cfg *balanceWatchConfig, args []string) int {
+	fs := flag.NewFlagSet("balance", flag.ContinueOnError)
+	test := fs.Bool("test", false, "通過所有已配置的渠道發送一條測試提醒")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	s := devices.open(ctx, profile)
+	device, _, err := s.client.GetDevice(ctx, profile.DeviceNo)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 獲取設備信息失敗: %v\n", err)
+		return exitCode(err)
+	}
+	w := &balanceWatcher{cfg: cfg, session: s}
+	fmt.Fprintf(msgOut, "電費餘額：%s\n", formatMoney(device.Balance))
+	if cfg.enabled() {
+		fmt.Fprintf(msgOut, "監控配置：每%s檢查一次%s (須運行 actool daemon 或 serve)\n", formatDuration(cfg.Interval), w.describe())
+	} else {
+		fmt.Fprintln(msgOut, "監控配置：未設定 BALANCE_ALERT 或 BALANCE_FLOOR。")
+	}
+	names := make([]string, 0, len(cfg.Notifiers))
+	for _, n := range cfg.Notifiers {
+		names = append(names, n.Name())
+	}
+	fmt.Fprintf(msgOut, "提醒渠道：%s\n", cmp.Or(strings.Join(names, "、"), "無 (僅輸出到終端或日誌)"))
+
+	if !*test {
+		if cfg.Floor > 0 && device.Balance <= cfg.Floor {
+			return exitCode(client.ErrLowBalance)
+		}
+		return exitOK
+	}
+	if len(cfg.Notifiers) == 0 {
+		fmt.Fprintln(msgOut, "錯誤: 未配置 NOTIFY_WEBHOOK、NOTIFY_COMMAND 或 NOTIFY_EMAIL_TO。")
+		return exitConfig
+	}
+	alert := balanceAlert{
+		Level:    alertTest,
+		DeviceNo: profile.DeviceNo,
+		Room:     device.BuildingTitle + " " + device.RoomNo,
+		Balance:  math.Round(device.Balance*100) / 100,
+		Time:     time.Now(),
+	}
+	alert.Message = fmt.Sprintf("這是一條測試提醒：%s 的電費餘額為 %s。", alert.Room, formatMoney(device.Balance))
+	failed := 0
+	for _, n := range cfg.Notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			fmt.Fprintf(msgOut, "%s：失敗: %v\n", n.Name(), err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(msgOut, "%s：已發送。\n", n.Name())
+	}
+	if failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
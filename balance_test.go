@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// TestParseThresholds 測試提醒閾值的解析、排序及去重
+func TestParseThresholds(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []float64
+		err   bool
+	}{
+		{"", nil, false},
+		{"10", []float64{10}, false},
+		{"5,20,10", []float64{20, 10, 5}, false},
+		{" 10 , 5, 10 ", []float64{10, 5}, false},
+		{"2.5,0.5", []float64{2.5, 0.5}, false},
+		{"abc", nil, true},
+		{"10,0", nil, true},
+		{"-5", nil, true},
+		{"inf", nil, true},
+		{"NaN", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseThresholds(tt.value)
+		if (err != nil) != tt.err || !slices.Equal(got, tt.want) {
+			t.Errorf("parseThresholds(%q) = %v, %v，應為 %v (錯誤: %v)", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// recordingNotifier 結構體為記錄收到的提醒的測試渠道
+type recordingNotifier struct {
+	alerts []balanceAlert
+}
+
+// Name 方法用於返回渠道名稱
+func (n *recordingNotifier) Name() string {
+	return "test"
+}
+
+// Notify 方法用於記錄提醒
+func (n *recordingNotifier) Notify(ctx context.Context, a balanceAlert) error {
+	n.alerts = append(n.alerts, a)
+	return nil
+}
+
+// newTestWatcher 函數用於創建連接到模擬服務的餘額監控，提醒記錄到返回的渠道中
+func newTestWatcher(t *testing.T, thresholds []float64, floor float64) (*balanceWatcher, *mockserver.Server, *recordingNotifier) {
+	t.Helper()
+	server := mockserver.New("D1")
+	server.RatePerHour = 0
+	n := &recordingNotifier{}
+	cfg := &balanceWatchConfig{Thresholds: thresholds, Floor: floor, Interval: time.Minute, Notifiers: []notifier{n}}
+	return &balanceWatcher{cfg: cfg, session: newMockSession(t, server), alerted: math.Inf(1)}, server, n
+}
+
+// TestBalanceWatcherThresholds 測試每個閾值只提醒一次，充值回升後重新提醒
+func TestBalanceWatcherThresholds(t *testing.T) {
+	w, server, n := newTestWatcher(t, []float64{20, 10}, 0)
+	steps := []struct {
+		balance   float64
+		level     string // 為空表示不應發送提醒
+		threshold float64
+	}{
+		{30, "", 0},
+		{15, alertWarning, 20},
+		{14, "", 0}, // 同一閾值不重複提醒
+		{8, alertWarning, 10},
+		{9, "", 0}, // 仍低於 10
+		{19.99, "", 0},
+		{25, alertRecovered, 20},
+		{30, "", 0},
+		{5, alertWarning, 10}, // 重置後直接跌破最低閾值，只提醒一次
+		{15, "", 0},
+		{20, alertRecovered, 20},
+	}
+	for i, step := range steps {
+		server.SetBalance(step.balance)
+		before := len(n.alerts)
+		w.check(context.Background())
+		got := n.alerts[before:]
+		switch {
+		case step.level == "" && len(got) > 0:
+			t.Errorf("第 %d 步 (餘額 %v) 不應提醒，收到 %+v", i, step.balance, got)
+		case step.level != "" && (len(got) != 1 || got[0].Level != step.level || got[0].Threshold != step.threshold):
+			t.Errorf("第 %d 步 (餘額 %v) 收到 %+v，應為 %s (閾值 %v)", i, step.balance, got, step.level, step.threshold)
+		}
+	}
+}
+
+// TestBalanceWatcherFloor 測試跌破下限時強制關閉空調並取消自動關閉，空調保持關閉時不重複提醒
+func TestBalanceWatcherFloor(t *testing.T) {
+	w, server, n := newTestWatcher(t, nil, 5)
+	s := w.session
+	ctx := context.Background()
+	turnOn := func() {
+		t.Helper()
+		if _, err := s.client.Switch(ctx, "D1", client.CommandAirOpen); err != nil {
+			t.Fatal(err)
+		}
+		s.timers.SetAutoOff(time.Now().Add(time.Hour), "1小時")
+	}
+
+	server.SetBalance(10)
+	turnOn()
+	w.check(ctx)
+	if len(n.alerts) != 0 {
+		t.Fatalf("餘額高於下限時收到 %+v", n.alerts)
+	}
+
+	server.SetBalance(4)
+	w.check(ctx)
+	if len(n.alerts) != 1 || n.alerts[0].Level != alertFloor {
+		t.Fatalf("跌破下限時收到 %+v", n.alerts)
+	}
+	if server.Device().DeviceFan.FanStatus != 0 {
+		t.Error("跌破下限時未強制關閉空調")
+	}
+	if len(s.timers.Actions()) != 0 {
+		t.Error("強制關閉後未取消自動關閉")
+	}
+
+	w.check(ctx)
+	if len(n.alerts) != 1 {
+		t.Errorf("空調保持關閉時重複提醒 %+v", n.alerts[1:])
+	}
+
+	// 低於下限時再次開啟空調，會再次被關閉並提醒
+	turnOn()
+	w.check(ctx)
+	if len(n.alerts) != 2 || server.Device().DeviceFan.FanStatus != 0 {
+		t.Errorf("再次開啟後收到 %d 條提醒，空調狀態 %d", len(n.alerts), server.Device().DeviceFan.FanStatus)
+	}
+
+	// 充值後重置，再次跌破下限時即使空調已關閉也提醒一次
+	server.SetBalance(6)
+	w.check(ctx)
+	server.SetBalance(5)
+	w.check(ctx)
+	if len(n.alerts) != 3 || n.alerts[2].Level != alertFloor || n.alerts[2].Threshold != 5 {
+		t.Errorf("充值後再次跌破下限時收到 %+v", n.alerts[2:])
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthAPIResponse 結構體用於解析換取 Token 接口的響應
+type AuthAPIResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Token string `json:"token"`
+	} `json:"data"`
+}
+
+// ParseOAuthCode 函數用於從微信授權後的回調地址中取出 code 與 state
+// 可接受完整的回調地址、"code=...&state=..." 形式的查詢字串或單獨的 code，未提供 state 時默認為 "wx"
+func ParseOAuthCode(input string) (code, state string, err error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", "", errors.New("未提供 OAuth code")
+	}
+	query := input
+	if u, err := url.Parse(input); err == nil && u.RawQuery != "" {
+		query = u.RawQuery
+	}
+	if !strings.Contains(query, "code=") {
+		if strings.ContainsAny(input, "/?&= ") {
+			return "", "", fmt.Errorf("無法從 %q 中找到 code 參數", input)
+		}
+		return input, "wx", nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", fmt.Errorf("解析回調地址失敗: %w", err)
+	}
+	code = values.Get("code")
+	if code == "" {
+		return "", "", fmt.Errorf("回調地址中的 code 為空")
+	}
+	state = values.Get("state")
+	if state == "" {
+		state = "wx"
+	}
+	return code, state, nil
+}
+
+// ExchangeCode 方法用於以微信 OAuth code 換取新的 Token，code 只能使用一次
+// 成功後 GET 請求的 Referer 會更新為對應的回調地址，與網頁版的請求一致；Token 不會被修改，請以 SetToken 更換
+// 網頁版在微信授權後帶著 code=...&state=wx 回調並換取 Token，但 hatch-api 沒有公開文檔，
+// 接口路徑須從網頁版的請求中確認後設定到 c.AuthPath，未設定時返回 ErrNoAuthPath
+func (c *Client) ExchangeCode(ctx context.Context, code, state string) (string, error) {
+	if c.AuthPath == "" {
+		return "", ErrNoAuthPath
+	}
+	query := url.Values{"code": {code}, "state": {state}}
+	endpoint := c.endpoint(c.AuthPath) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("創建 GET 請求失敗: %w", err)
+	}
+	var referer string
+	c.setHeaders(req, func(h HeaderProfile) string {
+		referer = h.OperateReferer + "?" + query.Encode()
+		return referer
+	})
+	req.Header.Del("Token")
+
+	statusCode, body, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+	var authResponse AuthAPIResponse
+	if err := json.Unmarshal(body, &authResponse); err != nil {
+		return "", fmt.Errorf("解析換取 Token 的 JSON 失敗: %w, 原始響應體:\n%s", err, string(body))
+	}
+	if authResponse.Code != 0 {
+		return "", &APIError{Op: "換取 Token", Code: authResponse.Code, Msg: authResponse.Msg, StatusCode: statusCode}
+	}
+	if authResponse.Data.Token == "" {
+		return "", errors.New("換取 Token 的響應中沒有 token")
+	}
+	c.setReferer(referer)
+	return authResponse.Data.Token, nil
+}
+
+// reauth 方法用於在 err 表示 Token 已過期時調用 c.Reauth 獲取新的 Token
+// 返回 true 表示 Token 已更新，應以新 Token 重試
+func (c *Client) reauth(ctx context.Context, err error) bool {
+	if c.Reauth == nil || !errors.Is(err, ErrTokenExpired) || ctx.Err() != nil {
+		return false
+	}
+	expired := c.CurrentToken()
+	token, reauthErr := c.Reauth(ctx, expired)
+	if reauthErr != nil || token == "" || token == expired {
+		return false
+	}
+	c.SetToken(token)
+	return true
+}
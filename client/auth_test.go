@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// TestReauthConcurrent 測試多個 goroutine 共用客戶端時 Token 過期後的重新授權，須以 go test -race 運行
+func TestReauthConcurrent(t *testing.T) {
+	server := mockserver.New("D1")
+	server.Token = "old"
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := client.New("old", "x")
+	c.BaseURL = ts.URL
+	c.AuthPath = mockserver.AuthPath
+	ctx := context.Background()
+
+	// 以另一個客戶端換取 Token，使 c 的 Token 失效
+	other := client.New("", "x")
+	other.BaseURL = ts.URL
+	other.AuthPath = mockserver.AuthPath
+	if _, err := other.ExchangeCode(ctx, "code-0", "wx"); err != nil {
+		t.Fatalf("ExchangeCode: %v", err)
+	}
+
+	var mu sync.Mutex
+	current, exchanges := "", 0
+	c.Reauth = func(ctx context.Context, expired string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if current != "" && current != expired {
+			return current, nil
+		}
+		exchanges++
+		token, err := c.ExchangeCode(ctx, fmt.Sprintf("code-%d", exchanges), "wx")
+		if err != nil {
+			return "", err
+		}
+		current = token
+		return token, nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := c.GetDevice(ctx, "D1"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("GetDevice: %v", err)
+	}
+	if exchanges != 1 {
+		t.Errorf("換取了 %d 次 Token，應為 1 次", exchanges)
+	}
+	if got := c.CurrentToken(); got != current {
+		t.Errorf("CurrentToken() = %q，應為 %q", got, current)
+	}
+	if c.Clone().CurrentToken() != current {
+		t.Error("Clone 未複製當前的 Token")
+	}
+}
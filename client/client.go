@@ -0,0 +1,345 @@
+// Package client 封裝 hatch-api 的設備查詢與空調操作接口，
+// 供 actool 命令行及其他 Go 服務共用。
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL 為山東工商學院 hatch-api 的默認地址
+const DefaultBaseURL = "https://es.sdtbu.edu.cn/hatch-api/api/sdgongshang"
+
+// 空調操作的 commandKey
+const (
+	CommandAirOpen  = "AirOpen"  // 開啟空調
+	CommandAirClose = "AirClose" // 關閉空調
+)
+
+// HeaderProfile 結構體用於描述請求時模擬的瀏覽器請求頭
+type HeaderProfile struct {
+	UserAgent      string
+	Origin         string
+	Referer        string // GET 請求使用的 Referer
+	OperateReferer string // POST 請求使用的 Referer
+	AcceptLanguage string
+}
+
+// DefaultHeaderProfile 返回模擬微信內置瀏覽器的默認請求頭
+func DefaultHeaderProfile() HeaderProfile {
+	return HeaderProfile{
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 NetType/WIFI MicroMessenger/7.0.20.1781(0x6700143B) WindowsWechat(0x63090c33) XWEB/13639 Flue",
+		Origin:         "https://es.sdtbu.edu.cn",
+		Referer:        "https://es.sdtbu.edu.cn/?code=081yCw2w33V3553Rxc4w3olDyB0yCw2G&state=wx",
+		OperateReferer: "https://es.sdtbu.edu.cn/",
+		AcceptLanguage: "zh-CN,zh;q=0.9",
+	}
+}
+
+// DefaultRequestTimeout 為單次 HTTP 請求的默認超時時間
+const DefaultRequestTimeout = 10 * time.Second
+
+// Client 結構體為 hatch-api 的客戶端，可在多個 goroutine 中共用
+// 所有方法均接受 ctx，取消 ctx 會中止正在進行的請求、重試及狀態確認
+// 各欄位須在開始使用前設定；之後 Token 與 Headers 只能通過 SetToken 等方法修改，複製客戶端請使用 Clone
+type Client struct {
+	BaseURL        string        // API 基礎地址，不含結尾的 "/"
+	Token          string        // 請求頭中的 Token，開始使用後請通過 CurrentToken 與 SetToken 讀寫
+	StudentName    string        // 操作空調時提交的學生姓名
+	HTTPClient     *http.Client  // 共用的 HTTP 客戶端
+	Headers        HeaderProfile // 模擬的瀏覽器請求頭，換取 Token 後 Referer 會被更新
+	Verify         VerifyOptions // Switch 確認指令送達的配置
+	Retry          RetryPolicy   // 暫時性錯誤的重試策略
+	RequestTimeout time.Duration // 單次 HTTP 請求 (每次重試分別計算) 的超時時間，0 表示僅受 ctx 限制
+	AuthPath       string        // 以 OAuth code 換取 Token 的接口路徑，為空時無法換取 Token
+	BalanceFloor   float64       // 大於 0 時，電費餘額不高於此值則 Switch 拒絕開啟空調
+
+	// ExperimentalSettings 為 true 時才允許 SetTemp、SetMode 與 SetWind 發送設定指令
+	// 這些指令的 commandKey 及取值編號均為推測，確認前須由用戶明確啟用，以免向真實設備發送未知的指令
+	ExperimentalSettings bool
+
+	// Reauth 在 Token 過期時被調用，expired 為過期的 Token，返回新的 Token 後請求會重試一次
+	// 為 nil 時直接返回 ErrTokenExpired
+	Reauth func(ctx context.Context, expired string) (string, error)
+
+	// Observe 在每次獲取設備信息或空調操作的請求完成後被調用，用於統計，為 nil 時不統計
+	Observe func(RequestInfo)
+
+	mu sync.RWMutex // 保護 Token 與 Headers，重新授權時會在其他請求進行中被修改
+}
+
+// New 函數用於以默認配置創建客戶端
+func New(token, studentName string) *Client {
+	return &Client{
+		BaseURL:        DefaultBaseURL,
+		Token:          token,
+		StudentName:    studentName,
+		HTTPClient:     &http.Client{},
+		Headers:        DefaultHeaderProfile(),
+		Verify:         DefaultVerifyOptions(),
+		Retry:          DefaultRetryPolicy(),
+		RequestTimeout: DefaultRequestTimeout,
+	}
+}
+
+// Clone 方法用於返回客戶端的副本，副本的 Token 與 Headers 可獨立修改
+func (c *Client) Clone() *Client {
+	token, headers := c.credentials()
+	return &Client{
+		BaseURL:              c.BaseURL,
+		Token:                token,
+		StudentName:          c.StudentName,
+		HTTPClient:           c.HTTPClient,
+		Headers:              headers,
+		Verify:               c.Verify,
+		Retry:                c.Retry,
+		RequestTimeout:       c.RequestTimeout,
+		AuthPath:             c.AuthPath,
+		BalanceFloor:         c.BalanceFloor,
+		ExperimentalSettings: c.ExperimentalSettings,
+		Reauth:               c.Reauth,
+		Observe:              c.Observe,
+	}
+}
+
+// CurrentToken 方法用於返回當前使用的 Token
+func (c *Client) CurrentToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Token
+}
+
+// SetToken 方法用於更換 Token，之後的請求 (包括重試) 均使用新的 Token
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Token = token
+}
+
+// credentials 方法用於返回當前的 Token 與請求頭
+func (c *Client) credentials() (string, HeaderProfile) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Token, c.Headers
+}
+
+// setReferer 方法用於更新 GET 請求使用的 Referer
+func (c *Client) setReferer(referer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Headers.Referer = referer
+}
+
+// GetDevice 方法用於獲取設備信息，同時返回 HTTP 回應狀態碼
+// 遇到暫時性錯誤時按 c.Retry 重試
+func (c *Client) GetDevice(ctx context.Context, deviceNo string) (*DeviceInfo, int, error) {
+	var device *DeviceInfo
+	var statusCode int
+	err := c.retry(ctx, func() (err error) {
+		start := time.Now()
+		device, statusCode, err = c.getDevice(ctx, deviceNo)
+		c.observe(EndpointGetDevice, start, statusCode, err)
+		return err
+	})
+	return device, statusCode, err
+}
+
+// getDevice 方法用於發送一次獲取設備信息的請求
+func (c *Client) getDevice(ctx context.Context, deviceNo string) (*DeviceInfo, int, error) {
+	endpoint := c.endpoint("/device/getDeviceByNo") + "?deviceNo=" + url.QueryEscape(deviceNo)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("創建 GET 請求失敗: %w", err)
+	}
+	c.setHeaders(req, func(h HeaderProfile) string { return h.Referer })
+
+	statusCode, body, err := c.do(req)
+	if err != nil {
+		return nil, statusCode, err
+	}
+
+	var getResponse GetAPIResponse
+	if err := json.Unmarshal(body, &getResponse); err != nil {
+		return nil, statusCode, fmt.Errorf("解析 GET JSON 失敗: %w, 原始響應體:\n%s", err, string(body))
+	}
+
+	if getResponse.Code != 0 {
+		return nil, statusCode, &APIError{Op: "獲取設備信息", Code: getResponse.Code, Msg: getResponse.Msg, StatusCode: statusCode}
+	}
+
+	return &getResponse.Data, statusCode, nil
+}
+
+// Operate 方法用於向設備發送開關指令，command 為 CommandAirOpen 或 CommandAirClose
+// 調整溫度、模式與風速請使用 SetTemp、SetMode 與 SetWind
+// 注意：device 會被就地修改為提交的 payload
+func (c *Client) Operate(ctx context.Context, device *DeviceInfo, command string) (*OperateResult, error) {
+	// 根據操作類型設置 commandKey 和 fanStatus
+	switch command {
+	case CommandAirOpen:
+		if device.DeviceFan != nil {
+			device.DeviceFan.FanStatus = 1 // 開啟
+		}
+	case CommandAirClose:
+		if device.DeviceFan != nil {
+			device.DeviceFan.FanStatus = 0 // 關閉
+		}
+	default:
+		return nil, fmt.Errorf("無效的操作指令：%s", command)
+	}
+	return c.submit(ctx, device, command)
+}
+
+// submit 方法用於將設定好的 device 以指定 commandKey 提交到 operateDevice 接口
+// 開關及設定指令重複執行的效果相同，因此遇到暫時性錯誤時同樣按 c.Retry 重試
+func (c *Client) submit(ctx context.Context, device *DeviceInfo, command string) (*OperateResult, error) {
+	var result *OperateResult
+	err := c.retry(ctx, func() (err error) {
+		start := time.Now()
+		result, err = c.submitOnce(ctx, device, command)
+		statusCode := 0
+		if result != nil {
+			statusCode = result.StatusCode
+		}
+		c.observe(EndpointOperate, start, statusCode, err)
+		return err
+	})
+	return result, err
+}
+
+// submitOnce 方法用於發送一次 operateDevice 請求
+func (c *Client) submitOnce(ctx context.Context, device *DeviceInfo, command string) (*OperateResult, error) {
+	device.CommandKey = command
+	device.StudentName = c.StudentName
+
+	// 將更新後的 device 序列化為 JSON
+	payloadBytes, err := json.Marshal(device)
+	if err != nil {
+		return nil, fmt.Errorf("序列化請求 payload 失敗: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/device/operateDevice"), bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("創建 POST 請求失敗: %w", err)
+	}
+	c.setHeaders(req, func(h HeaderProfile) string { return h.OperateReferer })
+	req.Header.Set("Content-Type", "application/json") // POST 請求需要設置 Content-Type
+
+	statusCode, body, err := c.do(req)
+	result := &OperateResult{StatusCode: statusCode}
+	if err != nil {
+		return result, err
+	}
+
+	var operateResponse OperateAPIResponse
+	if err := json.Unmarshal(body, &operateResponse); err != nil {
+		return result, fmt.Errorf("解析 POST JSON 失敗: %w, 原始響應體:\n%s", err, string(body))
+	}
+
+	if operateResponse.Code != 0 {
+		return result, &APIError{Op: "空調操作", Code: operateResponse.Code, Msg: operateResponse.Msg, StatusCode: statusCode}
+	}
+
+	result.MsgID = operateResponse.Data.MsgID
+	result.DeviceNo = operateResponse.Data.DeviceNo
+	return result, nil
+}
+
+// endpoint 方法用於拼接完整的接口地址
+func (c *Client) endpoint(path string) string {
+	return strings.TrimRight(c.BaseURL, "/") + path
+}
+
+// setHeaders 方法用於設置模擬瀏覽器的通用請求頭，referer 用於從請求頭配置中選擇 Referer
+// POST 請求另外帶有 Origin
+func (c *Client) setHeaders(req *http.Request, referer func(HeaderProfile) string) {
+	token, h := c.credentials()
+	req.Header.Set("User-Agent", h.UserAgent)
+	req.Header.Set("Token", token)
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	req.Header.Set("Sec-Fetch-Dest", "empty")
+	req.Header.Set("Referer", referer(h))
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	req.Header.Set("Accept-Language", h.AcceptLanguage)
+	if req.Method == http.MethodPost {
+		req.Header.Set("Origin", h.Origin)
+	}
+	req.Header.Set("Priority", "u=1, i")
+	req.Close = true // 對應 Connection: close
+}
+
+// do 方法用於發送請求並讀取完整的響應體
+func (c *Client) do(req *http.Request) (int, []byte, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	// 超時僅作用於本次請求，調用方的 ctx 被取消時不再重試
+	parent := req.Context()
+	if c.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(parent, c.RequestTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, classifyTransportError(parent, fmt.Errorf("發送 %s 請求失敗: %w", req.Method, err))
+	}
+	defer resp.Body.Close()
+	if err := checkHTTPStatus(req.Method, resp.StatusCode); err != nil {
+		return resp.StatusCode, nil, err
+	}
+
+	// 請求頭中顯式設置了 Accept-Encoding，net/http 不會自動解壓，需按 Content-Encoding 自行處理
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("解壓 %s 響應體失敗: %w", req.Method, err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return resp.StatusCode, nil, classifyTransportError(parent, fmt.Errorf("讀取 %s 響應體失敗: %w", req.Method, err))
+	}
+	return resp.StatusCode, body, nil
+}
+
+// decodeBody 函數用於按 Content-Encoding 返回解壓後的響應體
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(resp.Body)
+	case "deflate":
+		// HTTP 規範中的 deflate 為 zlib 格式，但部分服務端直接發送原始 deflate 數據
+		br := bufio.NewReader(resp.Body)
+		header, err := br.Peek(2)
+		if err == nil && isZlibHeader(header) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("不支持的 Content-Encoding: %s", resp.Header.Get("Content-Encoding"))
+	}
+}
+
+// isZlibHeader 函數用於判斷數據是否以 zlib 頭部開始 (RFC 1950)
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
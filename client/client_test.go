@@ -0,0 +1,193 @@
+package client_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"actool/client"
+)
+
+// deviceResponse 為壓縮測試使用的 getDeviceByNo 響應體
+var deviceResponse = func() []byte {
+	var resp client.GetAPIResponse
+	resp.Msg = "success"
+	resp.Data.DeviceNo = "D1"
+	resp.Data.RoomNo = "301"
+	resp.Data.Balance = 12.5
+	body, _ := json.Marshal(resp)
+	return body
+}()
+
+// compress 函數用於以指定的壓縮器壓縮 deviceResponse
+func compress(t *testing.T, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(deviceResponse); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestGetDeviceCompression 測試按 Content-Encoding 解壓響應體，deflate 兼容 zlib 格式與原始 deflate 數據
+func TestGetDeviceCompression(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantErr  string
+	}{
+		{name: "identity", encoding: "", body: deviceResponse},
+		{name: "explicit identity", encoding: "identity", body: deviceResponse},
+		{name: "gzip", encoding: "gzip", body: compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{name: "x-gzip", encoding: "x-gzip", body: compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{name: "upper case", encoding: " GZIP ", body: compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{name: "zlib deflate", encoding: "deflate", body: compress(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })},
+		{name: "raw deflate", encoding: "deflate", body: compress(t, func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		})},
+		{name: "unsupported", encoding: "br", body: []byte("whatever"), wantErr: "不支持的 Content-Encoding: br"},
+		{name: "corrupt gzip", encoding: "gzip", body: []byte("not gzip"), wantErr: "解壓 GET 響應體失敗"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Accept-Encoding"); got != "gzip, deflate" {
+					t.Errorf("Accept-Encoding = %q", got)
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer ts.Close()
+
+			c := client.New("t", "x")
+			c.BaseURL = ts.URL
+			c.Retry.MaxAttempts = 1
+			device, _, err := c.GetDevice(context.Background(), "D1")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("錯誤為 %v，應包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetDevice: %v", err)
+			}
+			if device.DeviceNo != "D1" || device.RoomNo != "301" || device.Balance != 12.5 {
+				t.Errorf("解壓後的設備信息不正確: %+v", device)
+			}
+		})
+	}
+}
+
+// TestRequestHeaders 測試獲取設備信息與空調操作的請求地址、請求頭及 payload，SetToken 後的請求使用新的 Token
+func TestRequestHeaders(t *testing.T) {
+	var requests []*http.Request
+	var payload client.DeviceInfo
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/api/device/getDeviceByNo":
+			w.Write(deviceResponse)
+		case "/api/device/operateDevice":
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("解析 payload 失敗: %v", err)
+			}
+			w.Write([]byte(`{"code":0,"msg":"success","data":{"msgId":"42","deviceNo":"D1"}}`))
+		default:
+			t.Errorf("未預期的請求 %s %s", r.Method, r.URL)
+		}
+	}))
+	defer ts.Close()
+
+	c := client.New("t1", "小明")
+	c.BaseURL = ts.URL + "/api/"
+	c.Retry.MaxAttempts = 1
+	ctx := context.Background()
+
+	device, statusCode, err := c.GetDevice(ctx, "D 1")
+	if err != nil || statusCode != http.StatusOK {
+		t.Fatalf("GetDevice = %d, %v", statusCode, err)
+	}
+	c.SetToken("t2")
+	result, err := c.Operate(ctx, device, client.CommandAirOpen)
+	if err != nil {
+		t.Fatalf("Operate: %v", err)
+	}
+	if result.MsgID != "42" || result.DeviceNo != "D1" || result.StatusCode != http.StatusOK {
+		t.Errorf("OperateResult = %+v", result)
+	}
+	if payload.CommandKey != client.CommandAirOpen || payload.StudentName != "小明" || payload.DeviceNo != "D1" {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	h := client.DefaultHeaderProfile()
+	get, post := requests[0], requests[1]
+	if get.Method != http.MethodGet || get.URL.Query().Get("deviceNo") != "D 1" {
+		t.Errorf("GET 請求為 %s %s", get.Method, get.URL)
+	}
+	for _, tt := range []struct {
+		req    *http.Request
+		header string
+		want   string
+	}{
+		{get, "Token", "t1"},
+		{get, "User-Agent", h.UserAgent},
+		{get, "Referer", h.Referer},
+		{get, "Accept-Language", h.AcceptLanguage},
+		{get, "Origin", ""},
+		{post, "Token", "t2"},
+		{post, "Referer", h.OperateReferer},
+		{post, "Origin", h.Origin},
+		{post, "Content-Type", "application/json"},
+	} {
+		if got := tt.req.Header.Get(tt.header); got != tt.want {
+			t.Errorf("%s 請求頭 %s = %q，應為 %q", tt.req.Method, tt.header, got, tt.want)
+		}
+	}
+}
+
+// TestResponseErrors 測試響應中非 0 的 code 轉換為 APIError，無法解析的響應體返回包含原文的錯誤
+func TestResponseErrors(t *testing.T) {
+	body := `{"code":500,"msg":"系統繁忙","data":null}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	c := client.New("t", "x")
+	c.BaseURL = ts.URL
+	c.Retry.MaxAttempts = 1
+	_, _, err := c.GetDevice(context.Background(), "D1")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 500 || apiErr.Msg != "系統繁忙" || apiErr.Op != "獲取設備信息" {
+		t.Fatalf("錯誤為 %#v，應為 APIError", err)
+	}
+
+	body = "<html>維護中</html>"
+	_, _, err = c.GetDevice(context.Background(), "D1")
+	if err == nil || !strings.Contains(err.Error(), "解析 GET JSON 失敗") || !strings.Contains(err.Error(), body) {
+		t.Errorf("錯誤為 %v，應包含原始響應體", err)
+	}
+
+	_, err = c.Operate(context.Background(), &client.DeviceInfo{DeviceNo: "D1"}, "AirBoost")
+	if err == nil || !strings.Contains(err.Error(), "無效的操作指令") {
+		t.Errorf("無效指令返回 %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// 可用 errors.Is 判斷的錯誤類別
+var (
+	ErrUnauthorized  = errors.New("token 無效或已過期")
+	ErrTokenExpired  = errors.New("token 已過期") // 屬於 ErrUnauthorized，可通過重新授權換取新的 Token
+	ErrDeviceOffline = errors.New("設備離線或不存在")
+	ErrNetwork       = errors.New("網絡錯誤或服務暫時不可用")
+	ErrLowBalance    = errors.New("電費餘額過低") // 餘額不高於 Client.BalanceFloor 時拒絕開啟空調
+	ErrNoAuthPath    = errors.New("未設定換取 Token 的接口路徑 (AUTH_PATH)")
+)
+
+// APIError 結構體表示 hatch-api 返回了非 0 的 code，即 GetAPIResponse.Code 或 OperateAPIResponse.Code
+// 可用 errors.Is 判斷是否屬於 ErrUnauthorized 或 ErrDeviceOffline
+type APIError struct {
+	Op         string // 出錯的接口，例如 "獲取設備信息"、"空調操作"
+	Code       int    // 響應中的 code
+	Msg        string // 響應中的 msg
+	StatusCode int    // HTTP 回應狀態碼
+}
+
+// Error 方法用於實現 error 接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API 返回錯誤代碼: %d, 訊息: %s", e.Op, e.Code, e.Msg)
+}
+
+// Is 方法用於按 code 及 msg 將錯誤歸類
+// code 401 屬於 ErrTokenExpired，401 與 403 屬於 ErrUnauthorized
+// hatch-api 沒有公開的錯誤代碼表，部分 Token 錯誤以其他 code 返回，因此亦識別 tokenExpiredMessages 與 tokenInvalidMessages 中的完整訊息
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrTokenExpired:
+		return e.Code == http.StatusUnauthorized || knownMessage(e.Msg, tokenExpiredMessages)
+	case ErrUnauthorized:
+		return e.Code == http.StatusUnauthorized || e.Code == http.StatusForbidden ||
+			knownMessage(e.Msg, tokenExpiredMessages) || knownMessage(e.Msg, tokenInvalidMessages)
+	case ErrDeviceOffline:
+		return e.Code == http.StatusNotFound ||
+			containsAny(strings.ToLower(e.Msg), "離線", "离线", "offline", "不存在", "不在線", "不在线")
+	}
+	return false
+}
+
+// tokenExpiredMessages 為已知表示 Token 已過期、可重新授權的訊息
+var tokenExpiredMessages = []string{
+	"token已過期", "token已过期", "token過期", "token过期", "token expired",
+	"登錄已過期", "登录已过期", "登錄已過期，請重新登錄", "登录已过期，请重新登录",
+	"授權已失效", "授权已失效", "授權已過期", "授权已过期",
+}
+
+// tokenInvalidMessages 為已知表示 Token 無效的訊息
+var tokenInvalidMessages = []string{
+	"token無效", "token无效", "無效的token", "无效的token", "invalid token", "unauthorized",
+	"未登錄", "未登录", "請先登錄", "请先登录",
+}
+
+// knownMessage 函數用於判斷 msg 是否為 messages 之一，比較時忽略大小寫、首尾空白及結尾的標點
+func knownMessage(msg string, messages []string) bool {
+	msg = strings.TrimRight(strings.ToLower(strings.TrimSpace(msg)), "。.!！")
+	msg = strings.Join(strings.Fields(msg), " ")
+	for _, m := range messages {
+		if msg == m || strings.ReplaceAll(msg, " ", "") == m {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny 函數用於判斷 s 是否包含任一關鍵字
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
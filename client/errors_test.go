@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	"actool/client"
+)
+
+// TestAPIErrorIs 測試 API 錯誤只在 code 為 401/403/404 或訊息為已知的完整訊息時歸入對應類別
+func TestAPIErrorIs(t *testing.T) {
+	tests := []struct {
+		code    int
+		msg     string
+		expired bool
+		unauth  bool
+		offline bool
+	}{
+		{401, "", true, true, false},
+		{403, "禁止訪問", false, true, false},
+		{404, "not found", false, false, true},
+		{500, "Token已過期", true, true, false},
+		{500, "token 已过期。", true, true, false},
+		{500, "登录已过期，请重新登录", true, true, false},
+		{1, "授權已失效", true, true, false},
+		{500, "Token Expired", true, true, false},
+		{500, "Token無效", false, true, false},
+		{500, "Invalid  token!", false, true, false},
+		{500, "未登录", false, true, false},
+		{500, "设备离线", false, false, true},
+		// 只是提及 token、登錄、授權或過期的業務錯誤不視為 Token 錯誤
+		{500, "token 參數格式錯誤", false, false, false},
+		{500, "缺少 token 字段", false, false, false},
+		{500, "優惠券已過期", false, false, false},
+		{500, "授權碼 expire 參數無效", false, false, false},
+		{500, "登錄次數過多，請稍後再試", false, false, false},
+		{500, "系統繁忙", false, false, false},
+		{0, "", false, false, false},
+	}
+	for _, tt := range tests {
+		err := &client.APIError{Op: "測試", Code: tt.code, Msg: tt.msg}
+		if got := errors.Is(err, client.ErrTokenExpired); got != tt.expired {
+			t.Errorf("code %d %q: 屬於 ErrTokenExpired = %v，應為 %v", tt.code, tt.msg, got, tt.expired)
+		}
+		if got := errors.Is(err, client.ErrUnauthorized); got != tt.unauth {
+			t.Errorf("code %d %q: 屬於 ErrUnauthorized = %v，應為 %v", tt.code, tt.msg, got, tt.unauth)
+		}
+		if got := errors.Is(err, client.ErrDeviceOffline); got != tt.offline {
+			t.Errorf("code %d %q: 屬於 ErrDeviceOffline = %v，應為 %v", tt.code, tt.msg, got, tt.offline)
+		}
+	}
+}
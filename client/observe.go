@@ -0,0 +1,34 @@
+package client
+
+import (
+	"errors"
+	"time"
+)
+
+// 統計時使用的接口名稱
+const (
+	EndpointGetDevice = "getDeviceByNo"
+	EndpointOperate   = "operateDevice"
+)
+
+// RequestInfo 結構體描述一次已完成的 hatch-api 請求 (每次重試分別記錄)，用於統計延遲與錯誤
+type RequestInfo struct {
+	Endpoint   string        // 接口名稱，例如 EndpointGetDevice
+	Duration   time.Duration // 從發送請求到解析完響應的耗時
+	StatusCode int           // HTTP 回應狀態碼，網絡錯誤時為 0
+	Code       int           // 響應中的 code，成功時為 0
+	Err        error         // 請求失敗的原因，成功時為 nil
+}
+
+// observe 方法用於在設定了 c.Observe 時報告一次請求的結果
+func (c *Client) observe(endpoint string, start time.Time, statusCode int, err error) {
+	if c.Observe == nil {
+		return
+	}
+	info := RequestInfo{Endpoint: endpoint, Duration: time.Since(start), StatusCode: statusCode, Err: err}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		info.Code = apiErr.Code
+	}
+	c.Observe(info)
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy 結構體用於配置請求失敗時的重試策略
+// 僅暫時性錯誤 (網絡錯誤、超時、HTTP 5xx/429) 會被重試，API 返回的錯誤代碼不會重試
+type RetryPolicy struct {
+	MaxAttempts  int           // 最多嘗試次數 (含首次)，小於等於 1 表示不重試
+	InitialDelay time.Duration // 首次重試前的等待時間，之後每次加倍
+	MaxDelay     time.Duration // 兩次嘗試之間的最長等待時間
+	Jitter       float64       // 等待時間的隨機浮動比例 (0-1)，避免多個客戶端同時重試
+}
+
+// DefaultRetryPolicy 返回默認的重試策略：最多嘗試 3 次，等待 0.5 秒起並加倍
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 500 * time.Millisecond,
+		MaxDelay:     8 * time.Second,
+		Jitter:       0.2,
+	}
+}
+
+// Backoff 方法用於計算第 attempt 次嘗試失敗後 (從 1 開始) 的等待時間
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 {
+		delay = min(delay, p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return max(delay, 0)
+}
+
+// temporaryError 結構體用於標記可重試的暫時性錯誤
+type temporaryError struct {
+	err error
+}
+
+// Error 方法用於實現 error 接口
+func (e *temporaryError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap 方法用於返回原始錯誤
+func (e *temporaryError) Unwrap() error {
+	return e.err
+}
+
+// Is 方法用於將暫時性錯誤歸類為 ErrNetwork
+func (e *temporaryError) Is(target error) bool {
+	return target == ErrNetwork
+}
+
+// IsTemporary 函數用於判斷錯誤是否為暫時性錯誤，即稍後重試可能成功
+func IsTemporary(err error) bool {
+	var tempErr *temporaryError
+	return errors.As(err, &tempErr)
+}
+
+// classifyTransportError 函數用於將發送請求時的錯誤分類
+// 網絡錯誤、超時、連接被重置等均視為暫時性錯誤，但 ctx 被取消時不重試
+func classifyTransportError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
+	}
+	return &temporaryError{err: err}
+}
+
+// checkHTTPStatus 函數用於檢查 HTTP 狀態碼，5xx 與 429 視為暫時性錯誤，401 歸類為 ErrTokenExpired，403 歸類為 ErrUnauthorized
+func checkHTTPStatus(method string, statusCode int) error {
+	switch {
+	case statusCode >= 500 || statusCode == http.StatusTooManyRequests:
+		return &temporaryError{err: fmt.Errorf("%s 請求返回 HTTP %d", method, statusCode)}
+	case statusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%s 請求返回 HTTP %d: %w", method, statusCode, errors.Join(ErrUnauthorized, ErrTokenExpired))
+	case statusCode == http.StatusForbidden:
+		return fmt.Errorf("%s 請求返回 HTTP %d: %w", method, statusCode, ErrUnauthorized)
+	}
+	return nil
+}
+
+// retry 方法用於按 c.Retry 執行 op，遇到暫時性錯誤時等待後重試
+// Token 過期且設定了 c.Reauth 時，以換取的新 Token 再執行一次
+func (c *Client) retry(ctx context.Context, op func() error) error {
+	var err error
+	reauthed := false
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err != nil && !reauthed && c.reauth(ctx, err) {
+			reauthed = true
+			err = op()
+		}
+		if err == nil || !IsTemporary(err) || attempt >= c.Retry.MaxAttempts {
+			return err
+		}
+
+		t := time.NewTimer(c.Retry.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"actool/client"
+)
+
+// TestBackoff 測試等待時間按次數加倍、不超過 MaxDelay，且隨機浮動在 Jitter 範圍內
+func TestBackoff(t *testing.T) {
+	p := client.RetryPolicy{InitialDelay: 500 * time.Millisecond, MaxDelay: 3 * time.Second}
+	want := []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w {
+			t.Errorf("Backoff(%d) = %s，應為 %s", i+1, got, w)
+		}
+	}
+	if got := p.Backoff(1000); got != p.MaxDelay {
+		t.Errorf("Backoff(1000) = %s，應為 MaxDelay", got)
+	}
+	if got := (client.RetryPolicy{InitialDelay: time.Second}).Backoff(4); got != 8*time.Second {
+		t.Errorf("沒有 MaxDelay 時 Backoff(4) = %s，應為 8s", got)
+	}
+
+	p.Jitter = 0.2
+	for range 100 {
+		if got := p.Backoff(2); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("Backoff(2) = %s，超出 1s±20%%", got)
+		}
+	}
+}
+
+// failingServer 函數用於創建前 failures 次請求返回 status、之後返回設備信息的測試服務
+func failingServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(deviceResponse)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests
+}
+
+// newRetryClient 函數用於創建最多嘗試 attempts 次、等待時間為毫秒級的客戶端
+func newRetryClient(baseURL string, attempts int) *client.Client {
+	c := client.New("t", "x")
+	c.BaseURL = baseURL
+	c.Retry = client.RetryPolicy{MaxAttempts: attempts, InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	return c
+}
+
+// TestRetryTemporary 測試 HTTP 5xx 與 429 被重試直到成功，次數用盡時返回屬於 ErrNetwork 的暫時性錯誤
+func TestRetryTemporary(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int32
+		status   int
+		attempts int
+		wantErr  bool
+		requests int32
+	}{
+		{"5xx 後成功", 2, http.StatusServiceUnavailable, 3, false, 3},
+		{"429 後成功", 1, http.StatusTooManyRequests, 3, false, 2},
+		{"次數用盡", 5, http.StatusBadGateway, 3, true, 3},
+		{"不重試", 1, http.StatusInternalServerError, 1, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, requests := failingServer(t, tt.failures, tt.status)
+			c := newRetryClient(ts.URL, tt.attempts)
+			device, _, err := c.GetDevice(context.Background(), "D1")
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("發送了 %d 次請求，應為 %d 次", got, tt.requests)
+			}
+			if !tt.wantErr {
+				if err != nil || device.DeviceNo != "D1" {
+					t.Errorf("GetDevice = %+v, %v", device, err)
+				}
+				return
+			}
+			if !client.IsTemporary(err) || !errors.Is(err, client.ErrNetwork) {
+				t.Errorf("錯誤為 %v，應為屬於 ErrNetwork 的暫時性錯誤", err)
+			}
+		})
+	}
+}
+
+// TestRetryPermanent 測試 401、403 及 API 錯誤代碼不重試，並歸類為對應的錯誤
+func TestRetryPermanent(t *testing.T) {
+	tests := []struct {
+		status int
+		want   []error
+		not    []error
+	}{
+		{http.StatusUnauthorized, []error{client.ErrUnauthorized, client.ErrTokenExpired}, []error{client.ErrNetwork}},
+		{http.StatusForbidden, []error{client.ErrUnauthorized}, []error{client.ErrTokenExpired, client.ErrNetwork}},
+	}
+	for _, tt := range tests {
+		ts, requests := failingServer(t, 10, tt.status)
+		_, _, err := newRetryClient(ts.URL, 5).GetDevice(context.Background(), "D1")
+		if requests.Load() != 1 || client.IsTemporary(err) {
+			t.Errorf("HTTP %d 被重試了 %d 次: %v", tt.status, requests.Load(), err)
+		}
+		for _, target := range tt.want {
+			if !errors.Is(err, target) {
+				t.Errorf("HTTP %d 的錯誤 %v 應屬於 %v", tt.status, err, target)
+			}
+		}
+		for _, target := range tt.not {
+			if errors.Is(err, target) {
+				t.Errorf("HTTP %d 的錯誤 %v 不應屬於 %v", tt.status, err, target)
+			}
+		}
+	}
+
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"code":500,"msg":"系統繁忙"}`))
+	}))
+	defer ts.Close()
+	_, _, err := newRetryClient(ts.URL, 5).GetDevice(context.Background(), "D1")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || client.IsTemporary(err) || requests.Load() != 1 {
+		t.Errorf("API 錯誤代碼返回 %v 並請求了 %d 次，應不重試", err, requests.Load())
+	}
+}
+
+// TestRetryNetworkError 測試無法連接時視為暫時性錯誤並重試
+func TestRetryNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+
+	var attempts int
+	c := newRetryClient(url, 3)
+	c.Observe = func(client.RequestInfo) { attempts++ }
+	_, _, err := c.GetDevice(context.Background(), "D1")
+	if !client.IsTemporary(err) || !errors.Is(err, client.ErrNetwork) || attempts != 3 {
+		t.Errorf("嘗試 %d 次後返回 %v，應嘗試 3 次並返回暫時性錯誤", attempts, err)
+	}
+}
+
+// TestRetryCancel 測試等待重試期間 ctx 被取消時立即返回，被取消的請求不視為暫時性錯誤
+func TestRetryCancel(t *testing.T) {
+	ts, requests := failingServer(t, 100, http.StatusServiceUnavailable)
+	c := newRetryClient(ts.URL, 10)
+	c.Retry.InitialDelay, c.Retry.MaxDelay = time.Hour, time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := c.GetDevice(ctx, "D1")
+	if err == nil || requests.Load() != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("取消後返回 %v，請求了 %d 次", err, requests.Load())
+	}
+
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, _, err := c.GetDevice(cancelled, "D1"); client.IsTemporary(err) {
+		t.Errorf("ctx 已取消的請求返回暫時性錯誤 %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 空調設定的 commandKey，與 AirOpen/AirClose 同樣經由 operateDevice 提交
+// 注意：未經驗證的假設。抓包記錄 (AirOpen.json) 中只有 AirOpen 與 AirClose，
+// 以下名稱僅按其命名方式推測，目前只在 mockserver 上測試過，真實的 hatch-api 可能返回錯誤或忽略指令
+// 因此默認不發送，須設定 Client.ExperimentalSettings 啟用
+const (
+	CommandAirTemp = "AirTemp"  // 設定溫度
+	CommandAirMode = "AirModel" // 設定運行模式
+	CommandAirWind = "AirWind"  // 設定風速
+)
+
+// 空調運行模式，對應 DeviceFan.FanModel
+// 注意：未經驗證的假設。GetdeviceNo.json 中只有 fanModel 欄位的一個取值，以下編號為按常見空調協議推測
+const (
+	ModeCool = 1 // 制冷
+	ModeHeat = 2 // 制熱
+	ModeFan  = 3 // 送風
+	ModeDry  = 4 // 除濕
+)
+
+// 風速，對應 DeviceFan.WindSpeed
+// 注意：與運行模式相同，編號為未經驗證的推測
+const (
+	WindAuto = 0 // 自動
+	WindLow  = 1 // 低速
+	WindMid  = 2 // 中速
+	WindHigh = 3 // 高速
+)
+
+// ErrInvalidSetting 表示空調設定的取值無效，例如溫度超出設備允許的範圍
+var ErrInvalidSetting = errors.New("無效的空調設定")
+
+// ErrSettingsDisabled 表示未設定 Client.ExperimentalSettings，拒絕發送未經驗證的設定指令
+var ErrSettingsDisabled = errors.New("溫度、模式與風速的設定指令未經真實設備驗證，默認停用 (EXPERIMENTAL_SETTINGS)")
+
+// settingName 結構體用於對應設定取值的英文名稱與中文描述
+type settingName struct {
+	value int
+	name  string
+	label string
+}
+
+var (
+	modeNames = []settingName{
+		{ModeCool, "cool", "制冷"},
+		{ModeHeat, "heat", "制熱"},
+		{ModeFan, "fan", "送風"},
+		{ModeDry, "dry", "除濕"},
+	}
+	windNames = []settingName{
+		{WindAuto, "auto", "自動"},
+		{WindLow, "low", "低速"},
+		{WindMid, "mid", "中速"},
+		{WindHigh, "high", "高速"},
+	}
+)
+
+// ParseMode 函數用於將 cool/heat/fan/dry 轉換為運行模式
+func ParseMode(name string) (int, error) {
+	return parseSettingName(modeNames, "模式", name)
+}
+
+// ModeLabel 函數用於返回運行模式的中文描述 (按推測的編號)
+func ModeLabel(mode int) string {
+	return settingLabel(modeNames, mode)
+}
+
+// ModeName 函數用於返回運行模式的英文名稱，例如 "cool"，未知取值返回空字串
+func ModeName(mode int) string {
+	return settingNameOf(modeNames, mode)
+}
+
+// ValidMode 函數用於判斷運行模式是否有效
+func ValidMode(mode int) bool {
+	return hasSetting(modeNames, mode)
+}
+
+// ParseWind 函數用於將 low/mid/high/auto 轉換為風速
+func ParseWind(name string) (int, error) {
+	return parseSettingName(windNames, "風速", name)
+}
+
+// WindLabel 函數用於返回風速的中文描述 (按推測的編號)
+func WindLabel(wind int) string {
+	return settingLabel(windNames, wind)
+}
+
+// WindName 函數用於返回風速的英文名稱，例如 "low"，未知取值返回空字串
+func WindName(wind int) string {
+	return settingNameOf(windNames, wind)
+}
+
+// ValidWind 函數用於判斷風速是否有效
+func ValidWind(wind int) bool {
+	return hasSetting(windNames, wind)
+}
+
+// parseSettingName 函數用於按名稱查找設定取值
+func parseSettingName(names []settingName, kind, name string) (int, error) {
+	valid := make([]string, 0, len(names))
+	for _, n := range names {
+		if strings.EqualFold(n.name, name) {
+			return n.value, nil
+		}
+		valid = append(valid, n.name)
+	}
+	return 0, fmt.Errorf("%w：%s %q 無效，可選值為 %s", ErrInvalidSetting, kind, name, strings.Join(valid, "|"))
+}
+
+// settingLabel 函數用於返回設定取值的中文描述，未知取值返回數字
+func settingLabel(names []settingName, value int) string {
+	for _, n := range names {
+		if n.value == value {
+			return n.label
+		}
+	}
+	return fmt.Sprintf("未知(%d)", value)
+}
+
+// settingNameOf 函數用於返回設定取值的英文名稱，未知取值返回空字串
+func settingNameOf(names []settingName, value int) string {
+	for _, n := range names {
+		if n.value == value {
+			return n.name
+		}
+	}
+	return ""
+}
+
+// hasSetting 函數用於判斷設定取值是否有效
+func hasSetting(names []settingName, value int) bool {
+	for _, n := range names {
+		if n.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// 設備未報告溫度範圍 (MaxTemp 為 0) 時使用的默認範圍
+const (
+	DefaultMinTemp = 16.0
+	DefaultMaxTemp = 30.0
+)
+
+// TempRange 函數用於返回設備允許的溫度範圍，設備未報告有效範圍時返回默認範圍
+func TempRange(fan *DeviceFan) (minTemp, maxTemp float64) {
+	if fan == nil || !(fan.MaxTemp > fan.MinTemp) {
+		return DefaultMinTemp, DefaultMaxTemp
+	}
+	return fan.MinTemp, fan.MaxTemp
+}
+
+// ValidateTemp 函數用於校驗溫度是否為有限的數值且在設備允許的範圍內
+func ValidateTemp(device *DeviceInfo, temp float64) error {
+	fan := device.DeviceFan
+	if fan == nil {
+		return fmt.Errorf("%w：設備 %s 沒有空調信息", ErrInvalidSetting, device.DeviceNo)
+	}
+	minTemp, maxTemp := TempRange(fan)
+	if !(temp >= minTemp && temp <= maxTemp) { // 同時排除 NaN
+		return fmt.Errorf("%w：溫度 %g 超出設備允許的範圍 %g-%g", ErrInvalidSetting, temp, minTemp, maxTemp)
+	}
+	return nil
+}
+
+// SetTemp 方法用於設定空調溫度，溫度須在設備的 MinTemp 與 MaxTemp 之間
+// SetTemp、SetMode 與 SetWind 在 c.ExperimentalSettings 為 false 時返回 ErrSettingsDisabled，不發送請求
+// 注意：device 會被就地修改為提交的 payload
+func (c *Client) SetTemp(ctx context.Context, device *DeviceInfo, temp float64) (*OperateResult, error) {
+	if !c.ExperimentalSettings {
+		return nil, ErrSettingsDisabled
+	}
+	if err := ValidateTemp(device, temp); err != nil {
+		return nil, err
+	}
+	device.DeviceFan.TempSetting = temp
+	return c.submit(ctx, device, CommandAirTemp)
+}
+
+// SetMode 方法用於設定空調運行模式，mode 為 ModeCool 等常量
+// 注意：device 會被就地修改為提交的 payload
+func (c *Client) SetMode(ctx context.Context, device *DeviceInfo, mode int) (*OperateResult, error) {
+	if !c.ExperimentalSettings {
+		return nil, ErrSettingsDisabled
+	}
+	if device.DeviceFan == nil {
+		return nil, fmt.Errorf("%w：設備 %s 沒有空調信息", ErrInvalidSetting, device.DeviceNo)
+	}
+	if !ValidMode(mode) {
+		return nil, fmt.Errorf("%w：未知的運行模式 %d", ErrInvalidSetting, mode)
+	}
+	device.DeviceFan.FanModel = mode
+	return c.submit(ctx, device, CommandAirMode)
+}
+
+// SetWind 方法用於設定空調風速，wind 為 WindAuto 等常量
+// 注意：device 會被就地修改為提交的 payload
+func (c *Client) SetWind(ctx context.Context, device *DeviceInfo, wind int) (*OperateResult, error) {
+	if !c.ExperimentalSettings {
+		return nil, ErrSettingsDisabled
+	}
+	if device.DeviceFan == nil {
+		return nil, fmt.Errorf("%w：設備 %s 沒有空調信息", ErrInvalidSetting, device.DeviceNo)
+	}
+	if !ValidWind(wind) {
+		return nil, fmt.Errorf("%w：未知的風速 %d", ErrInvalidSetting, wind)
+	}
+	device.DeviceFan.WindSpeed = wind
+	return c.submit(ctx, device, CommandAirWind)
+}
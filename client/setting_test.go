@@ -0,0 +1,83 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// TestValidateTemp 測試溫度的校驗，包括非有限值及設備未報告範圍時的默認範圍
+func TestValidateTemp(t *testing.T) {
+	ranged := &client.DeviceFan{MinTemp: 18, MaxTemp: 28}
+	unranged := &client.DeviceFan{}
+	tests := []struct {
+		fan  *client.DeviceFan
+		temp float64
+		ok   bool
+	}{
+		{ranged, 18, true},
+		{ranged, 28, true},
+		{ranged, 17.5, false},
+		{ranged, 29, false},
+		{ranged, math.NaN(), false},
+		{ranged, math.Inf(1), false},
+		{ranged, math.Inf(-1), false},
+		{unranged, client.DefaultMinTemp, true},
+		{unranged, client.DefaultMaxTemp, true},
+		{unranged, 26, true},
+		{unranged, 1e9, false},
+		{unranged, -40, false},
+		{unranged, math.NaN(), false},
+		{unranged, math.Inf(1), false},
+		{&client.DeviceFan{MinTemp: 30, MaxTemp: 16}, 26, true}, // 範圍無效時使用默認範圍
+	}
+	for _, tt := range tests {
+		err := client.ValidateTemp(&client.DeviceInfo{DeviceNo: "D1", DeviceFan: tt.fan}, tt.temp)
+		if tt.ok && err != nil {
+			t.Errorf("ValidateTemp(%g-%g, %g) = %v，應有效", tt.fan.MinTemp, tt.fan.MaxTemp, tt.temp, err)
+		}
+		if !tt.ok && !errors.Is(err, client.ErrInvalidSetting) {
+			t.Errorf("ValidateTemp(%g-%g, %g) = %v，應為 ErrInvalidSetting", tt.fan.MinTemp, tt.fan.MaxTemp, tt.temp, err)
+		}
+	}
+	if err := client.ValidateTemp(&client.DeviceInfo{DeviceNo: "D1"}, 26); !errors.Is(err, client.ErrInvalidSetting) {
+		t.Errorf("沒有空調信息時 ValidateTemp = %v", err)
+	}
+}
+
+// TestSettingsDisabled 測試未啟用 ExperimentalSettings 時不發送未經驗證的設定指令，啟用後才發送
+func TestSettingsDisabled(t *testing.T) {
+	server := mockserver.New("D1")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	c := client.New("t", "x")
+	c.BaseURL = ts.URL
+	ctx := context.Background()
+
+	device := server.Device()
+	for name, set := range map[string]func() (*client.OperateResult, error){
+		"SetTemp": func() (*client.OperateResult, error) { return c.SetTemp(ctx, &device, 26) },
+		"SetMode": func() (*client.OperateResult, error) { return c.SetMode(ctx, &device, client.ModeHeat) },
+		"SetWind": func() (*client.OperateResult, error) { return c.SetWind(ctx, &device, client.WindHigh) },
+	} {
+		if _, err := set(); !errors.Is(err, client.ErrSettingsDisabled) {
+			t.Errorf("%s 返回 %v，應為 ErrSettingsDisabled", name, err)
+		}
+	}
+	if commands := server.Commands(); len(commands) != 0 {
+		t.Fatalf("停用時仍發送了指令 %v", commands)
+	}
+
+	c.ExperimentalSettings = true
+	if _, err := c.Clone().SetMode(ctx, &device, client.ModeHeat); err != nil {
+		t.Fatalf("啟用後 SetMode: %v", err)
+	}
+	if got := server.Device().DeviceFan.FanModel; got != client.ModeHeat {
+		t.Errorf("FanModel = %d，應為 %d", got, client.ModeHeat)
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+// DeviceInfo 結構體用於解析 GET 響應中的 data 部分，以及構建 POST 請求的 payload
+type DeviceInfo struct {
+	ID                string      `json:"id"`
+	ManufactorID      string      `json:"manufactorId"`
+	ModelID           string      `json:"modelId"`
+	GatewayID         string      `json:"gatewayId"`
+	PortID            string      `json:"portId"`
+	CampusID          string      `json:"campusId"`
+	BuildingID        string      `json:"buildingId"`
+	FloorID           string      `json:"floorId"`
+	RoomID            string      `json:"roomId"`
+	DeviceType        int         `json:"deviceType"`
+	DeviceNo          string      `json:"deviceNo"`
+	DeviceIdx         int         `json:"deviceIdx"`
+	Status            int         `json:"status"`
+	StatusReason      string      `json:"statusReason"`
+	Creator           string      `json:"creator"`
+	CreateDate        string      `json:"createDate"`
+	CampusTitle       string      `json:"campusTitle"`
+	BuildingTitle     string      `json:"buildingTitle"`
+	FloorTitle        string      `json:"floorTitle"`
+	RoomNo            string      `json:"roomNo"`
+	ManufactorTitle   string      `json:"manufactorTitle"`
+	ModelTitle        string      `json:"modelTitle"`
+	GatewayNo         string      `json:"gatewayNo"`
+	SNCode            string      `json:"snCode"`
+	PortIdx           int         `json:"portIdx"`
+	DeviceFan         *DeviceFan  `json:"deviceFan"`   // 使用指針，因為可能為 null
+	DeviceMeter       interface{} `json:"deviceMeter"` // 可以是 null
+	DeviceWater       interface{} `json:"deviceWater"` // 可以是 null
+	IsInstallFinish   int         `json:"isInstallFinish"`
+	Position          interface{} `json:"position"` // 可以是 null
+	CommandKey        string      `json:"commandKey"`
+	LastCommunication string      `json:"lastCommunication"`
+	ProcessResult     interface{} `json:"processResult"` // 可以是 null
+	ProcessMsg        interface{} `json:"processMsg"`    // 可以是 null
+	CollectorNo       string      `json:"collectorNo"`
+	Forbidden         int         `json:"forbidden"`
+	Balance           float64     `json:"balance"`
+	NickNames         string      `json:"nickNames"`
+	UpdateDate        string      `json:"updateDate"`
+	MeterUsePower     interface{} `json:"meterUsePower"`         // 可以是 null
+	DeviceGroup       interface{} `json:"deviceGroup"`           // 可以是 null
+	StudentName       string      `json:"studentName,omitempty"` // AirOpen.json 中有，GetdeviceNo.json 中沒有
+}
+
+// DeviceFan 結構體用於解析 DeviceInfo 中的 deviceFan 部分
+type DeviceFan struct {
+	ID             string  `json:"id"`
+	DeviceID       string  `json:"deviceId"`
+	FanType        int     `json:"fanType"`
+	Password       string  `json:"password"`
+	FanStatus      int     `json:"fanStatus"` // 0 為關閉，1 為開啟
+	LockStatus     int     `json:"lockStatus"`
+	TempSetting    float64 `json:"tempSetting"`
+	FanModel       int     `json:"fanModel"`
+	WindSpeed      int     `json:"windSpeed"`
+	MaxTemp        float64 `json:"maxTemp"`
+	MinTemp        float64 `json:"minTemp"`
+	CompensateTemp float64 `json:"compensateTemp"`
+	CompensateFalg int     `json:"compensateFalg"`
+	ReturnTemp     float64 `json:"returnTemp"`
+	CurrentTemp    float64 `json:"currentTemp"`
+	FanStatusOld   int     `json:"fanStatusOld"`
+}
+
+// GetAPIResponse 結構體用於解析 GET 設備信息請求的整個 JSON 響應
+type GetAPIResponse struct {
+	Code int        `json:"code"`
+	Msg  string     `json:"msg"`
+	Data DeviceInfo `json:"data"`
+}
+
+// OperateAPIResponse 結構體用於解析空調開關請求的 JSON 響應
+type OperateAPIResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		MsgID    string `json:"msgId"`
+		DeviceNo string `json:"deviceNo"`
+	} `json:"data"`
+}
+
+// OperateResult 結構體用於返回空調操作的結果
+type OperateResult struct {
+	StatusCode int    `json:"statusCode"` // HTTP 回應狀態碼
+	MsgID      string `json:"msgId"`      // 服務端返回的消息 ID
+	DeviceNo   string `json:"deviceNo"`   // 服務端返回的設備號
+
+	Verification *Verification `json:"verification,omitempty"` // 經 Switch 發送並確認時的結果，否則為 nil
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// VerifyOptions 結構體用於配置開關指令的送達確認
+// operateDevice 返回成功僅表示指令已排入隊列，需輪詢設備狀態才能確認空調是否真正切換
+type VerifyOptions struct {
+	Timeout      time.Duration // 每次發送後等待確認的總時長，0 表示不確認
+	InitialDelay time.Duration // 首次查詢前的等待時間，之後每次加倍
+	MaxDelay     time.Duration // 兩次查詢之間的最長等待時間
+	Resends      int           // 狀態不符時自動重發指令的次數
+}
+
+// DefaultVerifyOptions 返回默認的確認配置：最多等待 20 秒，不自動重發
+func DefaultVerifyOptions() VerifyOptions {
+	return VerifyOptions{
+		Timeout:      20 * time.Second,
+		InitialDelay: time.Second,
+		MaxDelay:     5 * time.Second,
+	}
+}
+
+// Verification 結構體為開關指令的確認結果
+type Verification struct {
+	Confirmed bool `json:"confirmed"` // 設備狀態是否已與指令一致
+	FanStatus int  `json:"fanStatus"` // 最後一次查詢到的 FanStatus，查詢全部失敗時為 -1
+	Polls     int  `json:"polls"`     // 查詢設備狀態的次數
+	Resent    int  `json:"resent"`    // 自動重發指令的次數
+}
+
+// Switch 方法用於獲取最新設備狀態後發送開關指令，並按 c.Verify 確認空調是否真正切換
+// 確認超時不視為錯誤，結果記錄在返回值的 Verification 中；c.Verify.Timeout 為 0 時不確認
+// 開啟時若電費餘額不高於 c.BalanceFloor，則不發送指令並返回 ErrLowBalance
+func (c *Client) Switch(ctx context.Context, deviceNo, command string) (*OperateResult, error) {
+	want := 0
+	if command == CommandAirOpen {
+		want = 1
+	}
+
+	for attempt := 0; ; attempt++ {
+		device, statusCode, err := c.GetDevice(ctx, deviceNo)
+		if err != nil {
+			return &OperateResult{StatusCode: statusCode}, fmt.Errorf("獲取設備信息失敗: %w", err)
+		}
+		if command == CommandAirOpen && c.BalanceFloor > 0 && device.Balance <= c.BalanceFloor {
+			return &OperateResult{StatusCode: statusCode}, fmt.Errorf("%w：當前餘額 %.2f，不高於下限 %.2f，拒絕開啟空調", ErrLowBalance, device.Balance, c.BalanceFloor)
+		}
+		result, err := c.Operate(ctx, device, command)
+		if err != nil || c.Verify.Timeout <= 0 {
+			return result, err
+		}
+
+		v, err := c.waitFanStatus(ctx, deviceNo, want)
+		v.Resent = attempt
+		result.Verification = v
+		if err != nil || v.Confirmed || attempt >= c.Verify.Resends {
+			return result, err
+		}
+	}
+}
+
+// waitFanStatus 方法用於以指數退避輪詢設備狀態，直到 FanStatus 與 want 一致或超時
+// 查詢失敗視為暫時性錯誤並繼續輪詢，僅在 ctx 被取消時返回錯誤
+func (c *Client) waitFanStatus(ctx context.Context, deviceNo string, want int) (*Verification, error) {
+	v := &Verification{FanStatus: -1}
+	deadline := time.Now().Add(c.Verify.Timeout)
+	delay := c.Verify.InitialDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for {
+		wait := min(delay, time.Until(deadline))
+		if wait <= 0 {
+			return v, nil
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return v, ctx.Err()
+		case <-t.C:
+		}
+
+		v.Polls++
+		if device, _, err := c.GetDevice(ctx, deviceNo); err == nil && device.DeviceFan != nil {
+			v.FanStatus = device.DeviceFan.FanStatus
+			if v.FanStatus == want {
+				v.Confirmed = true
+				return v, nil
+			}
+		}
+
+		delay *= 2
+		if c.Verify.MaxDelay > 0 {
+			delay = min(delay, c.Verify.MaxDelay)
+		}
+	}
+}
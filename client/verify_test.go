@@ -0,0 +1,154 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"actool/client"
+)
+
+// flipServer 結構體為測試用的 hatch-api，開關指令在設備狀態被查詢 lag 次後才生效
+type flipServer struct {
+	mu       sync.Mutex
+	balance  float64
+	status   int // 當前的 FanStatus
+	want     int // 最近一次指令要求的 FanStatus
+	lag      int // 指令生效前仍返回舊狀態的查詢次數，小於 0 表示永不生效
+	pending  int
+	gets     int
+	operates int
+}
+
+// ServeHTTP 方法用於處理 getDeviceByNo 與 operateDevice 請求
+func (s *flipServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/device/getDeviceByNo":
+		s.gets++
+		if s.status != s.want && s.lag >= 0 {
+			if s.pending == 0 {
+				s.status = s.want
+			} else {
+				s.pending--
+			}
+		}
+		var resp client.GetAPIResponse
+		resp.Data = client.DeviceInfo{DeviceNo: "D1", Balance: s.balance, DeviceFan: &client.DeviceFan{FanStatus: s.status}}
+		json.NewEncoder(w).Encode(resp)
+	case "/device/operateDevice":
+		var payload client.DeviceInfo
+		json.NewDecoder(r.Body).Decode(&payload)
+		s.operates++
+		s.want = payload.DeviceFan.FanStatus
+		s.pending = s.lag
+		w.Write([]byte(`{"code":0,"msg":"success","data":{"msgId":"1","deviceNo":"D1"}}`))
+	}
+}
+
+// newVerifyClient 函數用於創建連接到 handler 的客戶端，輪詢間隔縮短為毫秒級
+func newVerifyClient(t *testing.T, handler http.Handler, timeout time.Duration, resends int) *client.Client {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	c := client.New("t", "x")
+	c.BaseURL = ts.URL
+	c.Retry.MaxAttempts = 1
+	c.Verify = client.VerifyOptions{Timeout: timeout, InitialDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, Resends: resends}
+	return c
+}
+
+// TestSwitchConfirmedAfterPolls 測試設備狀態在數次查詢後才切換時，Switch 持續輪詢直到確認
+func TestSwitchConfirmedAfterPolls(t *testing.T) {
+	server := &flipServer{balance: 10, lag: 3}
+	c := newVerifyClient(t, server, 5*time.Second, 0)
+
+	result, err := c.Switch(context.Background(), "D1", client.CommandAirOpen)
+	if err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	v := result.Verification
+	if v == nil || !v.Confirmed || v.FanStatus != 1 || v.Polls != 4 || v.Resent != 0 {
+		t.Errorf("確認結果為 %+v，應在第 4 次查詢時確認", v)
+	}
+}
+
+// TestSwitchVerifyTimeout 測試狀態始終未切換時，超時後返回未確認的結果而不是錯誤，並按 Resends 重發指令
+func TestSwitchVerifyTimeout(t *testing.T) {
+	server := &flipServer{balance: 10, lag: -1}
+	c := newVerifyClient(t, server, 30*time.Millisecond, 2)
+
+	start := time.Now()
+	result, err := c.Switch(context.Background(), "D1", client.CommandAirOpen)
+	if err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	v := result.Verification
+	if v == nil || v.Confirmed || v.FanStatus != 0 || v.Polls == 0 || v.Resent != 2 {
+		t.Errorf("確認結果為 %+v，應為未確認且重發 2 次", v)
+	}
+	if server.operates != 3 {
+		t.Errorf("發送了 %d 次指令，應為 3 次", server.operates)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Switch 耗時 %s，未按 Verify.Timeout 停止輪詢", elapsed)
+	}
+}
+
+// TestSwitchVerifyDisabled 測試 Verify.Timeout 為 0 時不輪詢設備狀態
+func TestSwitchVerifyDisabled(t *testing.T) {
+	server := &flipServer{balance: 10, lag: -1}
+	c := newVerifyClient(t, server, 0, 3)
+
+	result, err := c.Switch(context.Background(), "D1", client.CommandAirClose)
+	if err != nil || result.Verification != nil {
+		t.Fatalf("Switch = %+v, %v，應不確認", result, err)
+	}
+	if server.gets != 1 || server.operates != 1 {
+		t.Errorf("查詢 %d 次、發送 %d 次，應各為 1 次", server.gets, server.operates)
+	}
+}
+
+// TestSwitchVerifyCancel 測試輪詢期間 ctx 被取消時返回 ctx 的錯誤
+func TestSwitchVerifyCancel(t *testing.T) {
+	server := &flipServer{balance: 10, lag: -1}
+	c := newVerifyClient(t, server, time.Minute, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err := c.Switch(ctx, "D1", client.CommandAirOpen)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Switch 返回 %v，應為 context.DeadlineExceeded", err)
+	}
+	if result == nil || result.Verification == nil || result.Verification.Confirmed {
+		t.Errorf("確認結果為 %+v", result)
+	}
+}
+
+// TestSwitchBalanceFloor 測試餘額不高於 BalanceFloor 時拒絕開啟空調且不發送指令，關閉不受限制
+func TestSwitchBalanceFloor(t *testing.T) {
+	server := &flipServer{balance: 5, lag: 0}
+	c := newVerifyClient(t, server, 0, 0)
+	c.BalanceFloor = 5
+
+	if _, err := c.Switch(context.Background(), "D1", client.CommandAirOpen); !errors.Is(err, client.ErrLowBalance) {
+		t.Errorf("餘額等於下限時 Switch 返回 %v，應為 ErrLowBalance", err)
+	}
+	if server.operates != 0 {
+		t.Errorf("拒絕開啟時仍發送了 %d 次指令", server.operates)
+	}
+	if _, err := c.Switch(context.Background(), "D1", client.CommandAirClose); err != nil {
+		t.Errorf("餘額過低時關閉空調返回 %v", err)
+	}
+
+	server.balance = 5.01
+	if _, err := c.Switch(context.Background(), "D1", client.CommandAirOpen); err != nil {
+		t.Errorf("餘額高於下限時 Switch 返回 %v", err)
+	}
+}
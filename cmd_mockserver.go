@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"actool/mockserver"
+	"actool/mqtt"
+)
+
+// runMockServer 函數用於啟動本地模擬的 hatch-api 服務 (actool mock-server)
+func runMockServer(args []string) int {
+	fs := flag.NewFlagSet("mock-server", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "監聽地址")
+	deviceNo := fs.String("device", "000000000000", "模擬設備的 deviceNo")
+	token := fs.String("token", "", "若設定，則只接受該 Token")
+	tokenTTL := fs.Duration("token-ttl", 0, "若設定，Token 在簽發後經過該時長即過期，需以 OAuth code 換取新的 Token")
+	balance := fs.Float64("balance", 50, "初始電費餘額")
+	rate := fs.Float64("rate", mockserver.DefaultRatePerHour, "空調開啟時每小時扣除的電費")
+	drop := fs.Float64("drop", 0, "開關指令返回成功但未被執行的機率 (0-1)")
+	compression := fs.String("compress", "", "以 gzip 或 deflate 壓縮響應體")
+	mqttListen := fs.String("mqtt", "", "若設定，則同時在該地址啟動本地 MQTT 代理")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	server := mockserver.New(*deviceNo)
+	server.Token = *token
+	server.TokenTTL = *tokenTTL
+	server.RatePerHour = *rate
+	server.DropRate = *drop
+	server.Compression = *compression
+	server.SetBalance(*balance)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	httpServer := &http.Server{Addr: *listen, Handler: server}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(msgOut, "模擬 hatch-api 服務已啟動：http://%s (設備號 %s)\n", *listen, *deviceNo)
+	fmt.Fprintf(msgOut, "請設定 API_BASE_URL=http://%s 以連接此服務。\n", *listen)
+	fmt.Fprintf(msgOut, "以 OAuth code 換取 Token 的接口路徑為 %s，測試重新授權時請設定 AUTH_PATH=%s。\n", mockserver.AuthPath, mockserver.AuthPath)
+	if *mqttListen != "" {
+		broker := mqtt.NewBroker()
+		l, err := net.Listen("tcp", *mqttListen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "無法啟動 MQTT 代理: %v\n", err)
+			return exitFailure
+		}
+		go broker.Serve(l)
+		defer func() {
+			broker.Close()
+			l.Close()
+		}()
+		fmt.Fprintf(msgOut, "本地 MQTT 代理已啟動：請設定 MQTT_BROKER=tcp://%s\n", *mqttListen)
+	}
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "模擬服務退出: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintln(msgOut, "模擬服務已退出。")
+	return exitOK
+}
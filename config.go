@@ -0,0 +1,888 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"actool/mqtt"
+)
+
+// configKey 結構體描述配置檔案中的一個鍵
+type configKey struct {
+	Env     string // 環境變數及 actool.env 中的鍵名
+	TOML    string // config.toml 中的鍵名
+	Profile bool   // 是否可在 [名稱] 配置段中為單個設備設定
+	Help    string // 說明，用於 config validate 及 config init
+}
+
+// configKeys 為所有支持的配置鍵
+var configKeys = []configKey{
+	{Env: "TOKEN", TOML: "token", Profile: true, Help: "請求頭中的 Token (明文，建議改用以下三項之一)"},
+	{Env: "TOKEN_FILE", TOML: "token_file", Profile: true, Help: "從檔案讀取 Token，相對路徑以配置檔案所在目錄為基準"},
+	{Env: "TOKEN_COMMAND", TOML: "token_command", Profile: true, Help: "以 sh -c (Windows 上為 cmd /c) 執行命令並以其輸出作為 Token，例如 pass show actool"},
+	{Env: "TOKEN_SECRET", TOML: "token_secret", Profile: true, Help: "加密密鑰庫中的密鑰名稱，見 actool secret"},
+	{Env: "DEVICENO", TOML: "device_no", Profile: true, Help: "設備號"},
+	{Env: "STUDENTNAME", TOML: "student_name", Profile: true, Help: "操作空調時提交的學生姓名"},
+	{Env: "API_BASE_URL", TOML: "api_base_url", Profile: true, Help: "hatch-api 地址"},
+	{Env: "VERIFY_TIMEOUT", TOML: "verify_timeout", Help: "開關空調後確認狀態的最長等待時間，0 為不確認"},
+	{Env: "VERIFY_RESEND", TOML: "verify_resend", Help: "狀態未確認時自動重發指令的次數"},
+	{Env: "RETRY_ATTEMPTS", TOML: "retry_attempts", Help: "暫時性錯誤時每個請求最多嘗試的次數"},
+	{Env: "ON_EXIT", TOML: "on_exit", Help: "退出時的處理策略：keep、off 或 off-if-timer"},
+	{Env: "AUTH_PATH", TOML: "auth_path", Help: "以微信 OAuth code 換取 Token 的接口路徑，hatch-api 沒有公開文檔，須從網頁版的請求中確認；未設定時無法重新授權"},
+	{Env: "SERVE_LISTEN", TOML: "serve_listen", Help: "actool serve 的 HTTP 控制接口監聽的地址，默認為 127.0.0.1:8787"},
+	{Env: "SERVE_TOKEN", TOML: "serve_token", Help: "actool serve 要求的 Bearer Token，未設定時每次啟動隨機生成"},
+	{Env: "MQTT_BROKER", TOML: "mqtt_broker", Help: "MQTT 代理地址，例如 tcp://127.0.0.1:1883，設定後 daemon 與 serve 會橋接到 MQTT"},
+	{Env: "MQTT_USERNAME", TOML: "mqtt_username", Help: "連接 MQTT 代理的用戶名"},
+	{Env: "MQTT_PASSWORD", TOML: "mqtt_password", Help: "連接 MQTT 代理的密碼"},
+	{Env: "MQTT_PREFIX", TOML: "mqtt_prefix", Help: "狀態與命令主題的前綴，默認為 actool"},
+	{Env: "MQTT_DISCOVERY_PREFIX", TOML: "mqtt_discovery_prefix", Help: "Home Assistant 自動發現的主題前綴，默認為 homeassistant，none 為不發布"},
+	{Env: "MQTT_INTERVAL", TOML: "mqtt_interval", Help: "發布設備狀態的間隔，默認為 1m"},
+	{Env: "BALANCE_ALERT", TOML: "balance_alert", Help: "電費餘額低於這些值 (以逗號分隔，例如 20,5) 時發送提醒，須運行 daemon 或 serve"},
+	{Env: "BALANCE_FLOOR", TOML: "balance_floor", Help: "電費餘額不高於此值時拒絕開啟空調，daemon 與 serve 會強制關閉空調"},
+	{Env: "BALANCE_INTERVAL", TOML: "balance_interval", Help: "檢查電費餘額的間隔，默認為 10m"},
+	{Env: "NOTIFY_WEBHOOK", TOML: "notify_webhook", Help: "以 POST JSON 的方式接收提醒的地址"},
+	{Env: "NOTIFY_COMMAND", TOML: "notify_command", Help: "發送提醒時以 sh -c (Windows 上為 cmd /c) 執行的命令，提醒內容寫入其標準輸入並以 ACTOOL_ALERT_* 環境變數提供 (actool.env 中須寫作 $$ACTOOL_ALERT_LEVEL，cmd 中為 %ACTOOL_ALERT_LEVEL%)"},
+	{Env: "NOTIFY_EMAIL_TO", TOML: "notify_email_to", Help: "接收提醒郵件的地址，以逗號分隔"},
+	{Env: "NOTIFY_EMAIL_FROM", TOML: "notify_email_from", Help: "提醒郵件的發件人，默認為 actool@<主機名>"},
+	{Env: "NOTIFY_SMTP", TOML: "notify_smtp", Help: "發送提醒郵件的 SMTP 服務地址，默認為 localhost:25，不進行身份驗證"},
+	{Env: "ACON_DURATION", TOML: "acon_duration", Profile: true, Help: "不帶定時參數開啟空調時，默認多久後自動關閉"},
+	{Env: "EXPERIMENTAL_SETTINGS", TOML: "experimental_settings", Help: "設為 true 時才允許設定溫度、模式與風速；這些指令未經真實設備驗證，默認停用"},
+	{Env: "TEMP", TOML: "temp", Profile: true, Help: "開啟空調後默認設定的溫度"},
+	{Env: "MODE", TOML: "mode", Profile: true, Help: "開啟空調後默認設定的模式"},
+	{Env: "WIND", TOML: "wind", Profile: true, Help: "開啟空調後默認設定的風速"},
+}
+
+// lookupConfigKey 函數用於按環境變數名或 TOML 鍵名 (不區分大小寫) 查找配置鍵
+func lookupConfigKey(name string) (configKey, bool) {
+	for _, k := range configKeys {
+		if strings.EqualFold(name, k.Env) || strings.EqualFold(name, k.TOML) {
+			return k, true
+		}
+	}
+	return configKey{}, false
+}
+
+// configIssue 結構體為配置檔案中的一個問題
+type configIssue struct {
+	Path    string
+	Line    int // 0 表示與具體行無關
+	Message string
+}
+
+// String 方法用於返回 "路徑:行號: 訊息" 格式的描述
+func (i configIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", i.Path, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// configFile 結構體為讀取後的配置檔案，鍵名統一為環境變數名
+type configFile struct {
+	Path     string
+	Global   map[string]string
+	Sections []envSection
+	Lines    map[string]int // 鍵所在的行號，鍵為 "段名.鍵名"，全局鍵的段名為空
+	Issues   []configIssue  // 未知的鍵等不影響使用的問題
+}
+
+// envSection 結構體為配置檔案中以 [名稱] 開始的一個設備配置段
+type envSection struct {
+	Name   string
+	Values map[string]string
+}
+
+// set 方法用於記錄一個鍵值，未知的鍵及只能全局設定的鍵記為問題
+func (f *configFile) set(section *envSection, line int, name, value string) error {
+	k, ok := lookupConfigKey(name)
+	if !ok {
+		msg := fmt.Sprintf("未知的配置項 %q", name)
+		if suggestion := suggestConfigKey(name); suggestion != "" {
+			msg += fmt.Sprintf("，是否想輸入 %q？", suggestion)
+		}
+		f.Issues = append(f.Issues, configIssue{Path: f.Path, Line: line, Message: msg})
+		return nil
+	}
+	values, scope := f.Global, ""
+	if section != nil {
+		if !k.Profile {
+			f.Issues = append(f.Issues, configIssue{Path: f.Path, Line: line, Message: fmt.Sprintf("%s 只能在全局配置中設定，已忽略", name)})
+			return nil
+		}
+		values, scope = section.Values, section.Name
+	}
+	if _, dup := values[k.Env]; dup {
+		return fmt.Errorf("%s 重複設定", name)
+	}
+	values[k.Env] = value
+	f.Lines[scope+"."+k.Env] = line
+	return nil
+}
+
+// suggestConfigKey 函數用於為拼寫錯誤的鍵名找出最接近的已知鍵名
+// 距離相同時，小寫的鍵名優先建議 TOML 鍵名，否則優先建議環境變數名
+func suggestConfigKey(name string) string {
+	best, bestDist := "", 3 // 編輯距離超過 2 時不作建議
+	lower := name == strings.ToLower(name)
+	for _, k := range configKeys {
+		candidates := []string{k.Env, k.TOML}
+		if lower {
+			candidates = []string{k.TOML, k.Env}
+		}
+		for _, candidate := range candidates {
+			if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDist {
+				best, bestDist = candidate, d
+			}
+		}
+	}
+	return best
+}
+
+// editDistance 函數用於計算兩個字串的 Levenshtein 編輯距離
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// findConfigFile 函數用於確定配置檔案的路徑，均不存在時返回空字串
+// 優先使用 --config 或 $ACTOOL_CONFIG 指定的檔案，其次為當前目錄的 actool.env，
+// 再次為 $XDG_CONFIG_HOME/actool/config.toml 與 $XDG_CONFIG_HOME/actool/actool.env
+func findConfigFile(explicit string) (string, error) {
+	if explicit == "" {
+		explicit = os.Getenv("ACTOOL_CONFIG")
+	}
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", fmt.Errorf("無法讀取配置檔案 %s: %w", explicit, err)
+		}
+		return explicit, nil
+	}
+
+	candidates := []string{"actool.env"}
+	if dir, err := configDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, "config.toml"), filepath.Join(dir, "actool.env"))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// configDir 函數用於返回配置目錄，優先使用 $XDG_CONFIG_HOME/actool，否則為 ~/.config/actool
+func configDir() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("無法確定用戶主目錄: %w", err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "actool"), nil
+}
+
+// loadConfigFile 函數用於讀取配置檔案，副檔名為 .toml 時按 TOML 解析，否則按 KEY=VALUE 格式解析
+// path 為空時返回空配置
+func loadConfigFile(path string) (*configFile, error) {
+	f := &configFile{Path: path, Global: make(map[string]string), Lines: make(map[string]int)}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取配置檔案 %s: %w", path, err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = f.parseTOML(data)
+	} else {
+		err = f.parseEnv(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.hasPlainToken() {
+		if perm, ok := readableByOthers(path); ok {
+			f.Issues = append(f.Issues, configIssue{Path: path, Message: fmt.Sprintf("包含明文 TOKEN 且可被其他用戶讀取 (權限 %04o)，建議執行 chmod 600 %s，或改用 TOKEN_FILE、TOKEN_COMMAND 或 TOKEN_SECRET", perm, path)})
+		}
+	}
+	return f, nil
+}
+
+// section 方法用於按名稱 (不區分大小寫) 查找配置段，name 為空或不存在時返回 nil
+func (f *configFile) section(name string) *envSection {
+	for i := range f.Sections {
+		if name != "" && strings.EqualFold(f.Sections[i].Name, name) {
+			return &f.Sections[i]
+		}
+	}
+	return nil
+}
+
+// replaceValue 方法用於將配置檔案中 key 所在行的值替換為 value，scope 為配置段名稱，空字串為全局配置
+// 該行等號之前的內容保持不變，寫入後檔案權限為 0600
+func (f *configFile) replaceValue(scope, key, value string) error {
+	values := f.Global
+	if s := f.section(scope); s != nil {
+		scope, values = s.Name, s.Values
+	}
+	line := f.Lines[scope+"."+key]
+	if f.Path == "" || line == 0 {
+		return fmt.Errorf("配置檔案中沒有 %s", key)
+	}
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return fmt.Errorf("無法讀取配置檔案 %s: %w", f.Path, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return fmt.Errorf("%s:%d: 配置檔案已被修改", f.Path, line)
+	}
+	i := strings.IndexByte(lines[line-1], '=')
+	if i < 0 {
+		return fmt.Errorf("%s:%d: 配置檔案已被修改", f.Path, line)
+	}
+	toml := strings.EqualFold(filepath.Ext(f.Path), ".toml")
+	comment := trailingComment(lines[line-1][i+1:], toml)
+	if toml {
+		lines[line-1] = lines[line-1][:i+1] + " " + strconv.Quote(value) + comment
+	} else {
+		lines[line-1] = lines[line-1][:i+1] + formatEnvValue(value) + comment
+	}
+	if err := writeFileAtomic(f.Path, []byte(strings.Join(lines, "\n"))); err != nil {
+		return err
+	}
+	values[key] = value
+	return nil
+}
+
+// trailingComment 函數用於返回值之後的行尾註釋及其前的空白，以便替換值時保留，沒有註釋時返回空字串
+// 未加引號的值在 actool.env 中以 " #" 開始註釋，在 config.toml 中以 "#" 開始註釋
+func trailingComment(raw string, toml bool) string {
+	raw = strings.TrimLeft(raw, " \t")
+	var rest string
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return ""
+		}
+		rest = raw[end+2:]
+	case strings.HasPrefix(raw, `"`):
+		_, after, err := cutQuoted(raw)
+		if err != nil {
+			return ""
+		}
+		rest = after
+	default:
+		sep := " #"
+		if toml {
+			sep = "#"
+		}
+		i := strings.Index(raw, sep)
+		if i < 0 {
+			return ""
+		}
+		rest = raw[i:]
+	}
+	i := strings.IndexByte(rest, '#')
+	if i < 0 {
+		return ""
+	}
+	space := rest[:i]
+	if space == "" {
+		space = " "
+	}
+	return space + rest[i:]
+}
+
+// hasPlainToken 方法用於判斷配置中是否包含明文的 TOKEN
+func (f *configFile) hasPlainToken() bool {
+	if f.Global["TOKEN"] != "" {
+		return true
+	}
+	return slices.ContainsFunc(f.Sections, func(s envSection) bool { return s.Values["TOKEN"] != "" })
+}
+
+// addSection 方法用於開始一個新的設備配置段
+func (f *configFile) addSection(line int, name string) (*envSection, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%s:%d: 配置段名稱不能為空", f.Path, line)
+	}
+	for _, s := range f.Sections {
+		if strings.EqualFold(s.Name, name) {
+			return nil, fmt.Errorf("%s:%d: 設備 [%s] 重複定義", f.Path, line, name)
+		}
+	}
+	f.Sections = append(f.Sections, envSection{Name: name, Values: make(map[string]string)})
+	f.Lines[name+"."] = line
+	return &f.Sections[len(f.Sections)-1], nil
+}
+
+// parseEnv 方法用於解析 KEY=VALUE 格式的配置
+// 支持 "export " 前綴、單引號與雙引號、行尾註釋，以及在值中以 $VAR 或 ${VAR} 引用環境變數或之前定義的鍵
+func (f *configFile) parseEnv(data []byte) error {
+	var section *envSection
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue // 跳過空行或註釋行
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			end := strings.IndexByte(name, ']')
+			if end < 0 {
+				return fmt.Errorf("%s:%d: 配置段缺少 \"]\"", f.Path, lineNo)
+			}
+			if err := checkTrailingComment(name[end+1:]); err != nil {
+				return fmt.Errorf("%s:%d: %w", f.Path, lineNo, err)
+			}
+			name = name[:end]
+			var err error
+			if section, err = f.addSection(lineNo, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			f.Issues = append(f.Issues, configIssue{Path: f.Path, Line: lineNo, Message: "缺少 \"=\"，應為 KEY=VALUE 格式，已忽略"})
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value, err := parseEnvValue(strings.TrimSpace(raw), func(name string) string {
+			if k, ok := lookupConfigKey(name); ok {
+				if section != nil && section.Values[k.Env] != "" {
+					return section.Values[k.Env]
+				}
+				if v, ok := f.Global[k.Env]; ok {
+					return v
+				}
+			}
+			return os.Getenv(name)
+		})
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s 的值無效: %w", f.Path, lineNo, key, err)
+		}
+		if err := f.set(section, lineNo, key, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", f.Path, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("讀取配置檔案時出錯 %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// parseEnvValue 函數用於解析 actool.env 中的值
+// 單引號內的內容原樣保留；雙引號支持 \" \\ \n 等轉義並展開變數；未加引號的值在 " #" 後視為註釋
+func parseEnvValue(raw string, lookup func(string) string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "'"):
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", errors.New("缺少結尾的單引號")
+		}
+		if err := checkTrailingComment(raw[end+2:]); err != nil {
+			return "", err
+		}
+		return raw[1 : end+1], nil
+	case strings.HasPrefix(raw, `"`):
+		quoted, rest, err := cutQuoted(raw)
+		if err != nil {
+			return "", err
+		}
+		if err := checkTrailingComment(rest); err != nil {
+			return "", err
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", fmt.Errorf("雙引號字串格式錯誤")
+		}
+		return expandConfigVars(value, lookup), nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	return expandConfigVars(raw, lookup), nil
+}
+
+// cutQuoted 函數用於切出以雙引號開始的字串，返回含引號的部分及其後的內容
+func cutQuoted(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return s[:i+1], s[i+1:], nil
+		}
+	}
+	return "", "", errors.New("缺少結尾的雙引號")
+}
+
+// checkTrailingComment 函數用於確認引號後只有空白或註釋
+func checkTrailingComment(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("引號後有多餘的內容 %q", rest)
+	}
+	return nil
+}
+
+// expandConfigVars 函數用於展開值中的 $VAR 與 ${VAR}，"$$" 表示字面的 "$"
+func expandConfigVars(s string, lookup func(string) string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(lookup(s[i+2 : i+2+end]))
+			i += end + 2
+		case isVarNameByte(next):
+			j := i + 1
+			for j < len(s) && isVarNameByte(s[j]) {
+				j++
+			}
+			b.WriteString(lookup(s[i+1 : j]))
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// isVarNameByte 函數用於判斷字元是否可出現在變數名中
+func isVarNameByte(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// parseTOML 方法用於解析 config.toml，支持 TOML 的一個子集：
+// 頂層鍵值、以 [名稱] 開始的設備配置段、字串 (基本及字面)、整數、浮點數、布爾值及 # 註釋
+func (f *configFile) parseTOML(data []byte) error {
+	var section *envSection
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			if strings.HasPrefix(name, "[") {
+				return fmt.Errorf("%s:%d: 不支持表數組", f.Path, lineNo)
+			}
+			end := strings.IndexByte(name, ']')
+			if end < 0 {
+				return fmt.Errorf("%s:%d: 表名缺少 \"]\"", f.Path, lineNo)
+			}
+			if err := checkTrailingComment(name[end+1:]); err != nil {
+				return fmt.Errorf("%s:%d: %w", f.Path, lineNo, err)
+			}
+			name = strings.Trim(strings.TrimSpace(name[:end]), `"`)
+			var err error
+			if section, err = f.addSection(lineNo, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			f.Issues = append(f.Issues, configIssue{Path: f.Path, Line: lineNo, Message: "缺少 \"=\"，應為 key = value 格式，已忽略"})
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if !isBareTOMLKey(key) {
+			return fmt.Errorf("%s:%d: 無效的鍵名 %q", f.Path, lineNo, key)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s 的值無效: %w", f.Path, lineNo, key, err)
+		}
+		if err := f.set(section, lineNo, key, value); err != nil {
+			return fmt.Errorf("%s:%d: %w", f.Path, lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("讀取配置檔案時出錯 %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// isBareTOMLKey 函數用於判斷是否為 TOML 的裸鍵 (字母、數字、"_" 與 "-")
+func isBareTOMLKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTOMLValue 函數用於解析 TOML 的標量值，統一返回字串形式
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", errors.New("缺少值")
+	case strings.HasPrefix(raw, `"""`), strings.HasPrefix(raw, "'''"):
+		return "", errors.New("不支持多行字串")
+	case strings.HasPrefix(raw, `"`):
+		quoted, rest, err := cutQuoted(raw)
+		if err != nil {
+			return "", err
+		}
+		if err := checkTrailingComment(rest); err != nil {
+			return "", err
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", errors.New("字串格式錯誤")
+		}
+		return value, nil
+	case strings.HasPrefix(raw, "'"):
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", errors.New("缺少結尾的單引號")
+		}
+		if err := checkTrailingComment(raw[end+2:]); err != nil {
+			return "", err
+		}
+		return raw[1 : end+1], nil
+	case strings.HasPrefix(raw, "["), strings.HasPrefix(raw, "{"):
+		return "", errors.New("不支持數組及內聯表")
+	}
+
+	if i := strings.IndexByte(raw, '#'); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	if raw == "true" || raw == "false" {
+		return raw, nil
+	}
+	number := strings.ReplaceAll(raw, "_", "")
+	if _, err := strconv.ParseFloat(number, 64); err == nil {
+		return number, nil
+	}
+	return "", fmt.Errorf("%q 不是字串、數字或布爾值，字串須加引號", raw)
+}
+
+// validateConfig 函數用於檢查配置內容，返回所有問題 (包括讀取時發現的未知鍵)
+// 環境變數中已設定的必填項不視為缺失
+func validateConfig(f *configFile) []configIssue {
+	issues := append([]configIssue(nil), f.Issues...)
+	report := func(scope, key, format string, args ...any) {
+		issues = append(issues, configIssue{Path: f.Path, Line: f.Lines[scope+"."+key], Message: fmt.Sprintf(format, args...)})
+	}
+	check := func(scope string, values map[string]string) {
+		for _, k := range configKeys {
+			value, ok := values[k.Env]
+			if !ok {
+				continue
+			}
+			if err := validateConfigValue(k.Env, value); err != nil {
+				report(scope, k.Env, "%s: %v", k.Env, err)
+			}
+		}
+	}
+	check("", f.Global)
+	for _, s := range f.Sections {
+		check(s.Name, s.Values)
+	}
+
+	// 必填項：每個設備最終都須有 DEVICENO、TOKEN 與 STUDENTNAME
+	globalValue := func(key string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return f.Global[key]
+	}
+	if globalValue("DEVICENO") == "" && len(f.Sections) == 0 {
+		report("", "", "缺少 DEVICENO，請設定全局 DEVICENO 或添加 [名稱] 設備配置段")
+	}
+	for _, keys := range [][]string{tokenKeys, {"STUDENTNAME"}} {
+		if slices.ContainsFunc(keys, func(k string) bool { return globalValue(k) != "" }) {
+			continue
+		}
+		name := keys[0]
+		if len(keys) > 1 {
+			name += " (或 " + strings.Join(keys[1:], "、") + ")"
+		}
+		if len(f.Sections) == 0 {
+			report("", "", "缺少 %s", name)
+			continue
+		}
+		for _, s := range f.Sections {
+			if !slices.ContainsFunc(keys, func(k string) bool { return s.Values[k] != "" }) {
+				report(s.Name, "", "設備 [%s] 缺少 %s，且未設定全局配置", s.Name, name)
+			}
+		}
+	}
+
+	// 同一處只能設定一個 Token 來源
+	conflict := func(scope string, values map[string]string) {
+		var set []string
+		for _, k := range tokenKeys {
+			if values[k] != "" {
+				set = append(set, k)
+			}
+		}
+		if len(set) > 1 {
+			report(scope, set[1], "%s 只能設定其中一個", strings.Join(set, " 與 "))
+		}
+	}
+	conflict("", f.Global)
+	for _, s := range f.Sections {
+		conflict(s.Name, s.Values)
+	}
+
+	// 默認設定須同時啟用 EXPERIMENTAL_SETTINGS 才會生效
+	if enabled, _ := strconv.ParseBool(globalValue("EXPERIMENTAL_SETTINGS")); !enabled {
+		for _, scope := range append([]envSection{{Values: f.Global}}, f.Sections...) {
+			for _, key := range []string{"TEMP", "MODE", "WIND"} {
+				if scope.Values[key] != "" {
+					report(scope.Name, key, "%s 須同時設定 EXPERIMENTAL_SETTINGS = true 才會生效", key)
+				}
+			}
+		}
+	}
+	for _, s := range f.Sections {
+		if s.Values["DEVICENO"] == "" {
+			report(s.Name, "", "設備 [%s] 缺少 DEVICENO", s.Name)
+		} else if !profileNamePattern.MatchString(strings.ToLower(s.Name)) {
+			report(s.Name, "", "無效的設備名稱 [%s]，只能包含字母、數字、\"-\" 與 \"_\"", s.Name)
+		}
+	}
+	return issues
+}
+
+// validateConfigValue 函數用於檢查單個配置值的格式
+func validateConfigValue(key, value string) error {
+	switch key {
+	case "TOKEN", "TOKEN_FILE", "TOKEN_COMMAND", "TOKEN_SECRET", "DEVICENO", "STUDENTNAME":
+		if value == "" {
+			return errors.New("不能為空")
+		}
+	case "API_BASE_URL":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%q 不是有效的 http(s) 地址", value)
+		}
+	case "VERIFY_TIMEOUT":
+		if value != "0" {
+			_, err := parseFlexibleDuration(value)
+			return err
+		}
+	case "VERIFY_RESEND":
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("%q 不是非負整數", value)
+		}
+	case "RETRY_ATTEMPTS":
+		if n, err := strconv.Atoi(value); err != nil || n < 1 {
+			return fmt.Errorf("%q 不是正整數", value)
+		}
+	case "AUTH_PATH":
+		if !strings.HasPrefix(value, "/") {
+			return fmt.Errorf("%q 須以 \"/\" 開始", value)
+		}
+	case "SERVE_LISTEN":
+		if _, port, err := net.SplitHostPort(value); err != nil || port == "" {
+			return fmt.Errorf("%q 不是有效的監聽地址，請使用 127.0.0.1:8787 等格式", value)
+		}
+	case "SERVE_TOKEN":
+		if len(value) < minServeTokenLength {
+			return fmt.Errorf("長度至少為 %d 個字符", minServeTokenLength)
+		}
+	case "MQTT_BROKER":
+		_, _, err := mqtt.ParseBrokerURL(value)
+		return err
+	case "MQTT_PREFIX", "MQTT_DISCOVERY_PREFIX":
+		if strings.HasSuffix(value, "/") {
+			return fmt.Errorf("%q 不能以 \"/\" 結尾", value)
+		}
+		return mqtt.ValidTopic(value)
+	case "MQTT_INTERVAL":
+		d, err := parseFlexibleDuration(value)
+		if err == nil && d < 5*time.Second {
+			return fmt.Errorf("%q 過短，至少為 5s", value)
+		}
+		return err
+	case "BALANCE_ALERT":
+		thresholds, err := parseThresholds(value)
+		if err == nil && len(thresholds) == 0 {
+			return errors.New("不能為空")
+		}
+		return err
+	case "BALANCE_FLOOR":
+		if v, err := strconv.ParseFloat(value, 64); err != nil || !(v >= 0) || math.IsInf(v, 0) {
+			return fmt.Errorf("%q 不是非負數", value)
+		}
+	case "BALANCE_INTERVAL":
+		d, err := parseFlexibleDuration(value)
+		if err == nil && d < time.Minute {
+			return fmt.Errorf("%q 過短，至少為 1m", value)
+		}
+		return err
+	case "NOTIFY_WEBHOOK":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%q 不是有效的 http(s) 地址", value)
+		}
+	case "NOTIFY_SMTP":
+		if _, port, err := net.SplitHostPort(value); err != nil || port == "" {
+			return fmt.Errorf("%q 不是有效的地址，請使用 localhost:25 等格式", value)
+		}
+	case "NOTIFY_EMAIL_FROM":
+		if _, err := mail.ParseAddress(value); err != nil {
+			return fmt.Errorf("%q 不是有效的郵件地址", value)
+		}
+	case "NOTIFY_EMAIL_TO":
+		if len(splitList(value)) == 0 {
+			return errors.New("不能為空")
+		}
+		for _, addr := range splitList(value) {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("%q 不是有效的郵件地址", addr)
+			}
+		}
+	case "ON_EXIT":
+		_, err := parseExitPolicy(value)
+		return err
+	case "ACON_DURATION":
+		_, err := parseFlexibleDuration(value)
+		return err
+	case "EXPERIMENTAL_SETTINGS":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%q 不是 true 或 false", value)
+		}
+	case "TEMP", "MODE", "WIND":
+		return acSetting{Kind: strings.ToLower(key), Value: value}.validate()
+	}
+	return nil
+}
+
+// runConfigCommand 函數用於處理 actool config 子命令，返回退出碼
+func runConfigCommand(explicit, baseURL string, args []string) int {
+	if len(args) == 0 {
+		printConfigUsage()
+		return exitUsage
+	}
+	if args[0] == "init" {
+		if len(args) > 1 {
+			explicit = args[1]
+		}
+		return runConfigInit(cmp.Or(explicit, os.Getenv("ACTOOL_CONFIG")), baseURL)
+	}
+
+	path, err := findConfigFile(explicit)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+	switch args[0] {
+	case "path":
+		if path == "" {
+			fmt.Fprintln(msgOut, "未找到配置檔案。")
+			return exitConfig
+		}
+		fmt.Fprintln(msgOut, path)
+	case "validate":
+		if len(args) > 1 {
+			path = args[1]
+		}
+		if path == "" {
+			fmt.Fprintln(msgOut, "錯誤: 未找到配置檔案，可使用 actool config validate <路徑> 指定。")
+			return exitConfig
+		}
+		f, err := loadConfigFile(path)
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return exitConfig
+		}
+		issues := validateConfig(f)
+		for _, issue := range issues {
+			fmt.Fprintln(msgOut, issue)
+		}
+		if len(issues) > 0 {
+			fmt.Fprintf(msgOut, "發現 %d 個問題。\n", len(issues))
+			return exitConfig
+		}
+		fmt.Fprintf(msgOut, "%s: 配置有效，共 %d 個設備。\n", path, countConfigDevices(f))
+	default:
+		printConfigUsage()
+		return exitUsage
+	}
+	return exitOK
+}
+
+// countConfigDevices 函數用於計算配置中定義的設備數量
+func countConfigDevices(f *configFile) int {
+	n := len(f.Sections)
+	if f.Global["DEVICENO"] != "" || os.Getenv("DEVICENO") != "" {
+		n++
+	}
+	return n
+}
+
+// printConfigUsage 函數用於輸出 config 子命令的用法
+func printConfigUsage() {
+	fmt.Fprintln(msgOut, "用法：actool config init [路徑]      以互動方式生成配置檔案 (默認為 $XDG_CONFIG_HOME/actool/config.toml)")
+	fmt.Fprintln(msgOut, "      actool config validate [路徑]  檢查配置檔案中的拼寫錯誤、無效值及缺失的必填項")
+	fmt.Fprintln(msgOut, "      actool config path             顯示正在使用的配置檔案")
+	fmt.Fprintln(msgOut, "支持的配置項 (actool.env 中使用大寫名稱，config.toml 中使用小寫名稱)：")
+	for _, k := range configKeys {
+		scope := "全局"
+		if k.Profile {
+			scope = "全局或設備"
+		}
+		fmt.Fprintf(msgOut, "  %-15s %-15s %-10s %s\n", k.Env, k.TOML, scope, k.Help)
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadConfigString 函數用於將 content 寫入臨時目錄中名為 name 的檔案並讀取
+func loadConfigString(t *testing.T, name, content string) (*configFile, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return loadConfigFile(path)
+}
+
+// issueMessages 函數用於返回配置問題的 "行號: 訊息" 列表
+func issueMessages(issues []configIssue) []string {
+	var messages []string
+	for _, issue := range issues {
+		messages = append(messages, strings.TrimPrefix(issue.String(), issue.Path+":"))
+	}
+	return messages
+}
+
+// TestParseEnvValue 測試 actool.env 中值的引號、轉義、行尾註釋及變數展開
+func TestParseEnvValue(t *testing.T) {
+	lookup := func(name string) string {
+		return map[string]string{"A": "1", "B": "2"}[name]
+	}
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{raw: "", want: ""},
+		{raw: "plain", want: "plain"},
+		{raw: "a b # 註釋", want: "a b"},
+		{raw: "a#b", want: "a#b"},
+		{raw: "$A-${B}", want: "1-2"},
+		{raw: `'$A # 不是註釋'`, want: "$A # 不是註釋"},
+		{raw: `'x' # 註釋`, want: "x"},
+		{raw: `"a \"b\"\n$A"`, want: "a \"b\"\n1"},
+		{raw: `"x" # 註釋`, want: "x"},
+		{raw: `"#"`, want: "#"},
+		{raw: `'x' y`, wantErr: `引號後有多餘的內容 "y"`},
+		{raw: `"x" y`, wantErr: `引號後有多餘的內容 "y"`},
+		{raw: `'x`, wantErr: "缺少結尾的單引號"},
+		{raw: `"x`, wantErr: "缺少結尾的雙引號"},
+		{raw: `"x\"`, wantErr: "缺少結尾的雙引號"},
+		{raw: `"\q"`, wantErr: "雙引號字串格式錯誤"},
+	}
+	for _, tt := range tests {
+		got, err := parseEnvValue(tt.raw, lookup)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseEnvValue(%q) = %q, %v，錯誤應包含 %q", tt.raw, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseEnvValue(%q) = %q, %v，應為 %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+// TestExpandConfigVars 測試 $VAR、${VAR} 及 "$$" 的展開，不構成變數引用的 "$" 原樣保留
+func TestExpandConfigVars(t *testing.T) {
+	lookup := func(name string) string {
+		return map[string]string{"A": "1", "A_B": "2", "X Y": "3"}[name]
+	}
+	tests := []struct {
+		s, want string
+	}{
+		{"no vars", "no vars"},
+		{"$A", "1"},
+		{"$A_B", "2"},
+		{"$A-B", "1-B"},
+		{"${A}_B", "1_B"},
+		{"${X Y}", "3"},
+		{"$UNKNOWN", ""},
+		{"$$A", "$A"},
+		{"$$$A", "$1"},
+		{"cost $", "cost $"},
+		{"$-", "$-"},
+		{"${A", "${A"},
+	}
+	for _, tt := range tests {
+		if got := expandConfigVars(tt.s, lookup); got != tt.want {
+			t.Errorf("expandConfigVars(%q) = %q，應為 %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestLoadConfigEnv 測試 actool.env 的解析：export 前綴、變數引用、設備配置段，以及記為問題而不中斷讀取的行
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("ACTOOL_TEST_HOST", "example.com")
+	f, err := loadConfigString(t, "actool.env", `# 全局配置
+export DEVICENO=D1
+STUDENTNAME="${DEVICENO}-name"
+API_BASE_URL=http://$ACTOOL_TEST_HOST/api
+this line has no equals sign
+
+[room2] # 第二個房間
+DEVICENO = D2 # 註釋
+STUDENTNAME=$STUDENTNAME+
+TOKEN_FILE='/tmp/$x'
+SERVE_LISTEN=:1
+TOKNE=abc
+`)
+	if err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+	wantGlobal := map[string]string{"DEVICENO": "D1", "STUDENTNAME": "D1-name", "API_BASE_URL": "http://example.com/api"}
+	for k, v := range wantGlobal {
+		if f.Global[k] != v {
+			t.Errorf("全局 %s = %q，應為 %q", k, f.Global[k], v)
+		}
+	}
+	if len(f.Sections) != 1 || f.Sections[0].Name != "room2" {
+		t.Fatalf("配置段為 %+v", f.Sections)
+	}
+	wantSection := map[string]string{"DEVICENO": "D2", "STUDENTNAME": "D1-name+", "TOKEN_FILE": "/tmp/$x"}
+	for k, v := range wantSection {
+		if got := f.Sections[0].Values[k]; got != v {
+			t.Errorf("[room2] %s = %q，應為 %q", k, got, v)
+		}
+	}
+	if _, ok := f.Sections[0].Values["SERVE_LISTEN"]; ok {
+		t.Error("只能全局設定的鍵不應出現在配置段中")
+	}
+	if f.Lines["room2.DEVICENO"] != 8 || f.Lines[".API_BASE_URL"] != 4 {
+		t.Errorf("行號為 %v", f.Lines)
+	}
+
+	want := []string{
+		`5: 缺少 "="，應為 KEY=VALUE 格式，已忽略`,
+		"11: SERVE_LISTEN 只能在全局配置中設定，已忽略",
+		`12: 未知的配置項 "TOKNE"，是否想輸入 "TOKEN"？`,
+	}
+	if got := issueMessages(f.Issues); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("問題為 %q，應為 %q", got, want)
+	}
+}
+
+// TestLoadConfigTOML 測試 config.toml 支持的子集：各類標量、註釋及帶引號的表名
+func TestLoadConfigTOML(t *testing.T) {
+	f, err := loadConfigString(t, "config.toml", `device_no = "D1" # 註釋
+student_name = 'C:\name'
+verify_resend = 1_0
+balance_floor = 2.5
+experimental_settings = true
+token_file
+["room 2"] # 註釋
+device_no = "D\u0032"
+`)
+	if err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+	wantGlobal := map[string]string{"DEVICENO": "D1", "STUDENTNAME": `C:\name`, "VERIFY_RESEND": "10", "BALANCE_FLOOR": "2.5", "EXPERIMENTAL_SETTINGS": "true"}
+	for k, v := range wantGlobal {
+		if f.Global[k] != v {
+			t.Errorf("全局 %s = %q，應為 %q", k, f.Global[k], v)
+		}
+	}
+	if len(f.Sections) != 1 || f.Sections[0].Name != "room 2" || f.Sections[0].Values["DEVICENO"] != "D2" {
+		t.Errorf("配置段為 %+v", f.Sections)
+	}
+	want := []string{`6: 缺少 "="，應為 key = value 格式，已忽略`}
+	if got := issueMessages(f.Issues); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("問題為 %q，應為 %q", got, want)
+	}
+}
+
+// TestLoadConfigErrors 測試導致讀取失敗的格式錯誤及其錯誤訊息
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, content, wantErr string
+	}{
+		{"actool.env", "[room", `:1: 配置段缺少 "]"`},
+		{"actool.env", "[ ]", ":1: 配置段名稱不能為空"},
+		{"actool.env", "[room] x", `:1: 引號後有多餘的內容 "x"`},
+		{"actool.env", "[a]\nDEVICENO=1\n[A]", ":3: 設備 [A] 重複定義"},
+		{"actool.env", "DEVICENO=1\ndeviceno=2", ":2: deviceno 重複設定"},
+		{"actool.env", "DEVICENO='D1", ":1: DEVICENO 的值無效: 缺少結尾的單引號"},
+		{"config.toml", "[[device]]", ":1: 不支持表數組"},
+		{"config.toml", "[device", `:1: 表名缺少 "]"`},
+		{"config.toml", "[device] x", `:1: 引號後有多餘的內容 "x"`},
+		{"config.toml", "device.no = 1", `:1: 無效的鍵名 "device.no"`},
+		{"config.toml", "device_no =", ":1: device_no 的值無效: 缺少值"},
+		{"config.toml", `device_no = """D1"""`, "不支持多行字串"},
+		{"config.toml", "device_no = [1]", "不支持數組及內聯表"},
+		{"config.toml", "device_no = {a = 1}", "不支持數組及內聯表"},
+		{"config.toml", "device_no = D1", `"D1" 不是字串、數字或布爾值，字串須加引號`},
+		{"config.toml", `device_no = "D1" x`, `引號後有多餘的內容 "x"`},
+		{"config.toml", `device_no = "\q"`, "字串格式錯誤"},
+	}
+	for _, tt := range tests {
+		_, err := loadConfigString(t, tt.name, tt.content)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s %q: 錯誤為 %v，應包含 %q", tt.name, tt.content, err, tt.wantErr)
+		}
+	}
+}
+
+// TestSuggestConfigKey 測試拼寫錯誤的鍵名按編輯距離給出建議，距離超過 2 時不建議
+func TestSuggestConfigKey(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"DEVICEN0", "DEVICENO"},
+		{"TOKNE", "TOKEN"},
+		{"studnet_name", "student_name"},
+		{"verify_timout", "verify_timeout"},
+		{"VERIFY_TIMOUT", "VERIFY_TIMEOUT"},
+		{"COMPLETELY_UNKNOWN", ""},
+		{"X", ""},
+	}
+	for _, tt := range tests {
+		if got := suggestConfigKey(tt.name); got != tt.want {
+			t.Errorf("suggestConfigKey(%q) = %q，應為 %q", tt.name, got, tt.want)
+		}
+	}
+
+	distances := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"token", "tokne", 2},
+		{"same", "same", 0},
+	}
+	for _, tt := range distances {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d，應為 %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestValidateConfig 測試配置檢查發現的問題：無效值、缺失的必填項、Token 來源衝突及未啟用的默認設定
+func TestValidateConfig(t *testing.T) {
+	for _, key := range []string{"DEVICENO", "STUDENTNAME", "TOKEN", "TOKEN_FILE", "TOKEN_COMMAND", "TOKEN_SECRET", "EXPERIMENTAL_SETTINGS"} {
+		t.Setenv(key, "")
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"完整", "DEVICENO=D1\nSTUDENTNAME=n\nTOKEN_SECRET=t", nil},
+		{"缺少必填項", "VERIFY_RESEND=1", []string{
+			"缺少 DEVICENO，請設定全局 DEVICENO 或添加 [名稱] 設備配置段",
+			"缺少 TOKEN (或 TOKEN_FILE、TOKEN_COMMAND、TOKEN_SECRET)",
+			"缺少 STUDENTNAME",
+		}},
+		{"無效值", "DEVICENO=D1\nSTUDENTNAME=n\nTOKEN_SECRET=t\nVERIFY_RESEND=-1\nSERVE_TOKEN=short", []string{
+			`4: VERIFY_RESEND: "-1" 不是非負整數`,
+			"5: SERVE_TOKEN: 長度至少為 16 個字符",
+		}},
+		{"Token 來源衝突", "DEVICENO=D1\nSTUDENTNAME=n\nTOKEN=a\nTOKEN_FILE=b", []string{
+			"4: TOKEN 與 TOKEN_FILE 只能設定其中一個",
+		}},
+		{"設備配置段", "STUDENTNAME=n\nTOKEN_SECRET=t\n[a]\nDEVICENO=D1\n[b c]\nDEVICENO=D2\n[d]\nTOKEN=x", []string{
+			"5: 無效的設備名稱 [b c]，只能包含字母、數字、\"-\" 與 \"_\"",
+			"7: 設備 [d] 缺少 DEVICENO",
+		}},
+		{"未啟用的默認設定", "DEVICENO=D1\nSTUDENTNAME=n\nTOKEN_SECRET=t\nMODE=cool\n[a]\nDEVICENO=D2\nWIND=high", []string{
+			"4: MODE 須同時設定 EXPERIMENTAL_SETTINGS = true 才會生效",
+			"7: WIND 須同時設定 EXPERIMENTAL_SETTINGS = true 才會生效",
+		}},
+		{"已啟用的默認設定", "DEVICENO=D1\nSTUDENTNAME=n\nTOKEN_SECRET=t\nMODE=cool\nEXPERIMENTAL_SETTINGS=true", nil},
+		{"缺少等號", "DEVICENO=D1\nSTUDENTNAME=n\nTOKEN_SECRET=t\nTOKEN_SECRET", []string{
+			`4: 缺少 "="，應為 KEY=VALUE 格式，已忽略`,
+		}},
+	}
+	for _, tt := range tests {
+		f, err := loadConfigString(t, "actool.env", tt.content)
+		if err != nil {
+			t.Fatalf("%s: loadConfigFile: %v", tt.name, err)
+		}
+		got := issueMessages(validateConfig(f))
+		for i, msg := range got {
+			got[i] = strings.TrimPrefix(msg, " ")
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: 問題為 %q，應為 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestReplaceValue 測試替換配置值時保留鍵名前的內容及行尾註釋
+func TestReplaceValue(t *testing.T) {
+	tests := []struct {
+		name, content, scope, value, want string
+	}{
+		{"actool.env", "TOKEN=old # 舊的 Token\nDEVICENO=D1", "", "new", "TOKEN=new # 舊的 Token\nDEVICENO=D1"},
+		{"actool.env", "export TOKEN='old'   # 註釋", "", "a b", "export TOKEN='a b'   # 註釋"},
+		{"actool.env", `TOKEN="o#ld"`, "", "new", "TOKEN=new"},
+		{"actool.env", "TOKEN=o#ld", "", "new", "TOKEN=new"},
+		{"actool.env", "DEVICENO=D1\n[lab] # 實驗室\nTOKEN = old #c", "lab", "new", "DEVICENO=D1\n[lab] # 實驗室\nTOKEN =new #c"},
+		{"config.toml", `token = "old" # 註釋`, "", "new", `token = "new" # 註釋`},
+		{"config.toml", "token = 'old'", "", "new", `token = "new"`},
+		{"config.toml", "[lab] # 實驗室\ntoken = \"old\"# c", "lab", "new", "[lab] # 實驗室\ntoken = \"new\" # c"},
+	}
+	for _, tt := range tests {
+		f, err := loadConfigString(t, tt.name, tt.content)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.content, err)
+		}
+		if err := f.replaceValue(tt.scope, "TOKEN", tt.value); err != nil {
+			t.Fatalf("%q: replaceValue: %v", tt.content, err)
+		}
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("替換 %q 後為 %q，應為 %q", tt.content, data, tt.want)
+		}
+		reloaded, err := loadConfigFile(f.Path)
+		if err != nil {
+			t.Fatalf("重新讀取 %q: %v", data, err)
+		}
+		values := reloaded.Global
+		if s := reloaded.section(tt.scope); s != nil {
+			values = s.Values
+		}
+		if values["TOKEN"] != tt.value {
+			t.Errorf("重新讀取 %q 後 TOKEN = %q，應為 %q", data, values["TOKEN"], tt.value)
+		}
+	}
+}
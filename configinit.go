@@ -0,0 +1,284 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"actool/client"
+)
+
+// configPrompter 結構體用於在終端中逐行讀取用戶輸入
+type configPrompter struct {
+	ctx    context.Context // 被取消 (Ctrl-C) 時正在等待的輸入立即返回
+	in     io.Reader
+	hidden func(prompt string) (string, error) // 讀取不回顯的輸入，為 nil 時從 in 讀取
+}
+
+// ask 方法用於提示並讀取一行輸入，直接按 Enter 時返回 def
+// 輸入結束 (Ctrl-D) 或被中斷 (Ctrl-C) 時返回錯誤
+func (p *configPrompter) ask(label, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(msgOut, "%s [%s]: ", label, def)
+	} else {
+		fmt.Fprintf(msgOut, "%s: ", label)
+	}
+	line, err := readLineContext(p.ctx, p.in)
+	if err != nil {
+		fmt.Fprintln(msgOut)
+		if err == io.EOF {
+			return "", errors.New("輸入已結束")
+		}
+		return "", err
+	}
+	return cmp.Or(strings.TrimSpace(line), def), nil
+}
+
+// askRequired 方法用於讀取不能為空的輸入
+func (p *configPrompter) askRequired(label, def string) (string, error) {
+	for {
+		value, err := p.ask(label, def)
+		if err != nil || value != "" {
+			return value, err
+		}
+		fmt.Fprintln(msgOut, "此項不能為空。")
+	}
+}
+
+// askSecret 方法用於讀取不能為空且不回顯的輸入，已有值時只提示 "(已設定)" 而不顯示其內容，直接按 Enter 時保留
+func (p *configPrompter) askSecret(label, def string) (string, error) {
+	prompt := label + ": "
+	if def != "" {
+		prompt = label + " (已設定，直接按 Enter 保留): "
+	}
+	for {
+		var line string
+		var err error
+		if p.hidden != nil {
+			line, err = p.hidden(prompt)
+		} else {
+			fmt.Fprint(msgOut, prompt)
+			line, err = readLineContext(p.ctx, p.in)
+		}
+		if err != nil {
+			if err == io.EOF {
+				fmt.Fprintln(msgOut)
+				return "", errors.New("輸入已結束")
+			}
+			return "", err
+		}
+		if value := cmp.Or(strings.TrimSpace(line), def); value != "" {
+			return value, nil
+		}
+		fmt.Fprintln(msgOut, "此項不能為空。")
+	}
+}
+
+// confirm 方法用於詢問是或否，直接按 Enter 時返回 def
+func (p *configPrompter) confirm(label string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := p.ask(label+" ("+hint+")", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// runConfigInit 函數用於以互動方式生成配置檔案，寫入前先向 hatch-api 查詢設備以確認 Token 與設備號有效
+// path 為空時寫入 $XDG_CONFIG_HOME/actool/config.toml，baseURL 為空時使用默認地址
+func runConfigInit(path, baseURL string) int {
+	if path == "" {
+		dir, err := configDir()
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return exitConfig
+		}
+		path = filepath.Join(dir, "config.toml")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	p := &configPrompter{ctx: ctx, in: os.Stdin, hidden: readHiddenLine}
+	fmt.Fprintln(msgOut, "===================================")
+	fmt.Fprintln(msgOut, "         ACtool 配置嚮導            ")
+	fmt.Fprintln(msgOut, "===================================")
+	fmt.Fprintf(msgOut, "配置將寫入 %s\n", path)
+	if _, err := os.Stat(path); err == nil {
+		overwrite, err := p.confirm("檔案已存在，是否覆蓋？", false)
+		if err != nil {
+			return configInitAborted(err)
+		}
+		if !overwrite {
+			fmt.Fprintln(msgOut, "已取消。")
+			return exitFailure
+		}
+	}
+	fmt.Fprintln(msgOut, "Token 與設備號可在微信中打開宿舍空調頁面後，從請求頭的 Token 及頁面中的設備號獲取。")
+
+	token, deviceNo, studentName := os.Getenv("TOKEN"), os.Getenv("DEVICENO"), os.Getenv("STUDENTNAME")
+	for {
+		var err error
+		if token, err = p.askSecret("Token", token); err != nil {
+			return configInitAborted(err)
+		}
+		if deviceNo, err = p.askRequired("設備號", deviceNo); err != nil {
+			return configInitAborted(err)
+		}
+		if studentName, err = p.askRequired("學生姓名", studentName); err != nil {
+			return configInitAborted(err)
+		}
+
+		c := client.New(token, studentName)
+		if baseURL != "" {
+			c.BaseURL = baseURL
+		}
+		fmt.Fprintln(msgOut, "\n正在向 hatch-api 查詢設備...")
+		device, _, err := c.GetDevice(ctx, deviceNo)
+		if err == nil {
+			fmt.Fprintln(msgOut, "已找到設備：")
+			fmt.Fprintf(msgOut, "校   區：%s\n", device.CampusTitle)
+			fmt.Fprintf(msgOut, "宿舍樓號：%s\n", device.BuildingTitle)
+			fmt.Fprintf(msgOut, "樓   層：%s\n", device.FloorTitle)
+			fmt.Fprintf(msgOut, "門牌號：%s\n", device.RoomNo)
+			fmt.Fprintf(msgOut, "電費信息：%.2f\n", device.Balance)
+			break
+		}
+		if ctx.Err() != nil {
+			return configInitAborted(ctx.Err())
+		}
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		switch {
+		case errors.Is(err, client.ErrUnauthorized):
+			fmt.Fprintln(msgOut, "Token 無效或已過期，請重新獲取。")
+		case errors.Is(err, client.ErrDeviceOffline):
+			fmt.Fprintln(msgOut, "請確認設備號是否正確。")
+		}
+		retry, perr := p.confirm("是否重新輸入？", true)
+		if perr != nil {
+			return configInitAborted(perr)
+		}
+		if !retry {
+			fmt.Fprintln(msgOut, "已取消，配置檔案未寫入。")
+			return exitCode(err)
+		}
+	}
+
+	ok, err := p.confirm("\n以上是你的宿舍嗎？確認後寫入配置", true)
+	if err != nil {
+		return configInitAborted(err)
+	}
+	if !ok {
+		fmt.Fprintln(msgOut, "已取消，配置檔案未寫入。")
+		return exitFailure
+	}
+	type entry struct{ key, value string }
+	tokenEntry := entry{"TOKEN", token}
+	useVault, err := p.confirm("是否將 Token 保存到以口令加密的密鑰庫，而不是明文寫入配置？", true)
+	if err != nil {
+		return configInitAborted(err)
+	}
+	if useVault {
+		name, err := storeTokenInVault(token)
+		if errors.Is(err, context.Canceled) {
+			return configInitAborted(err)
+		}
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return exitConfig
+		}
+		tokenEntry = entry{"TOKEN_SECRET", name}
+	}
+	values := []entry{
+		tokenEntry,
+		{"DEVICENO", deviceNo},
+		{"STUDENTNAME", studentName},
+	}
+	if baseURL != "" {
+		values = append(values, entry{"API_BASE_URL", baseURL})
+	}
+
+	var b strings.Builder
+	b.WriteString("# 由 actool config init 生成，可執行 actool config validate 檢查\n")
+	toml := strings.EqualFold(filepath.Ext(path), ".toml")
+	for _, v := range values {
+		if toml {
+			k, _ := lookupConfigKey(v.key)
+			fmt.Fprintf(&b, "%s = %s\n", k.TOML, strconv.Quote(v.value))
+		} else {
+			fmt.Fprintf(&b, "%s=%s\n", v.key, formatEnvValue(v.value))
+		}
+	}
+	if err := writeFileAtomic(path, []byte(b.String())); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+	fmt.Fprintf(msgOut, "配置已寫入 %s (僅當前用戶可讀寫)。\n", path)
+	if cwdConfig, _ := findConfigFile(""); cwdConfig != "" && cwdConfig != path {
+		fmt.Fprintf(msgOut, "注意: %s 優先於新配置，可刪除它或使用 --config %s。\n", cwdConfig, path)
+	}
+	return exitOK
+}
+
+// storeTokenInVault 函數用於將 Token 以 "token" 為名保存到密鑰庫，返回密鑰名稱
+func storeTokenInVault(token string) (string, error) {
+	const name = "token"
+	path, err := defaultVaultPath()
+	if err != nil {
+		return "", err
+	}
+	passphrase, err := readPassphrase(!vaultExists(path))
+	if err != nil {
+		return "", err
+	}
+	v, err := openVault(path, passphrase)
+	if err != nil {
+		return "", err
+	}
+	v.secrets[name] = token
+	if err := v.save(); err != nil {
+		return "", fmt.Errorf("保存密鑰庫失敗: %w", err)
+	}
+	fmt.Fprintf(msgOut, "Token 已加密保存到 %s。\n", path)
+	return name, nil
+}
+
+// configInitAborted 函數用於在輸入結束或被中斷時結束配置嚮導
+func configInitAborted(err error) int {
+	fmt.Fprintf(msgOut, "已取消，配置檔案未寫入: %v\n", err)
+	if errors.Is(err, context.Canceled) {
+		return exitInterrupted
+	}
+	return exitFailure
+}
+
+// formatEnvValue 函數用於格式化 actool.env 中的值，含空白、"#"、"$" 或引號時加上引號
+func formatEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t#$'\"\\") {
+		return value
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'" // 單引號內的內容不展開變數
+	}
+	return strconv.Quote(strings.ReplaceAll(value, "$", "$$"))
+}
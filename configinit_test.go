@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestConfigPrompterCancel 測試等待輸入時 ctx 被取消 (Ctrl-C) 會立即返回
+func TestConfigPrompterCancel(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &configPrompter{ctx: ctx, in: r}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := p.ask("Token", ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("ask 返回 %v，應為 context.Canceled", err)
+	}
+	if code := configInitAborted(context.Canceled); code != exitInterrupted {
+		t.Errorf("中斷時的退出碼為 %d", code)
+	}
+}
+
+// TestConfigPrompterAsk 測試讀取輸入、默認值及輸入結束
+func TestConfigPrompterAsk(t *testing.T) {
+	p := &configPrompter{ctx: context.Background(), in: strings.NewReader("  abc \r\n\ny\n")}
+	if v, err := p.ask("Token", ""); err != nil || v != "abc" {
+		t.Errorf("ask = %q, %v", v, err)
+	}
+	if v, err := p.ask("設備號", "D1"); err != nil || v != "D1" {
+		t.Errorf("直接按 Enter 時 ask = %q, %v，應為默認值", v, err)
+	}
+	if ok, err := p.confirm("確認", false); err != nil || !ok {
+		t.Errorf("confirm = %v, %v", ok, err)
+	}
+	if _, err := p.ask("姓名", ""); err == nil {
+		t.Error("輸入結束時應返回錯誤")
+	}
+}
+
+// TestConfigPrompterAskSecret 測試讀取 Token 時不在提示中顯示已有的值，直接按 Enter 時保留
+func TestConfigPrompterAskSecret(t *testing.T) {
+	var prompts []string
+	inputs := []string{"", "", " new-token "}
+	p := &configPrompter{ctx: context.Background(), hidden: func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		line := inputs[0]
+		inputs = inputs[1:]
+		return line, nil
+	}}
+	if v, err := p.askSecret("Token", "old-secret-token"); err != nil || v != "old-secret-token" {
+		t.Errorf("直接按 Enter 時 askSecret = %q, %v，應保留原值", v, err)
+	}
+	if v, err := p.askSecret("Token", ""); err != nil || v != "new-token" {
+		t.Errorf("askSecret = %q, %v，應為 new-token", v, err)
+	}
+	if len(prompts) != 3 || !strings.Contains(prompts[0], "已設定") {
+		t.Errorf("提示為 %q", prompts)
+	}
+	for _, prompt := range prompts {
+		if strings.Contains(prompt, "old-secret-token") {
+			t.Errorf("提示 %q 顯示了已有的 Token", prompt)
+		}
+	}
+
+	p = &configPrompter{ctx: context.Background(), hidden: func(string) (string, error) { return "", io.EOF }}
+	if _, err := p.askSecret("Token", ""); err == nil {
+		t.Error("輸入結束時應返回錯誤")
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField 結構體描述 cron 表達式中一個字段的取值範圍及可用的名稱
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+	cycle    int // 取值的循環週期，非零時允許首尾相接的範圍，例如星期字段的 "fri-mon"
+}
+
+var (
+	cronMinute = cronField{name: "分鐘", min: 0, max: 59}
+	cronHour   = cronField{name: "小時", min: 0, max: 23}
+	cronDom    = cronField{name: "日期", min: 1, max: 31}
+	cronMonth  = cronField{name: "月份", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 星期字段中 0 與 7 均表示星期日
+	cronDow = cronField{name: "星期", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}, cycle: 7}
+)
+
+// cronSpec 結構體為解析後的五段式 cron 表達式 (分 時 日 月 星期)
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // 以位元表示允許的取值
+	domAny, dowAny                bool   // 日期/星期字段是否為 "*"
+}
+
+// parseCron 函數用於解析五段式 cron 表達式，例如 "0 23 * * *" 或 "30 14 * * mon-fri"
+func parseCron(expr string) (*cronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表達式須包含 5 個字段 (分 時 日 月 星期)，收到 %d 個", len(fields))
+	}
+
+	spec := &cronSpec{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	if spec.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if spec.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if spec.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if spec.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if spec.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1 // 7 與 0 同為星期日
+	}
+	return spec, nil
+}
+
+// parse 方法用於解析單個字段，支持 "*"、列表 ","、範圍 "-" 及步長 "/"
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(strings.ToLower(field), ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s字段的步長無效：%q", f.name, part)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = f.value(loStr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(hiStr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max // "5/15" 表示從 5 開始每 15 個單位
+			}
+			if lo > hi {
+				if f.cycle == 0 {
+					return 0, fmt.Errorf("%s字段的範圍無效：%q", f.name, part)
+				}
+				hi += f.cycle // "fri-mon" 表示星期五至下一個星期一
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bit := v
+			if bit > f.max {
+				bit -= f.cycle
+			}
+			bits |= 1 << uint(bit)
+		}
+	}
+	return bits, nil
+}
+
+// value 方法用於解析字段中的單個數值或名稱
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[s]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s字段的取值無效：%q (範圍 %d-%d)", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next 方法用於計算 after 之後 (不含) 下一次符合表達式的時間點
+// 在五年內找不到符合的時間 (例如 2 月 30 日) 時返回零值
+func (c *cronSpec) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 方法用於判斷日期是否符合
+// 與傳統 cron 相同：日期與星期字段均有限制時，滿足其一即可
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// parseScheduleSpec 函數用於將用戶輸入的週期規則轉換為 cron 表達式
+// 支持五段式 cron 表達式，或 "HH:MM [星期]" 的簡寫，例如 "13:00 mon-fri"、"23:00 weekends"
+// 命令行中的 cron 表達式通常以引號作為一個參數傳入，因此先合併再重新按空白分割
+func parseScheduleSpec(args []string) (string, error) {
+	args = strings.Fields(strings.Join(args, " "))
+	if len(args) == 5 {
+		expr := strings.Join(args, " ")
+		if _, err := parseCron(expr); err != nil {
+			return "", err
+		}
+		return expr, nil
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("請使用 \"HH:MM [星期]\" 或五段式 cron 表達式")
+	}
+
+	clock, err := time.Parse("15:04", args[0])
+	if err != nil {
+		return "", fmt.Errorf("時間格式無效：%q，請使用 HH:MM 格式", args[0])
+	}
+	days := "*"
+	if len(args) == 2 {
+		switch strings.ToLower(args[1]) {
+		case "daily", "everyday":
+			days = "*"
+		case "weekdays":
+			days = "mon-fri"
+		case "weekends":
+			days = "sat,sun"
+		default:
+			days = strings.ToLower(args[1])
+		}
+	}
+
+	expr := fmt.Sprintf("%d %d * * %s", clock.Minute(), clock.Hour(), days)
+	if _, err := parseCron(expr); err != nil {
+		return "", err
+	}
+	return expr, nil
+}
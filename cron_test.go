@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// bits 函數用於將取值列表轉換為 cronSpec 中的位元表示
+func bits(values ...int) uint64 {
+	var b uint64
+	for _, v := range values {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+// TestCronFieldParse 測試單個字段的列表、範圍、步長及名稱
+func TestCronFieldParse(t *testing.T) {
+	tests := []struct {
+		field cronField
+		expr  string
+		want  uint64
+	}{
+		{cronMinute, "0", bits(0)},
+		{cronMinute, "0,30", bits(0, 30)},
+		{cronMinute, "*/15", bits(0, 15, 30, 45)},
+		{cronMinute, "5/20", bits(5, 25, 45)},
+		{cronMinute, "10-20/5", bits(10, 15, 20)},
+		{cronHour, "22-23,0-1", bits(22, 23, 0, 1)},
+		{cronDom, "*", bits(1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31)},
+		{cronMonth, "JAN,jun-aug", bits(1, 6, 7, 8)},
+		{cronDow, "mon-fri", bits(1, 2, 3, 4, 5)},
+		{cronDow, "sat,sun", bits(6, 0)},
+		{cronDow, "7", bits(7)},
+		{cronDow, "fri-mon", bits(5, 6, 7, 1)},
+		{cronDow, "sat-sun", bits(6, 7)},
+		{cronDow, "5-0", bits(5, 6, 7)},
+		{cronDow, "thu-tue/2", bits(4, 6, 1)},
+	}
+	for _, tt := range tests {
+		got, err := tt.field.parse(tt.expr)
+		if err != nil || got != tt.want {
+			t.Errorf("%s字段 parse(%q) = %b, %v，應為 %b", tt.field.name, tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+// TestParseCronInvalid 測試無效的 cron 表達式及其錯誤訊息
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"0 23 * *", "須包含 5 個字段"},
+		{"0 23 * * * *", "收到 6 個"},
+		{"60 23 * * *", `分鐘字段的取值無效："60" (範圍 0-59)`},
+		{"0 24 * * *", "小時字段的取值無效"},
+		{"0 23 0 * *", "日期字段的取值無效"},
+		{"0 23 * 13 *", "月份字段的取值無效"},
+		{"0 23 * foo *", `月份字段的取值無效："foo"`},
+		{"0 23 * * 8", "星期字段的取值無效"},
+		{"0 23 * * 7-8", "星期字段的取值無效"},
+		{"0 23-1 * * *", `小時字段的範圍無效："23-1"`},
+		{"0 23 31-1 * *", "日期字段的範圍無效"},
+		{"*/0 23 * * *", `分鐘字段的步長無效："*/0"`},
+		{"*/x 23 * * *", "分鐘字段的步長無效"},
+		{"0 -1 * * *", "小時字段的取值無效"},
+		{"0 23 ,1 * *", "日期字段的取值無效"},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseCron(%q) = %v，應包含 %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+// TestCronDayMatches 測試日期與星期字段的組合：均有限制時滿足其一即可，其中一個為 "*" 時按另一個判斷
+func TestCronDayMatches(t *testing.T) {
+	// 2025-07-01 為星期二，2025-07-04 為星期五，2025-07-15 為星期二
+	tue1 := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	fri4 := time.Date(2025, 7, 4, 0, 0, 0, 0, time.UTC)
+	tue15 := time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)
+	sun6 := time.Date(2025, 7, 6, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		day  time.Time
+		want bool
+	}{
+		{"0 0 * * *", tue1, true},
+		{"0 0 1 * *", tue1, true},
+		{"0 0 1 * *", tue15, false}, // 星期為 "*" 時只按日期判斷
+		{"0 0 * * fri", fri4, true},
+		{"0 0 * * fri", tue1, false}, // 日期為 "*" 時只按星期判斷
+		{"0 0 15 * fri", fri4, true}, // 均有限制時滿足星期即可
+		{"0 0 15 * fri", tue15, true},
+		{"0 0 15 * fri", tue1, false},
+		{"0 0 * * 7", sun6, true}, // 7 與 0 同為星期日
+		{"0 0 * * 0", sun6, true},
+		{"0 0 * * fri-sun", sun6, true}, // 首尾相接的星期範圍
+		{"0 0 * * fri-sun", fri4, true},
+		{"0 0 * * fri-sun", tue1, false},
+		{"0 0 * * sat-mon", sun6, true},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := spec.dayMatches(tt.day); got != tt.want {
+			t.Errorf("%q dayMatches(%s) = %v，應為 %v", tt.expr, tt.day.Format("2006-01-02 Mon"), got, tt.want)
+		}
+	}
+}
+
+// TestCronNext 測試下一次觸發時間的計算，包括跨日、跨月、跨年及永遠不會觸發的表達式
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"0 23 * * *", at("2025-07-01 12:00"), at("2025-07-01 23:00")},
+		{"0 23 * * *", at("2025-07-01 23:00"), at("2025-07-02 23:00")}, // 不含 after 本身
+		{"0 23 * * *", at("2025-07-01 22:59").Add(30 * time.Second), at("2025-07-01 23:00")},
+		{"*/15 * * * *", at("2025-07-01 12:07"), at("2025-07-01 12:15")},
+		{"30 14 * * mon-fri", at("2025-07-04 15:00"), at("2025-07-07 14:30")}, // 星期五之後為下星期一
+		{"0 8 1 * *", at("2025-07-15 00:00"), at("2025-08-01 08:00")},
+		{"0 0 1 jan *", at("2025-07-01 00:00"), at("2026-01-01 00:00")},
+		{"0 0 29 feb *", at("2025-03-01 00:00"), at("2028-02-29 00:00")},
+		{"0 0 31 * *", at("2025-04-01 00:00"), at("2025-05-31 00:00")},
+		{"0 0 30 feb *", at("2025-01-01 00:00"), time.Time{}},
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := spec.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%q Next(%s) = %s，應為 %s", tt.expr, tt.after, got, tt.want)
+		}
+	}
+}
+
+// TestParseScheduleSpec 測試週期規則的輸入形式，cron 表達式可以分開或以一個參數 (加引號) 傳入
+func TestParseScheduleSpec(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{args: []string{"0 23 * * *"}, want: "0 23 * * *"},
+		{args: []string{"0", "23", "*", "*", "*"}, want: "0 23 * * *"},
+		{args: []string{" 30  14 * * mon-fri "}, want: "30 14 * * mon-fri"},
+		{args: []string{"13:00"}, want: "0 13 * * *"},
+		{args: []string{"13:05", "mon-fri"}, want: "5 13 * * mon-fri"},
+		{args: []string{"23:00 weekends"}, want: "0 23 * * sat,sun"},
+		{args: []string{"07:30", "weekdays"}, want: "30 7 * * mon-fri"},
+		{args: []string{"07:30", "daily"}, want: "30 7 * * *"},
+		{args: []string{"0 23 * * * *"}, wantErr: "請使用"},
+		{args: nil, wantErr: "請使用"},
+		{args: []string{"25:00"}, wantErr: `時間格式無效："25:00"`},
+		{args: []string{"13:00", "someday"}, wantErr: "星期字段的取值無效"},
+		{args: []string{"0 24 * * *"}, wantErr: "小時字段的取值無效"},
+	}
+	for _, tt := range tests {
+		got, err := parseScheduleSpec(tt.args)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseScheduleSpec(%q) = %q, %v，錯誤應包含 %q", tt.args, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseScheduleSpec(%q) = %q, %v，應為 %q", tt.args, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"actool/client"
+)
+
+// defaultSocketPath 函數用於返回守護進程控制 socket 的默認路徑
+// 優先使用 $ACTOOL_SOCKET，其次為 $XDG_RUNTIME_DIR/actool.sock，否則放在狀態目錄下
+func defaultSocketPath() (string, error) {
+	if path := os.Getenv("ACTOOL_SOCKET"); path != "" {
+		return path, nil
+	}
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "actool.sock"), nil
+	}
+	statePath, err := defaultTimerStorePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(statePath), "actool.sock"), nil
+}
+
+// daemonStatus 結構體為 GET /status 的響應
+type daemonStatus struct {
+	StatusCode int                `json:"statusCode"`
+	Device     *client.DeviceInfo `json:"device"`
+	Actions    []scheduledAction  `json:"actions"`
+}
+
+// daemonOperateResponse 結構體為 POST /ac/on 與 POST /ac/off 的響應
+// 延遲開啟時 Result 為空，僅 Scheduled 有值
+type daemonOperateResponse struct {
+	Result         *client.OperateResult `json:"result,omitempty"`
+	Scheduled      *scheduledAction      `json:"scheduled,omitempty"`
+	Actions        []scheduledAction     `json:"actions"`
+	TimerCancelled bool                  `json:"timerCancelled,omitempty"`
+}
+
+// daemonScheduleRequest 結構體為 POST /schedules 的請求
+type daemonScheduleRequest struct {
+	Command string `json:"command"`
+	Spec    string `json:"spec"`
+}
+
+// daemonTimerRequest 結構體為 POST /timers 的請求，不立即操作空調
+// Command 為 AirClose 時於 In 或 At 指定的時間關閉空調 (覆蓋原有的自動關閉定時器)；
+// 為 AirOpen 時於指定時間開啟，可用 For 指定開啟後持續多久自動關閉
+type daemonTimerRequest struct {
+	Command string `json:"command"`
+	In      string `json:"in,omitempty"`
+	At      string `json:"at,omitempty"`
+	For     string `json:"for,omitempty"`
+}
+
+// daemonError 結構體為錯誤響應
+type daemonError struct {
+	Message    string `json:"error"`
+	StatusCode int    `json:"statusCode,omitempty"` // hatch-api 的 HTTP 回應狀態碼
+	Kind       string `json:"kind,omitempty"`       // 錯誤類別，客戶端據此決定退出碼
+
+	httpStatus int // 控制接口本身的 HTTP 狀態碼，僅客戶端使用
+}
+
+// Error 方法用於實現 error 接口
+func (e *daemonError) Error() string {
+	return e.Message
+}
+
+// daemonAPI 結構體用於處理控制 socket 上的請求，持有設備與定時器狀態
+type daemonAPI struct {
+	client  *client.Client
+	profile deviceProfile // 開啟空調時補充設備的默認持續時間及設定
+	timers  *scheduler
+}
+
+// Handler 方法用於返回控制接口的路由
+func (api *daemonAPI) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", api.handleStatus)
+	mux.HandleFunc("POST /ac/on", api.handleOn)
+	mux.HandleFunc("POST /ac/off", api.handleOff)
+	mux.HandleFunc("POST /ac/settings", api.handleSetting)
+	mux.HandleFunc("GET /timers", api.handleListTimers)
+	mux.HandleFunc("POST /timers", api.handleAddTimer)
+	mux.HandleFunc("DELETE /timers", api.handleCancelTimer)
+	mux.HandleFunc("DELETE /timers/{id}", api.handleCancelAction)
+	mux.HandleFunc("GET /schedules", api.handleListSchedules)
+	mux.HandleFunc("POST /schedules", api.handleAddSchedule)
+	mux.HandleFunc("DELETE /schedules/{id}", api.handleRemoveSchedule)
+	return mux
+}
+
+// handleStatus 方法用於返回設備信息及定時器狀態
+func (api *daemonAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
+	deviceInfo, statusCode, err := api.client.GetDevice(r.Context(), api.profile.DeviceNo)
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadGateway, daemonError{Message: err.Error(), StatusCode: statusCode, Kind: errorKind(err)})
+		return
+	}
+	writeDaemonJSON(w, http.StatusOK, daemonStatus{StatusCode: statusCode, Device: deviceInfo, Actions: api.timers.Actions()})
+}
+
+// handleOn 方法用於開啟空調，並按請求設定定時器
+func (api *daemonAPI) handleOn(w http.ResponseWriter, r *http.Request) {
+	var req aconRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: "請求格式錯誤: " + err.Error(), Kind: kindUsage})
+			return
+		}
+	}
+	p, err := api.profile.withDefaults(req).plan(time.Now())
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: err.Error(), Kind: kindUsage})
+		return
+	}
+
+	result, action, err := startAC(r.Context(), api.client, api.profile.DeviceNo, api.timers, p)
+	if err != nil {
+		writeDaemonOperateError(w, result, err)
+		return
+	}
+	writeDaemonJSON(w, http.StatusOK, daemonOperateResponse{Result: result, Scheduled: action, Actions: api.timers.Actions()})
+}
+
+// handleOff 方法用於關閉空調並取消自動關閉的定時器
+func (api *daemonAPI) handleOff(w http.ResponseWriter, r *http.Request) {
+	result, err := api.client.Switch(r.Context(), api.profile.DeviceNo, client.CommandAirClose)
+	if err != nil {
+		writeDaemonOperateError(w, result, err)
+		return
+	}
+	cancelled := api.timers.CancelAutoOff()
+	writeDaemonJSON(w, http.StatusOK, daemonOperateResponse{Result: result, Actions: api.timers.Actions(), TimerCancelled: cancelled})
+}
+
+// handleSetting 方法用於調整空調的溫度、模式或風速
+func (api *daemonAPI) handleSetting(w http.ResponseWriter, r *http.Request) {
+	var req acSetting
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: "請求格式錯誤: " + err.Error(), Kind: kindUsage})
+		return
+	}
+	result, err := req.apply(r.Context(), api.client, api.profile.DeviceNo)
+	if errors.Is(err, client.ErrInvalidSetting) {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: err.Error(), Kind: kindUsage})
+		return
+	}
+	if errors.Is(err, client.ErrSettingsDisabled) {
+		writeDaemonJSON(w, http.StatusForbidden, daemonError{Message: err.Error(), Kind: kindConfig})
+		return
+	}
+	if err != nil {
+		writeDaemonOperateError(w, result, err)
+		return
+	}
+	writeDaemonJSON(w, http.StatusOK, daemonOperateResponse{Result: result, Actions: api.timers.Actions()})
+}
+
+// handleListTimers 方法用於返回所有待執行的定時動作
+func (api *daemonAPI) handleListTimers(w http.ResponseWriter, r *http.Request) {
+	writeDaemonJSON(w, http.StatusOK, api.timers.Actions())
+}
+
+// handleAddTimer 方法用於設定定時開啟或關閉空調
+func (api *daemonAPI) handleAddTimer(w http.ResponseWriter, r *http.Request) {
+	var req daemonTimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: "請求格式錯誤: " + err.Error(), Kind: kindUsage})
+		return
+	}
+	action, err := scheduleTimer(api.timers, req, time.Now())
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: err.Error(), Kind: kindUsage})
+		return
+	}
+	writeDaemonJSON(w, http.StatusCreated, action)
+}
+
+// scheduleTimer 函數用於按請求排程定時開啟或關閉空調
+func scheduleTimer(timers *scheduler, req daemonTimerRequest, now time.Time) (scheduledAction, error) {
+	if req.In == "" && req.At == "" {
+		return scheduledAction{}, errors.New("須指定 in 或 at")
+	}
+	switch req.Command {
+	case client.CommandAirOpen:
+		p, err := aconRequest{In: req.In, At: req.At, For: req.For}.plan(now)
+		if err != nil {
+			return scheduledAction{}, err
+		}
+		return scheduleStart(timers, p), nil
+	case client.CommandAirClose:
+		if req.For != "" {
+			return scheduledAction{}, errors.New("關閉空調的定時器不支持 for")
+		}
+		p, err := aconRequest{In: req.In, At: req.At}.plan(now)
+		if err != nil {
+			return scheduledAction{}, err
+		}
+		description := "指定時間 " + req.At
+		if req.In != "" {
+			description = formatDuration(p.StartAt.Sub(now))
+		}
+		return timers.SetAutoOff(p.StartAt, description), nil
+	}
+	return scheduledAction{}, fmt.Errorf("無效的操作指令：%s", req.Command)
+}
+
+// handleCancelTimer 方法用於取消所有定時動作，不操作空調
+func (api *daemonAPI) handleCancelTimer(w http.ResponseWriter, r *http.Request) {
+	cancelled := api.timers.CancelAll()
+	writeDaemonJSON(w, http.StatusOK, daemonOperateResponse{Actions: api.timers.Actions(), TimerCancelled: cancelled})
+}
+
+// handleCancelAction 方法用於取消指定編號的定時動作
+func (api *daemonAPI) handleCancelAction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: "無效的定時器編號", Kind: kindUsage})
+		return
+	}
+	if !api.timers.CancelAction(id) {
+		writeDaemonJSON(w, http.StatusNotFound, daemonError{Message: fmt.Sprintf("定時器 #%d 不存在", id), Kind: kindUsage})
+		return
+	}
+	writeDaemonJSON(w, http.StatusOK, daemonOperateResponse{Actions: api.timers.Actions(), TimerCancelled: true})
+}
+
+// handleListSchedules 方法用於返回所有定期任務
+func (api *daemonAPI) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	writeDaemonJSON(w, http.StatusOK, api.timers.Rules())
+}
+
+// handleAddSchedule 方法用於添加定期任務
+func (api *daemonAPI) handleAddSchedule(w http.ResponseWriter, r *http.Request) {
+	var req daemonScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: "請求格式錯誤: " + err.Error(), Kind: kindUsage})
+		return
+	}
+	rule, err := api.timers.AddRule(req.Command, req.Spec)
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: err.Error(), Kind: kindUsage})
+		return
+	}
+	writeDaemonJSON(w, http.StatusCreated, rule)
+}
+
+// handleRemoveSchedule 方法用於刪除定期任務
+func (api *daemonAPI) handleRemoveSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeDaemonJSON(w, http.StatusBadRequest, daemonError{Message: "無效的任務編號", Kind: kindUsage})
+		return
+	}
+	if err := api.timers.RemoveRule(id); err != nil {
+		writeDaemonJSON(w, http.StatusNotFound, daemonError{Message: err.Error(), Kind: kindUsage})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDaemonOperateError 函數用於輸出空調操作失敗的響應，餘額過低而被拒絕時為 409
+func writeDaemonOperateError(w http.ResponseWriter, result *client.OperateResult, err error) {
+	resp := daemonError{Message: err.Error(), Kind: errorKind(err)}
+	if result != nil {
+		resp.StatusCode = result.StatusCode
+	}
+	status := http.StatusBadGateway
+	if errors.Is(err, client.ErrLowBalance) {
+		status = http.StatusConflict
+	}
+	writeDaemonJSON(w, status, resp)
+}
+
+// writeDaemonJSON 函數用於輸出 JSON 響應
+func writeDaemonJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// listenUnixSocket 函數用於在 path 上監聽 Unix domain socket，權限為 0600
+// 若 socket 檔案殘留但無進程監聽，則先將其刪除
+func listenUnixSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("無法創建目錄 %s: %w", filepath.Dir(path), err)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("守護進程已在運行 (%s)", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("無法刪除殘留的 socket %s: %w", path, err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("無法監聽 socket %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("設置 socket 權限失敗: %w", err)
+	}
+	return listener, nil
+}
+
+// runDaemon 函數用於以無終端的守護進程模式運行 (actool daemon)
+// 守護進程擁有定時器，並在控制 socket 上接受命令行的請求，直到收到 SIGINT/SIGTERM
+func runDaemon(ctx context.Context, s *deviceSession, socketPath string, onExit exitPolicy) error {
+	listener, err := listenUnixSocket(socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	api := &daemonAPI{client: s.client, profile: s.profile, timers: s.timers}
+	server := &http.Server{Handler: api.Handler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(msgOut, "守護進程已啟動，設備號 %s，控制 socket：%s\n", s.profile.DeviceNo, socketPath)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("守護進程異常退出: %w", err)
+	}
+	applyExitPolicy(s.client, s.profile.DeviceNo, s.timers, onExit)
+	fmt.Fprintln(msgOut, "守護進程已退出。")
+	return nil
+}
+
+// runDaemonCommand 函數用於解析 actool daemon 的參數並運行守護進程
+// mqttCfg 不為 nil 時同時運行 MQTT 橋接，balanceCfg 設定了閾值或下限時同時監控電費餘額
+func runDaemonCommand(ctx context.Context, devices *deviceSet, profile deviceProfile, socketPath string, onExit exitPolicy, mqttCfg *mqttConfig, balanceCfg *balanceWatchConfig, args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.StringVar(&socketPath, "socket", socketPath, "控制 socket 的路徑")
+	onExitValue := fs.String("on-exit", string(onExit), "退出時的處理策略：keep、off 或 off-if-timer")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	onExit, err := parseExitPolicy(*onExitValue)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitUsage
+	}
+	if socketPath == "" {
+		fmt.Fprintln(msgOut, "錯誤: 無法確定控制 socket 的路徑，請使用 --socket 或 ACTOOL_SOCKET 指定。")
+		return exitConfig
+	}
+
+	s := devices.open(ctx, profile)
+	stopBridge := startMQTTBridge(ctx, mqttCfg, s)
+	stopWatcher := startBalanceWatcher(ctx, balanceCfg, s)
+	err = runDaemon(ctx, s, socketPath, onExit)
+	stopWatcher()
+	stopBridge()
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitCode(err)
+	}
+	return exitOK
+}
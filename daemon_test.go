@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// TestDaemonOnDefaults 測試經由控制接口開啟空調時套用設備配置的默認持續時間及設定
+func TestDaemonOnDefaults(t *testing.T) {
+	server := mockserver.New("D1")
+	s := newMockSession(t, server)
+	s.profile.AconDuration = "30m"
+	s.profile.Temp = "26"
+	s.client.ExperimentalSettings = true
+	api := &daemonAPI{client: s.client, profile: s.profile, timers: s.timers}
+
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ac/on", strings.NewReader("{}")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /ac/on 返回 %d: %s", rec.Code, rec.Body)
+	}
+	var resp daemonOperateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Actions) != 1 || resp.Actions[0].Command != client.CommandAirClose {
+		t.Fatalf("定時動作為 %+v，應有一個自動關閉動作", resp.Actions)
+	}
+	if d := time.Until(resp.Actions[0].At); d < 29*time.Minute || d > 30*time.Minute {
+		t.Errorf("自動關閉時間在 %s 後，應約為 30 分鐘", d)
+	}
+	if !hasCommand(server, client.CommandAirTemp) {
+		t.Error("未套用默認溫度")
+	}
+}
+
+// TestDaemonSettingDisabled 測試未啟用 EXPERIMENTAL_SETTINGS 時拒絕設定指令，不發送到 hatch-api
+func TestDaemonSettingDisabled(t *testing.T) {
+	server := mockserver.New("D1")
+	s := newMockSession(t, server)
+	api := &daemonAPI{client: s.client, profile: s.profile, timers: s.timers}
+
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ac/settings", strings.NewReader(`{"kind":"mode","value":"heat"}`)))
+	var resp daemonError
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusForbidden || resp.Kind != kindConfig {
+		t.Errorf("POST /ac/settings 返回 %d %+v，應為 403 config", rec.Code, resp)
+	}
+	if len(server.Commands()) != 0 {
+		t.Errorf("設定指令被發送: %v", server.Commands())
+	}
+}
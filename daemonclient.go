@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// daemonClient 結構體用於通過控制 socket 與正在運行的守護進程通信
+type daemonClient struct {
+	http *http.Client
+}
+
+// dialDaemon 函數用於連接守護進程，守護進程未運行時返回 nil
+func dialDaemon(socketPath string) *daemonClient {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+
+	// 不設置整體超時：守護進程可能需要較長時間確認指令送達，由調用方的 ctx (Ctrl-C) 控制取消
+	dialer := &net.Dialer{Timeout: time.Second}
+	return &daemonClient{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// call 方法用於發送請求並將 JSON 響應解析到 out，非 2xx 響應以 *daemonError 返回
+func (dc *daemonClient) call(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("序列化請求失敗: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	// 主機名僅用於組成 URL，實際經由 Unix socket 連接
+	req, err := http.NewRequestWithContext(ctx, method, "http://actool"+path, body)
+	if err != nil {
+		return fmt.Errorf("創建守護進程請求失敗: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := dc.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("與守護進程通信失敗: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var daemonErr daemonError
+		if err := json.NewDecoder(resp.Body).Decode(&daemonErr); err != nil {
+			return fmt.Errorf("守護進程返回 HTTP %d", resp.StatusCode)
+		}
+		daemonErr.httpStatus = resp.StatusCode
+		return &daemonErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析守護進程響應失敗: %w", err)
+	}
+	return nil
+}
+
+// Status 方法用於獲取設備信息及守護進程中的定時器狀態
+func (dc *daemonClient) Status(ctx context.Context) (*daemonStatus, error) {
+	var status daemonStatus
+	if err := dc.call(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// On 方法用於開啟空調並按需設定定時器
+func (dc *daemonClient) On(ctx context.Context, req aconRequest) (*daemonOperateResponse, error) {
+	var resp daemonOperateResponse
+	if err := dc.call(ctx, http.MethodPost, "/ac/on", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Off 方法用於關閉空調並取消定時器
+func (dc *daemonClient) Off(ctx context.Context) (*daemonOperateResponse, error) {
+	var resp daemonOperateResponse
+	if err := dc.call(ctx, http.MethodPost, "/ac/off", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Adjust 方法用於調整空調的溫度、模式或風速
+func (dc *daemonClient) Adjust(ctx context.Context, s acSetting) (*daemonOperateResponse, error) {
+	var resp daemonOperateResponse
+	if err := dc.call(ctx, http.MethodPost, "/ac/settings", s, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CancelTimer 方法用於取消指定編號的定時動作，id 為 0 時取消所有定時動作
+func (dc *daemonClient) CancelTimer(ctx context.Context, id int) (*daemonOperateResponse, error) {
+	path := "/timers"
+	if id != 0 {
+		path += "/" + strconv.Itoa(id)
+	}
+	var resp daemonOperateResponse
+	if err := dc.call(ctx, http.MethodDelete, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// daemonSchedules 結構體用於將守護進程的定期任務接口適配為 scheduleManager
+type daemonSchedules struct {
+	ctx context.Context
+	dc  *daemonClient
+}
+
+// AddRule 方法用於在守護進程中添加定期任務
+func (ds daemonSchedules) AddRule(command, spec string) (scheduleRule, error) {
+	var rule scheduleRule
+	err := ds.dc.call(ds.ctx, http.MethodPost, "/schedules", daemonScheduleRequest{Command: command, Spec: spec}, &rule)
+	return rule, err
+}
+
+// RemoveRule 方法用於刪除守護進程中的定期任務，守護進程返回 404 時返回 errRuleNotFound
+func (ds daemonSchedules) RemoveRule(id int) error {
+	err := ds.dc.call(ds.ctx, http.MethodDelete, "/schedules/"+strconv.Itoa(id), nil, nil)
+	if daemonErr, ok := err.(*daemonError); ok && daemonErr.httpStatus == http.StatusNotFound {
+		return fmt.Errorf("定期任務 #%d: %w", id, errRuleNotFound)
+	}
+	return err
+}
+
+// Rules 方法用於獲取守護進程中的所有定期任務
+func (ds daemonSchedules) Rules() ([]scheduleRule, error) {
+	var rules []scheduleRule
+	err := ds.dc.call(ds.ctx, http.MethodGet, "/schedules", nil, &rules)
+	return rules, err
+}
+
+// printDaemonError 函數用於輸出守護進程返回的錯誤
+func printDaemonError(err error) {
+	fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+	if daemonErr, ok := err.(*daemonError); ok && daemonErr.StatusCode != 0 {
+		fmt.Fprintf(msgOut, "回應狀態碼：%d\n", daemonErr.StatusCode)
+	}
+}
+
+// forwardToDaemon 函數用於將命令行參數對應的操作轉交給守護進程執行
+// 返回 false 表示該命令不由守護進程處理，應在本進程內執行；返回的錯誤決定程式的退出碼
+func forwardToDaemon(ctx context.Context, dc *daemonClient, profile deviceProfile, args []string) (bool, error) {
+	commandArg := strings.TrimPrefix(strings.ToLower(args[0]), "--")
+
+	switch commandArg {
+	case "status":
+		fmt.Fprintln(msgOut, "\n正在經由守護進程獲取設備信息...")
+		status, err := dc.Status(ctx)
+		if err != nil {
+			printDaemonError(err)
+			return true, err
+		}
+		printDeviceInfo(status.Device, status.StatusCode, status.Actions)
+	case "acon", "timer":
+		req := aconRequest{}
+		if commandArg == "acon" {
+			var err error
+			if req, err = parseAconArgs(args[1:]); err != nil {
+				fmt.Fprintf(msgOut, "錯誤: --acon 的參數無效: %v\n", err)
+				return true, errUsage
+			}
+		} else {
+			if len(args) < 2 {
+				return false, nil
+			}
+			if strings.ToLower(args[1]) == "cancel" {
+				return true, forwardCancelTimer(ctx, dc, args[2:])
+			}
+			req.Until = args[1]
+		}
+		fmt.Fprintln(msgOut, "\n正在經由守護進程開啟空調...")
+		resp, err := dc.On(ctx, req) // 由守護進程補充設備的默認值
+		if err != nil {
+			printDaemonError(err)
+			return true, err
+		}
+		if resp.Result != nil {
+			printOperateResult(resp.Result)
+		}
+		if resp.Scheduled != nil {
+			printScheduledAction(*resp.Scheduled)
+			fmt.Fprintln(msgOut, "定時器由守護進程執行。")
+		}
+		return true, verifyResult(resp.Result)
+	case "acoff":
+		fmt.Fprintln(msgOut, "\n正在經由守護進程關閉空調...")
+		resp, err := dc.Off(ctx)
+		if err != nil {
+			printDaemonError(err)
+			return true, err
+		}
+		printOperateResult(resp.Result)
+		if resp.TimerCancelled {
+			fmt.Fprintln(msgOut, "定時器已取消。")
+		}
+		return true, verifyResult(resp.Result)
+	case "temp", "mode", "wind":
+		if len(args) < 2 {
+			return false, nil
+		}
+		s := acSetting{Kind: commandArg, Value: args[1]}
+		if err := s.validate(); err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return true, err
+		}
+		fmt.Fprintf(msgOut, "\n正在經由守護進程將空調設定為%s...\n", s)
+		resp, err := dc.Adjust(ctx, s)
+		if err != nil {
+			printDaemonError(err)
+			return true, err
+		}
+		printOperateResult(resp.Result)
+	case "schedule":
+		return true, runScheduleCommand(daemonSchedules{ctx: ctx, dc: dc}, "--schedule", args[1:])
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// forwardCancelTimer 函數用於經由守護進程取消定時器，未指定編號時取消全部
+func forwardCancelTimer(ctx context.Context, dc *daemonClient, args []string) error {
+	id := 0
+	if len(args) > 0 {
+		var err error
+		if id, err = strconv.Atoi(strings.TrimPrefix(args[0], "#")); err != nil {
+			fmt.Fprintf(msgOut, "錯誤: 無效的定時器編號 \"%s\"。\n", args[0])
+			return errUsage
+		}
+	}
+	resp, err := dc.CancelTimer(ctx, id)
+	if err != nil {
+		printDaemonError(err)
+		return err
+	}
+	if !resp.TimerCancelled {
+		fmt.Fprintln(msgOut, "沒有需要取消的定時器。")
+		return nil
+	}
+	fmt.Fprintln(msgOut, "定時器已取消。")
+	return nil
+}
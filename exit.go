@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"actool/client"
+)
+
+// exitPolicy 為程式退出 (/exit、Ctrl-C、SIGTERM) 時對空調的處理策略
+type exitPolicy string
+
+const (
+	exitKeep       exitPolicy = "keep"         // 保持空調狀態，未完成的定時器保存後於下次啟動時恢復
+	exitOff        exitPolicy = "off"          // 退出前關閉空調
+	exitOffIfTimer exitPolicy = "off-if-timer" // 僅在有待執行的自動關閉定時器時關閉空調
+)
+
+// exitOffTimeout 為退出時關閉空調的最長等待時間
+const exitOffTimeout = 30 * time.Second
+
+// parseExitPolicy 函數用於解析退出策略，空字串視為 keep
+func parseExitPolicy(s string) (exitPolicy, error) {
+	switch p := exitPolicy(s); p {
+	case "":
+		return exitKeep, nil
+	case exitKeep, exitOff, exitOffIfTimer:
+		return p, nil
+	}
+	return "", fmt.Errorf("無效的退出策略 %q，可選值為 keep、off、off-if-timer", s)
+}
+
+// applyExitPolicy 函數用於在程式退出前按策略關閉空調或保留定時器
+// 關閉期間再次按下 Ctrl-C 可放棄等待，此時定時器會被保留
+func applyExitPolicy(c *client.Client, deviceNo string, timers *scheduler, policy exitPolicy) {
+	actions := timers.Actions()
+	hasAutoOff := slices.ContainsFunc(actions, func(a scheduledAction) bool { return a.Command == client.CommandAirClose })
+	if policy == exitKeep || (policy == exitOffIfTimer && !hasAutoOff) {
+		reportPendingTimers(timers, len(actions))
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, exitOffTimeout)
+	defer cancel()
+
+	fmt.Fprintln(msgOut, "\n正在按退出策略關閉空調 (再次按 Ctrl-C 可放棄)...")
+	if _, err := operateAC(ctx, c, deviceNo, client.CommandAirClose); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 退出前未能關閉空調，空調可能仍在運行: %v\n", err)
+		reportPendingTimers(timers, len(actions))
+		return
+	}
+	if timers.CancelAutoOff() {
+		fmt.Fprintln(msgOut, "自動關閉的定時器已取消。")
+	}
+}
+
+// reportPendingTimers 函數用於在退出時說明 pending 個未完成的定時器是否已保存
+func reportPendingTimers(timers *scheduler, pending int) {
+	switch {
+	case pending == 0:
+	case timers.Persistent():
+		fmt.Fprintln(msgOut, "未完成的定時器已保存，下次啟動 actool 時將自動恢復。")
+	default:
+		fmt.Fprintf(msgOut, "警告: 定時器未被保存，%d 個未完成的定時器將在退出後失效。\n", pending)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// captureMessages 函數用於在測試期間將提示訊息寫入緩衝區
+func captureMessages(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := msgOut
+	msgOut = &buf
+	t.Cleanup(func() { msgOut = saved })
+	return &buf
+}
+
+// TestApplyExitPolicyKeep 測試保留定時器時，只有啟用了持久化才提示定時器已保存
+func TestApplyExitPolicyKeep(t *testing.T) {
+	s := newMockSession(t, mockserver.New("D1"))
+	s.timers.SetAutoOff(time.Now().Add(time.Hour), "測試")
+
+	out := captureMessages(t)
+	applyExitPolicy(s.client, s.profile.DeviceNo, s.timers, exitKeep)
+	if strings.Contains(out.String(), "已保存") || !strings.Contains(out.String(), "未被保存") {
+		t.Errorf("未啟用持久化時輸出 %q", out)
+	}
+
+	out.Reset()
+	s.timers.persist = func([]scheduledAction, []scheduleRule) {}
+	applyExitPolicy(s.client, s.profile.DeviceNo, s.timers, exitKeep)
+	if !strings.Contains(out.String(), "未完成的定時器已保存") {
+		t.Errorf("啟用持久化時輸出 %q", out)
+	}
+
+	out.Reset()
+	s.timers.CancelAll()
+	applyExitPolicy(s.client, s.profile.DeviceNo, s.timers, exitKeep)
+	if out.Len() != 0 {
+		t.Errorf("沒有定時器時輸出 %q", out)
+	}
+}
+
+// TestApplyExitPolicyOffFails 測試退出時關閉空調失敗會輸出錯誤，而不是靜默返回
+func TestApplyExitPolicyOffFails(t *testing.T) {
+	ts := httptest.NewServer(mockserver.New("D1"))
+	url := ts.URL
+	ts.Close()
+	c := client.New("t", "x")
+	c.BaseURL = url
+	c.Retry.MaxAttempts = 1
+	c.Verify.Timeout = 0
+	timers := newScheduler(nil, nil)
+	timers.SetAutoOff(time.Now().Add(time.Hour), "測試")
+
+	out := captureMessages(t)
+	applyExitPolicy(c, "D1", timers, exitOff)
+	if !strings.Contains(out.String(), "退出前未能關閉空調") || !strings.Contains(out.String(), "未被保存") {
+		t.Errorf("關閉失敗時輸出 %q", out)
+	}
+	if len(timers.Actions()) != 1 {
+		t.Error("關閉失敗時應保留自動關閉的定時器")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"actool/client"
+)
+
+// 程式的退出碼，供 cron 等腳本判斷失敗原因
+const (
+	exitOK          = 0
+	exitFailure     = 1   // 其他錯誤
+	exitUsage       = 2   // 命令行參數無效
+	exitConfig      = 3   // 缺少必要配置或配置無效
+	exitAuth        = 4   // Token 無效或已過期
+	exitNetwork     = 5   // 網絡錯誤或服務暫時不可用
+	exitAPI         = 6   // hatch-api 返回了其他錯誤代碼
+	exitOffline     = 7   // 設備離線或不存在
+	exitUnconfirmed = 8   // 指令已發送，但未能確認空調狀態已切換
+	exitLowBalance  = 9   // 電費餘額不高於 BALANCE_FLOOR，拒絕開啟空調
+	exitInterrupted = 130 // 被 Ctrl-C 或 SIGTERM 中斷
+)
+
+var (
+	errUsage        = errors.New("參數無效")
+	errUnconfirmed  = errors.New("未能確認空調狀態已切換")
+	errRuleNotFound = errors.New("定期任務不存在")
+)
+
+// 錯誤類別名稱，亦作為守護進程錯誤響應中的 kind 字段
+const (
+	kindFailure     = "error"
+	kindUsage       = "usage"
+	kindConfig      = "config"
+	kindAuth        = "auth"
+	kindNetwork     = "network"
+	kindAPI         = "api"
+	kindOffline     = "offline"
+	kindUnconfirmed = "unconfirmed"
+	kindLowBalance  = "low_balance"
+	kindInterrupted = "interrupted"
+)
+
+// kindExitCodes 為錯誤類別對應的退出碼
+var kindExitCodes = map[string]int{
+	kindFailure:     exitFailure,
+	kindUsage:       exitUsage,
+	kindConfig:      exitConfig,
+	kindAuth:        exitAuth,
+	kindNetwork:     exitNetwork,
+	kindAPI:         exitAPI,
+	kindOffline:     exitOffline,
+	kindUnconfirmed: exitUnconfirmed,
+	kindLowBalance:  exitLowBalance,
+	kindInterrupted: exitInterrupted,
+}
+
+// errorKind 函數用於將錯誤歸類，經由守護進程返回的錯誤沿用守護進程的分類
+func errorKind(err error) string {
+	var daemonErr *daemonError
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &daemonErr) && daemonErr.Kind != "":
+		return daemonErr.Kind
+	case errors.Is(err, context.Canceled):
+		return kindInterrupted
+	case errors.Is(err, client.ErrNoAuthPath), errors.Is(err, client.ErrSettingsDisabled):
+		return kindConfig
+	case errors.Is(err, client.ErrUnauthorized):
+		return kindAuth
+	case errors.Is(err, client.ErrDeviceOffline):
+		return kindOffline
+	case errors.Is(err, client.ErrLowBalance):
+		return kindLowBalance
+	case errors.Is(err, client.ErrNetwork), errors.Is(err, context.DeadlineExceeded):
+		return kindNetwork
+	case errors.As(err, &apiErr):
+		return kindAPI
+	case errors.Is(err, client.ErrInvalidSetting), errors.Is(err, errUsage), errors.Is(err, errRuleNotFound):
+		return kindUsage
+	case errors.Is(err, errUnconfirmed):
+		return kindUnconfirmed
+	}
+	return kindFailure
+}
+
+// exitCode 函數用於返回錯誤對應的退出碼，err 為 nil 時返回 0
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if code, ok := kindExitCodes[errorKind(err)]; ok {
+		return code
+	}
+	return exitFailure
+}
+
+// verifyResult 函數用於在開關指令未能確認時返回 errUnconfirmed
+func verifyResult(result *client.OperateResult) error {
+	if result != nil && result.Verification != nil && !result.Verification.Confirmed {
+		return errUnconfirmed
+	}
+	return nil
+}
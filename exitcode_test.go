@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"actool/client"
+)
+
+// TestExitCodeAPIResponses 測試 hatch-api 的各種響應經客戶端返回後歸入的錯誤類別及退出碼
+func TestExitCodeAPIResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error // 錯誤應屬於的類別，nil 表示只檢查退出碼
+		code   int
+	}{
+		{"成功", http.StatusOK, `{"code":0,"msg":"success","data":{"deviceNo":"D1"}}`, nil, exitOK},
+		{"code 401", http.StatusOK, `{"code":401,"msg":"未授權"}`, client.ErrTokenExpired, exitAuth},
+		{"code 403", http.StatusOK, `{"code":403,"msg":"禁止訪問"}`, client.ErrUnauthorized, exitAuth},
+		{"token 無效", http.StatusOK, `{"code":500,"msg":"Token無效"}`, client.ErrUnauthorized, exitAuth},
+		{"登录已过期", http.StatusOK, `{"code":500,"msg":"登录已过期，请重新登录"}`, client.ErrTokenExpired, exitAuth},
+		{"授權失效", http.StatusOK, `{"code":1,"msg":"授權已失效"}`, client.ErrTokenExpired, exitAuth},
+		{"code 404", http.StatusOK, `{"code":404,"msg":"not found"}`, client.ErrDeviceOffline, exitOffline},
+		{"设备离线", http.StatusOK, `{"code":500,"msg":"设备离线"}`, client.ErrDeviceOffline, exitOffline},
+		{"device offline", http.StatusOK, `{"code":1,"msg":"Device OFFLINE"}`, client.ErrDeviceOffline, exitOffline},
+		{"設備不存在", http.StatusOK, `{"code":1,"msg":"設備不存在"}`, client.ErrDeviceOffline, exitOffline},
+		{"其他 API 錯誤", http.StatusOK, `{"code":500,"msg":"系統繁忙"}`, nil, exitAPI},
+		{"提及 token 的業務錯誤", http.StatusOK, `{"code":500,"msg":"token 參數格式錯誤"}`, nil, exitAPI},
+		{"提及過期的業務錯誤", http.StatusOK, `{"code":500,"msg":"優惠券已過期"}`, nil, exitAPI},
+		{"HTTP 401", http.StatusUnauthorized, ``, client.ErrTokenExpired, exitAuth},
+		{"HTTP 403", http.StatusForbidden, ``, client.ErrUnauthorized, exitAuth},
+		{"HTTP 502", http.StatusBadGateway, ``, client.ErrNetwork, exitNetwork},
+		{"HTTP 429", http.StatusTooManyRequests, ``, client.ErrNetwork, exitNetwork},
+		{"無法解析的響應", http.StatusOK, `<html>維護中</html>`, nil, exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+			c := client.New("t", "x")
+			c.BaseURL = ts.URL
+			c.Retry.MaxAttempts = 1
+
+			_, _, err := c.GetDevice(context.Background(), "D1")
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("錯誤 %v 應屬於 %v", err, tt.want)
+			}
+			if got := exitCode(err); got != tt.code {
+				t.Errorf("exitCode(%v) = %d，應為 %d", err, got, tt.code)
+			}
+		})
+	}
+}
+
+// TestExitCodeErrors 測試本地錯誤及守護進程返回的錯誤對應的退出碼
+func TestExitCodeErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, exitOK},
+		{errors.New("未知錯誤"), exitFailure},
+		{fmt.Errorf("包裝: %w", errUsage), exitUsage},
+		{errRuleNotFound, exitUsage},
+		{fmt.Errorf("%w：溫度 %q 不是有效的數字", client.ErrInvalidSetting, "abc"), exitUsage},
+		{client.ErrNoAuthPath, exitConfig},
+		{client.ErrSettingsDisabled, exitConfig},
+		{fmt.Errorf("開啟失敗: %w", client.ErrLowBalance), exitLowBalance},
+		{errUnconfirmed, exitUnconfirmed},
+		{context.Canceled, exitInterrupted},
+		{fmt.Errorf("請求超時: %w", context.DeadlineExceeded), exitNetwork},
+		{&daemonError{Message: "守護進程", Kind: kindOffline}, exitOffline},
+		{&daemonError{Message: "守護進程", Kind: kindLowBalance}, exitLowBalance},
+		{&daemonError{Message: "未知類別", Kind: "future"}, exitFailure},
+		{&daemonError{Message: "沒有類別"}, exitFailure},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.code {
+			t.Errorf("exitCode(%v) = %d，應為 %d", tt.err, got, tt.code)
+		}
+	}
+	for kind, code := range kindExitCodes {
+		if got := exitCode(&daemonError{Kind: kind}); got != code {
+			t.Errorf("守護進程錯誤類別 %s 的退出碼為 %d，應為 %d", kind, got, code)
+		}
+	}
+}
+
+// TestVerifyResult 測試未確認送達的開關指令返回 errUnconfirmed
+func TestVerifyResult(t *testing.T) {
+	tests := []struct {
+		result *client.OperateResult
+		want   error
+	}{
+		{nil, nil},
+		{&client.OperateResult{}, nil},
+		{&client.OperateResult{Verification: &client.Verification{Confirmed: true}}, nil},
+		{&client.OperateResult{Verification: &client.Verification{}}, errUnconfirmed},
+	}
+	for _, tt := range tests {
+		if got := verifyResult(tt.result); got != tt.want {
+			t.Errorf("verifyResult(%+v) = %v，應為 %v", tt.result, got, tt.want)
+		}
+	}
+}
@@ -1,718 +1,816 @@
 package main
 
 import (
-	"bufio"         // 引入 bufio 套件用於帶緩衝的讀寫
-	"bytes"         // 引入 bytes 套件用於處理字節緩衝區
-	"encoding/json" // 引入 json 套件用於解析 JSON
+	"bufio" // 引入 bufio 套件用於帶緩衝的讀寫
+	"cmp"
+	"context" // 引入 context 套件用於傳遞請求上下文
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"os"      // 引入 os 套件用於處理命令行參數和環境變數
-	"strconv" // 引入 strconv 套件用於字串轉換
-	"strings" // 引入 strings 套件用於字串操作
+	"os"        // 引入 os 套件用於處理命令行參數和環境變數
+	"os/signal" // 引入 os/signal 套件用於處理 Ctrl-C
+	"strconv"   // 引入 strconv 套件用於字串轉換
+	"strings"   // 引入 strings 套件用於字串操作
+	"syscall"
 	"time"
-)
 
-// 全局變數用於儲存定時器的相關信息
-var (
-	timerActive      bool = false
-	timerEndTime     time.Time
-	timerDuration    time.Duration
-	timerDescription string
-	lastStatusTime   time.Time // 上次更新狀態的時間，用於精確計算剩餘時間
+	"actool/client" // hatch-api 客戶端
 )
 
-// loadEnvFile 函數用於從 .env 檔案中讀取環境變數
-func loadEnvFile(filename string) (map[string]string, error) {
-	envMap := make(map[string]string)
-	file, err := os.Open(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return envMap, nil // 檔案不存在不是錯誤，只是沒有環境變數
-		}
-		return nil, fmt.Errorf("無法打開環境變數檔案 %s: %w", filename, err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if len(line) == 0 || strings.HasPrefix(line, "#") {
-			continue // 跳過空行或註釋行
-		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			envMap[key] = value
-		}
+// printDeviceInfo 函數用於輸出設備信息
+func printDeviceInfo(deviceInfo *client.DeviceInfo, statusCode int, actions []scheduledAction) {
+	if structuredOutput() {
+		emit(daemonStatus{StatusCode: statusCode, Device: deviceInfo, Actions: actions})
+		return
 	}
+	fmt.Fprintln(msgOut, "==reponse==")
+	fmt.Fprintf(msgOut, "回應狀態碼：%d\n", statusCode)
+	fmt.Fprintln(msgOut, "==回應訊息==")
+	fmt.Fprintf(msgOut, "校   區：%s\n", deviceInfo.CampusTitle)
+	fmt.Fprintf(msgOut, "宿舍樓號：%s\n", deviceInfo.BuildingTitle)
+	fmt.Fprintf(msgOut, "樓   層：%s\n", deviceInfo.FloorTitle)
+	fmt.Fprintf(msgOut, "門牌號：%s\n", deviceInfo.RoomNo)
+	fmt.Fprintf(msgOut, "電費信息：%.2f\n", deviceInfo.Balance)
+	printFanInfo(deviceInfo.DeviceFan)
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("讀取環境變數檔案時出錯 %s: %w", filename, err)
-	}
-	return envMap, nil
+	// 顯示定時器狀態
+	printActions(actions)
+	fmt.Fprintln(msgOut, "===========")
 }
 
-// DeviceInfo 結構體用於解析 GET 響應中的 data 部分，以及構建 POST 請求的 payload
-type DeviceInfo struct {
-	ID                string      `json:"id"`
-	ManufactorID      string      `json:"manufactorId"`
-	ModelID           string      `json:"modelId"`
-	GatewayID         string      `json:"gatewayId"`
-	PortID            string      `json:"portId"`
-	CampusID          string      `json:"campusId"`
-	BuildingID        string      `json:"buildingId"`
-	FloorID           string      `json:"floorId"`
-	RoomID            string      `json:"roomId"`
-	DeviceType        int         `json:"deviceType"`
-	DeviceNo          string      `json:"deviceNo"`
-	DeviceIdx         int         `json:"deviceIdx"`
-	Status            int         `json:"status"`
-	StatusReason      string      `json:"statusReason"`
-	Creator           string      `json:"creator"`
-	CreateDate        string      `json:"createDate"`
-	CampusTitle       string      `json:"campusTitle"`
-	BuildingTitle     string      `json:"buildingTitle"`
-	FloorTitle        string      `json:"floorTitle"`
-	RoomNo            string      `json:"roomNo"`
-	ManufactorTitle   string      `json:"manufactorTitle"`
-	ModelTitle        string      `json:"modelTitle"`
-	GatewayNo         string      `json:"gatewayNo"`
-	SNCode            string      `json:"snCode"`
-	PortIdx           int         `json:"portIdx"`
-	DeviceFan         *DeviceFan  `json:"deviceFan"`   // 使用指針，因為可能為 null
-	DeviceMeter       interface{} `json:"deviceMeter"` // 可以是 null
-	DeviceWater       interface{} `json:"deviceWater"` // 可以是 null
-	IsInstallFinish   int         `json:"isInstallFinish"`
-	Position          interface{} `json:"position"` // 可以是 null
-	CommandKey        string      `json:"commandKey"`
-	LastCommunication string      `json:"lastCommunication"`
-	ProcessResult     interface{} `json:"processResult"` // 可以是 null
-	ProcessMsg        interface{} `json:"processMsg"`    // 可以是 null
-	CollectorNo       string      `json:"collectorNo"`
-	Forbidden         int         `json:"forbidden"`
-	Balance           float64     `json:"balance"`
-	NickNames         string      `json:"nickNames"`
-	UpdateDate        string      `json:"updateDate"`
-	MeterUsePower     interface{} `json:"meterUsePower"`         // 可以是 null
-	DeviceGroup       interface{} `json:"deviceGroup"`           // 可以是 null
-	StudentName       string      `json:"studentName,omitempty"` // AirOpen.json 中有，GetdeviceNo.json 中沒有
+// printInteractiveHelpMessage 函數用於輸出互動模式下的使用幫助
+func printInteractiveHelpMessage() {
+	fmt.Fprintln(msgOut, "===================================")
+	fmt.Fprintln(msgOut, "         ACtool 使用幫助           ")
+	fmt.Fprintln(msgOut, "===================================")
+	fmt.Fprintln(msgOut, "輸入以下命令進行操作：")
+	fmt.Fprintln(msgOut, "  /status  - 獲取設備的詳細資訊 (包括定時器狀態)")
+	fmt.Fprintln(msgOut, "  /acon    - 開啟空調 (可選: /acon <分鐘>，設定分鐘定時)")
+	fmt.Fprintln(msgOut, "           /acon --in 20m --for 2h 延遲開啟，/acon --at 06:30 指定時間開啟")
+	fmt.Fprintln(msgOut, "  /acoff   - 關閉空調")
+	fmt.Fprintln(msgOut, "  /timer <HH:MM> - 設定指定時間關閉空調 (24小時制)")
+	fmt.Fprintln(msgOut, "  /timer cancel [編號] - 取消指定或全部定時器")
+	fmt.Fprintln(msgOut, "  /temp <溫度> - 設定溫度，須在設備允許的範圍內")
+	fmt.Fprintln(msgOut, "  /mode cool|heat|fan|dry - 設定運行模式")
+	fmt.Fprintln(msgOut, "  /wind low|mid|high|auto - 設定風速")
+	fmt.Fprintln(msgOut, "    (溫度、模式與風速的指令及編號為未經真實設備驗證的推測，須設定 EXPERIMENTAL_SETTINGS=true 才會發送，若無效請以 /status 確認)")
+	fmt.Fprintln(msgOut, "  /schedule add|list|remove - 管理定期任務，例如 /schedule add on 13:00 mon-fri")
+	fmt.Fprintln(msgOut, "  /use [名稱] - 切換操作的設備，不帶參數時列出已配置的設備")
+	fmt.Fprintln(msgOut, "  /auth [授權地址] - Token 過期後以微信授權回調地址中的 code 換取新的 Token (須設定 AUTH_PATH)")
+	fmt.Fprintln(msgOut, "  /help    - 顯示此幫助訊息")
+	fmt.Fprintln(msgOut, "  /exit    - 退出程式")
+	fmt.Fprintln(msgOut, "===================================")
 }
 
-// DeviceFan 結構體用於解析 DeviceInfo 中的 deviceFan 部分
-type DeviceFan struct {
-	ID             string  `json:"id"`
-	DeviceID       string  `json:"deviceId"`
-	FanType        int     `json:"fanType"`
-	Password       string  `json:"password"`
-	FanStatus      int     `json:"fanStatus"` // 0 為關閉，1 為開啟
-	LockStatus     int     `json:"lockStatus"`
-	TempSetting    float64 `json:"tempSetting"`
-	FanModel       int     `json:"fanModel"`
-	WindSpeed      int     `json:"windSpeed"`
-	MaxTemp        float64 `json:"maxTemp"`
-	MinTemp        float64 `json:"minTemp"`
-	CompensateTemp float64 `json:"compensateTemp"`
-	CompensateFalg int     `json:"compensateFalg"`
-	ReturnTemp     float64 `json:"returnTemp"`
-	CurrentTemp    float64 `json:"currentTemp"`
-	FanStatusOld   int     `json:"fanStatusOld"`
+// printCommandLineHelpMessage 函數用於輸出命令行參數的使用幫助
+func printCommandLineHelpMessage() {
+	fmt.Fprintln(msgOut, "===================================")
+	fmt.Fprintln(msgOut, "         ACtool 命令行使用幫助       ")
+	fmt.Fprintln(msgOut, "===================================")
+	fmt.Fprintln(msgOut, "使用以下參數啟動程式：")
+	fmt.Fprintln(msgOut, "  --status  - 獲取設備的詳細資訊 (包括定時器狀態)")
+	fmt.Fprintln(msgOut, "  --acon [分鐘] - 開啟空調 (可選: 帶分鐘參數，設定分鐘定時)")
+	fmt.Fprintln(msgOut, "  --acon --in <時長>|--at <HH:MM> [--for <時長>] - 延遲或指定時間開啟空調")
+	fmt.Fprintln(msgOut, "  --acoff   - 關閉空調")
+	fmt.Fprintln(msgOut, "  --timer <HH:MM> - 設定指定時間關閉空調 (24小時制)")
+	fmt.Fprintln(msgOut, "  --timer cancel [編號] - 取消指定或全部定時器")
+	fmt.Fprintln(msgOut, "  --temp <溫度> - 設定溫度，須在設備允許的範圍內")
+	fmt.Fprintln(msgOut, "  --mode cool|heat|fan|dry - 設定運行模式")
+	fmt.Fprintln(msgOut, "  --wind low|mid|high|auto - 設定風速")
+	fmt.Fprintln(msgOut, "    (溫度、模式與風速的指令及編號為未經真實設備驗證的推測，須設定 EXPERIMENTAL_SETTINGS=true 才會發送，若無效請以 --status 確認)")
+	fmt.Fprintln(msgOut, "  --schedule add|list|remove - 管理定期任務，例如 --schedule add off \"0 23 * * *\"")
+	fmt.Fprintln(msgOut, "  --auth [授權地址] - 以微信授權回調地址 (含 code=...) 或 code 換取新的 Token 並保存，Token 過期時亦會自動提示 (須設定 AUTH_PATH)")
+	fmt.Fprintln(msgOut, "  --help    - 顯示此幫助訊息")
+	fmt.Fprintln(msgOut, "全局參數：")
+	fmt.Fprintln(msgOut, "  --api-base-url <URL> - 指定 hatch-api 地址 (亦可用 API_BASE_URL 設定)")
+	fmt.Fprintln(msgOut, "  --timeout <時長> - 單次 HTTP 請求的超時時間 (默認 10s)，按 Ctrl-C 可隨時取消請求")
+	fmt.Fprintln(msgOut, "  --on-exit keep|off|off-if-timer - 互動模式或守護進程退出時是否關閉空調 (亦可用 ON_EXIT 設定，默認 keep)")
+	fmt.Fprintln(msgOut, "  --device <名稱> - 選擇 actool.env 中 [名稱] 配置段定義的設備 (默認為第一個設備)")
+	fmt.Fprintln(msgOut, "  --all     - 對所有設備並發執行 --status、--acon、--acoff、--temp、--mode 或 --wind")
+	fmt.Fprintln(msgOut, "  --output text|json|yaml|table - 單次命令結果的輸出格式 (默認 text)，非 text 時提示訊息輸出到標準錯誤")
+	fmt.Fprintln(msgOut, "  --config <路徑> - 指定配置檔案 (亦可用 ACTOOL_CONFIG 設定)，支持 .env 與 .toml 格式")
+	fmt.Fprintln(msgOut, "環境變數：")
+	fmt.Fprintln(msgOut, "  VERIFY_TIMEOUT - 開關空調後確認狀態的最長等待時間 (默認 20s，0 為不確認)")
+	fmt.Fprintln(msgOut, "  VERIFY_RESEND  - 狀態未確認時自動重發指令的次數 (默認 0)")
+	fmt.Fprintln(msgOut, "  RETRY_ATTEMPTS - 網絡錯誤或 HTTP 5xx 時每個請求最多嘗試的次數 (默認 3)")
+	fmt.Fprintln(msgOut, "  ACON_DURATION  - 不帶定時參數開啟空調時的持續時間，例如 2h")
+	fmt.Fprintln(msgOut, "  TEMP/MODE/WIND - 開啟空調後自動套用的溫度、模式與風速 (須設定 EXPERIMENTAL_SETTINGS=true)")
+	fmt.Fprintln(msgOut, "  TOKEN_FILE/TOKEN_COMMAND/TOKEN_SECRET - 從檔案、命令輸出或加密的密鑰庫讀取 Token，避免明文保存")
+	fmt.Fprintln(msgOut, "  ACTOOL_PASSPHRASE - 密鑰庫的口令，未設定時在終端中提示輸入")
+	fmt.Fprintln(msgOut, "配置檔案依次查找 --config、ACTOOL_CONFIG、./actool.env、$XDG_CONFIG_HOME/actool/config.toml 與 actool.env")
+	fmt.Fprintln(msgOut, "子命令：")
+	fmt.Fprintln(msgOut, "  config init [路徑] - 以互動方式輸入 Token、設備號及學生姓名，驗證後生成配置檔案")
+	fmt.Fprintln(msgOut, "  config path|validate [路徑] - 顯示使用的配置檔案或檢查配置檔案")
+	fmt.Fprintln(msgOut, "  secret set|list|remove [名稱] - 管理以口令加密的密鑰庫，配置中以 TOKEN_SECRET=<名稱> 引用 Token")
+	fmt.Fprintln(msgOut, "  mock-server [--listen 地址] [--device 設備號] [--mqtt 地址] - 啟動本地模擬 hatch-api 服務，可同時啟動本地 MQTT 代理")
+	fmt.Fprintln(msgOut, "  daemon [--socket 路徑] [--on-exit 策略] - 以守護進程模式運行，之後的 --status/--acon/--acoff/--timer 將交由守護進程執行")
+	fmt.Fprintln(msgOut, "  serve [--listen 地址] [--socket 路徑] [--on-exit 策略] - 同 daemon，並在 HTTP 地址提供以 SERVE_TOKEN 驗證的 REST 接口 (見 /openapi.json)、Prometheus 指標 (/metrics) 及網頁控制台")
+	fmt.Fprintln(msgOut, "  daemon 與 serve 在設定了 MQTT_BROKER 時會將設備狀態發布到 MQTT，並以 Home Assistant 自動發現顯示為 climate 實體")
+	fmt.Fprintln(msgOut, "  daemon 與 serve 在設定了 BALANCE_ALERT 或 BALANCE_FLOOR 時會定期檢查電費餘額，並通過 NOTIFY_WEBHOOK、NOTIFY_COMMAND 或 NOTIFY_EMAIL_TO 發送提醒")
+	fmt.Fprintln(msgOut, "  balance [--test] - 顯示電費餘額及監控配置，--test 時通過所有已配置的渠道發送測試提醒")
+	fmt.Fprintln(msgOut, "退出碼：")
+	fmt.Fprintln(msgOut, "  0 成功  1 其他錯誤  2 參數無效  3 配置缺失或無效  4 Token 無效或已過期")
+	fmt.Fprintln(msgOut, "  5 網絡錯誤或服務暫時不可用  6 API 返回錯誤  7 設備離線或不存在  8 指令未確認送達  9 電費餘額過低  130 被中斷")
+	fmt.Fprintln(msgOut, "===================================")
 }
 
-// GetAPIResponse 結構體用於解析 GET 設備信息請求的整個 JSON 響應
-type GetAPIResponse struct {
-	Code int        `json:"code"`
-	Msg  string     `json:"msg"`
-	Data DeviceInfo `json:"data"`
+// printOperateResult 函數用於輸出空調操作的回應
+func printOperateResult(result *client.OperateResult) {
+	if structuredOutput() {
+		emit(result)
+		return
+	}
+	fmt.Fprintln(msgOut, "==reponse==")
+	fmt.Fprintf(msgOut, "回應狀態碼：%d\n", result.StatusCode)
+	fmt.Fprintln(msgOut, "==回應訊息==")
+	fmt.Fprintf(msgOut, "訊息：%s\n", result.MsgID)
+	fmt.Fprintf(msgOut, "設備號：%s\n", result.DeviceNo)
+	printVerification(result.Verification)
+	fmt.Fprintln(msgOut, "===========")
 }
 
-// OperateAPIResponse 結構體用於解析空調開關請求的 JSON 響應
-type OperateAPIResponse struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
-	Data struct {
-		MsgID    string `json:"msgId"`
-		DeviceNo string `json:"deviceNo"`
-	} `json:"data"`
+// printVerification 函數用於輸出開關指令的確認結果，未確認時 v 為 nil
+func printVerification(v *client.Verification) {
+	if v == nil {
+		return
+	}
+	if v.Resent > 0 {
+		fmt.Fprintf(msgOut, "自動重發：%d 次\n", v.Resent)
+	}
+	state := "未知"
+	switch v.FanStatus {
+	case 0:
+		state = "關閉"
+	case 1:
+		state = "開啟"
+	}
+	if v.Confirmed {
+		fmt.Fprintf(msgOut, "狀態確認：已確認，空調當前為%s (查詢 %d 次)\n", state, v.Polls)
+		return
+	}
+	fmt.Fprintf(msgOut, "狀態確認：未確認，空調當前狀態為%s (查詢 %d 次)，指令可能未送達\n", state, v.Polls)
 }
 
-// getDeviceInfo 函數用於獲取設備信息
-func getDeviceInfo(deviceNo, token string) (*DeviceInfo, int, error) {
-	url := fmt.Sprintf("https://es.sdtbu.edu.cn/hatch-api/api/sdgongshang/device/getDeviceByNo?deviceNo=%s", deviceNo)
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// operateAC 函數用於發送空調操作指令，並輸出回應
+func operateAC(ctx context.Context, c *client.Client, deviceNo, command string) (*client.OperateResult, error) {
+	result, err := c.Switch(ctx, deviceNo, command)
+	if err != nil {
+		fmt.Fprintf(msgOut, "空調操作失敗: %v\n", err)
+		if result != nil {
+			fmt.Fprintf(msgOut, "回應狀態碼：%d\n", result.StatusCode)
+		}
+		return result, err
 	}
+	printOperateResult(result)
+	return result, nil
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, 0, fmt.Errorf("創建 GET 請求失敗: %w", err)
-	}
-
-	req.Header.Set("Host", "es.sdtbu.edu.cn")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 NetType/WIFI MicroMessenger/7.0.20.1781(0x6700143B) WindowsWechat(0x63090c33) XWEB/13639 Flue")
-	req.Header.Set("Token", token)
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Sec-Fetch-Site", "same-origin")
-	req.Header.Set("Sec-Fetch-Mode", "cors")
-	req.Header.Set("Sec-Fetch-Dest", "empty")
-	req.Header.Set("Referer", "https://es.sdtbu.edu.cn/?code=081yCw2w33V3553Rxc4w3olDyB0yCw2G&state=wx")
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-	req.Header.Set("Priority", "u=1, i")
-	req.Header.Set("Connection", "close")
-
-	resp, err := client.Do(req)
+// runCommand 函數用於執行定時觸發的開關指令
+// 關閉指令未能確認時返回 errUnconfirmed，由調度器稍後重試
+func runCommand(ctx context.Context, c *client.Client, deviceNo, command string) error {
+	result, err := operateAC(ctx, c, deviceNo, command)
 	if err != nil {
-		return nil, 0, fmt.Errorf("發送 GET 請求失敗: %w", err)
+		return err
+	}
+	if command == client.CommandAirClose {
+		return verifyResult(result)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, 0, fmt.Errorf("讀取 GET 響應體失敗: %w", err)
+// runScheduledAction 函數為定時動作到期時的回調，發送對應的空調操作指令
+func runScheduledAction(c *client.Client, deviceNo string) func(ctx context.Context, a scheduledAction) error {
+	return func(ctx context.Context, a scheduledAction) error {
+		label := commandLabel(a.Command)
+		fmt.Fprintf(msgOut, "\n定時器 #%d (%s) 已到期，正在自動%s...\n", a.ID, a.Description, label)
+		if err := runCommand(ctx, c, deviceNo, a.Command); err != nil {
+			fmt.Fprintf(msgOut, "自動%s失敗: %v\n", label, err)
+			return err
+		}
+		fmt.Fprintf(msgOut, "空調已自動%s。\n", strings.TrimSuffix(label, "空調"))
+		if a.Command == client.CommandAirOpen {
+			applySettings(ctx, c, deviceNo, a.Settings)
+		}
+		if a.Command == client.CommandAirOpen && a.Duration > 0 {
+			fmt.Fprintf(msgOut, "空調將在 %s 後自動關閉。\n", formatDuration(a.Duration))
+		}
+		return nil
 	}
+}
 
-	var getResponse GetAPIResponse
-	err = json.Unmarshal(body, &getResponse)
-	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("解析 GET JSON 失敗: %w, 原始響應體:\n%s", err, string(body))
+// runScheduledRule 函數為定期任務觸發時的回調，發送對應的空調操作指令
+func runScheduledRule(c *client.Client, deviceNo string) func(ctx context.Context, rule scheduleRule) error {
+	return func(ctx context.Context, rule scheduleRule) error {
+		fmt.Fprintf(msgOut, "\n定期任務 #%d (%s) 已觸發，正在%s...\n", rule.ID, rule.Spec, commandLabel(rule.Command))
+		if err := runCommand(ctx, c, deviceNo, rule.Command); err != nil {
+			fmt.Fprintf(msgOut, "定期任務 #%d 執行失敗: %v\n", rule.ID, err)
+			return err
+		}
+		return nil
 	}
+}
 
-	if getResponse.Code != 0 {
-		return nil, resp.StatusCode, fmt.Errorf("獲取設備信息 API 返回錯誤代碼: %d, 訊息: %s", getResponse.Code, getResponse.Msg)
+// startScheduler 函數用於創建並啟動後台調度器，執行到期的定時動作及定期任務
+// 同時恢復上次退出前保存的定時動作及定期任務
+// store 為 nil 時定時器不會被保存
+func startScheduler(ctx context.Context, c *client.Client, deviceNo string, store *timerStore) *scheduler {
+	ctx = withPrompt(ctx, false) // 調度器在後台執行，Token 過期時不提示用戶輸入
+	timers := newScheduler(runScheduledAction(c, deviceNo), runScheduledRule(c, deviceNo))
+	if store != nil {
+		if err := attachTimerStore(ctx, timers, store, deviceNo); err != nil {
+			fmt.Fprintf(msgOut, "警告: %v\n", err)
+		}
 	}
+	go timers.Run(ctx)
+	return timers
+}
 
-	return &getResponse.Data, resp.StatusCode, nil
+// cancelTimers 函數用於取消指定編號的定時器，未指定編號時取消全部
+func cancelTimers(timers *scheduler, args []string) error {
+	if len(args) == 0 {
+		if timers.CancelAll() {
+			fmt.Fprintln(msgOut, "已取消所有定時器。")
+		} else {
+			fmt.Fprintln(msgOut, "沒有需要取消的定時器。")
+		}
+		return nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 無效的定時器編號 \"%s\"。\n", args[0])
+		return errUsage
+	}
+	if !timers.CancelAction(id) {
+		fmt.Fprintf(msgOut, "錯誤: 定時器 #%d 不存在。\n", id)
+		return errUsage
+	}
+	fmt.Fprintf(msgOut, "已取消定時器 #%d。\n", id)
+	return nil
 }
 
-// operateDevice 函數用於空調開關操作
-// 接收 studentName 參數
-func operateDevice(deviceInfo *DeviceInfo, token string, action string, studentName string) (int, string, string, error) {
-	url := "https://es.sdtbu.edu.cn/hatch-api/api/sdgongshang/device/operateDevice"
+// parseClockTime 函數用於將 HH:MM 解析為下一次出現的時間點
+// 若目標時間已過，則為第二天
+func parseClockTime(timeStr string, now time.Time) (time.Time, error) {
+	targetTime, err := time.Parse("15:04", timeStr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	targetDateTime := time.Date(now.Year(), now.Month(), now.Day(), targetTime.Hour(), targetTime.Minute(), 0, 0, now.Location())
+	if targetDateTime.Before(now) {
+		targetDateTime = targetDateTime.Add(24 * time.Hour)
+	}
+	return targetDateTime, nil
+}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+// extractFlag 函數用於從參數中移除布爾旗標 --name，返回其是否出現及剩餘參數
+func extractFlag(args []string, name string) (bool, []string) {
+	found := false
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--"+name {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
 	}
+	return found, rest
+}
 
-	// 根據操作類型設置 commandKey 和 fanStatus
-	if action == "start" || action == "acon" { // 添加 /acon 命令
-		deviceInfo.CommandKey = "AirOpen"
-		if deviceInfo.DeviceFan != nil {
-			deviceInfo.DeviceFan.FanStatus = 1 // 開啟
+// extractFlagValue 函數用於從參數列表中取出 "--name value" 或 "--name=value" 形式的全局參數
+// 返回該參數的值及剩餘的參數列表
+func extractFlagValue(args []string, name string) (string, []string) {
+	var value string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--"+name && i+1 < len(args) {
+			value = args[i+1]
+			i++
+			continue
 		}
-		deviceInfo.StudentName = studentName // 使用傳入的 studentName 參數
-	} else if action == "stop" || action == "acoff" { // 添加 /acoff 命令
-		deviceInfo.CommandKey = "AirClose"
-		if deviceInfo.DeviceFan != nil {
-			deviceInfo.DeviceFan.FanStatus = 0 // 關閉
+		if v, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			value = v
+			continue
 		}
-		deviceInfo.StudentName = studentName // 關閉時也設置 StudentName
-	} else {
-		return 0, "", "", fmt.Errorf("無效的操作：%s，請使用 -start/-acon 或 -stop/-acoff", action)
+		rest = append(rest, arg)
+	}
+	return value, rest
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run 函數為程式的主體，返回退出碼
+func run() int {
+	// 模擬服務不需要 Token 等配置，優先處理
+	if len(os.Args) >= 2 && os.Args[1] == "mock-server" {
+		return runMockServer(os.Args[2:])
 	}
 
-	// 將更新後的 deviceInfo 序列化為 JSON
-	payloadBytes, err := json.Marshal(deviceInfo)
+	var token, deviceNo, studentName, apiBaseURL string
+
+	// 0. 命令行參數優先級最高
+	flagBaseURL, args := extractFlagValue(os.Args[1:], "api-base-url")
+	flagTimeout, args := extractFlagValue(args, "timeout")
+	flagOnExit, args := extractFlagValue(args, "on-exit")
+	flagOutput, args := extractFlagValue(args, "output")
+	flagDevice, args := extractFlagValue(args, "device")
+	flagAll, args := extractFlag(args, "all")
+	flagConfig, args := extractFlagValue(args, "config")
+	os.Args = append(os.Args[:1], args...)
+
+	format, err := parseOutputFormat(flagOutput)
 	if err != nil {
-		return 0, "", "", fmt.Errorf("序列化請求 payload 失敗: %w", err)
+		fmt.Fprintf(msgOut, "錯誤: --output: %v\n", err)
+		return exitUsage
 	}
+	// 結構化輸出只適用於執行後退出的單次命令，互動模式與守護進程的輸出保持不變
+	if format != outputText && (len(os.Args) < 2 || os.Args[1] == "daemon" || os.Args[1] == "serve") {
+		fmt.Fprintln(os.Stderr, "錯誤: --output 只適用於命令行模式的單次命令，不能用於互動模式、daemon 或 serve。")
+		return exitUsage
+	}
+	setOutputFormat(format)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
+	// 1. 確定配置檔案，config 子命令不需要 Token 等配置
+	if len(os.Args) >= 2 && os.Args[1] == "config" {
+		return runConfigCommand(flagConfig, cmp.Or(flagBaseURL, os.Getenv("API_BASE_URL")), os.Args[2:])
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "secret" {
+		return runSecretCommand(os.Args[2:])
+	}
+	configPath, err := findConfigFile(flagConfig)
 	if err != nil {
-		return 0, "", "", fmt.Errorf("創建 POST 請求失敗: %w", err)
-	}
-
-	// 設定請求頭
-	req.Header.Set("Host", "es.sdtbu.edu.cn")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 NetType/WIFI MicroMessenger/7.0.20.1781(0x6700143B) WindowsWechat(0x63090c33) XWEB/13639 Flue")
-	req.Header.Set("Token", token)
-	req.Header.Set("Content-Type", "application/json") // POST 請求需要設置 Content-Type
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Origin", "https://es.sdtbu.edu.cn") // 新增 Origin
-	req.Header.Set("Sec-Fetch-Site", "same-origin")
-	req.Header.Set("Sec-Fetch-Mode", "cors")
-	req.Header.Set("Sec-Fetch-Dest", "empty")
-	req.Header.Set("Referer", "https://es.sdtbu.edu.cn/") // 更新 Referer
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
-	req.Header.Set("Priority", "u=1, i")
-	req.Header.Set("Connection", "close")
-
-	resp, err := client.Do(req)
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+	config, err := loadConfigFile(configPath)
 	if err != nil {
-		return 0, "", "", fmt.Errorf("發送 POST 請求失敗: %w", err)
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		fmt.Fprintln(msgOut, "可執行 actool config validate 檢查配置檔案。")
+		return exitConfig
+	}
+	for _, issue := range config.Issues {
+		fmt.Fprintf(msgOut, "警告: %s\n", issue)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// 2. 環境變數優先於配置檔案
+	setting := func(key string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return config.Global[key]
+	}
+	secrets := newSecretResolver(configPath)
+	if token, err = secrets.token(setting); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+	deviceNo = setting("DEVICENO")
+	studentName = setting("STUDENTNAME")
+	apiBaseURL = setting("API_BASE_URL")
+	verifyTimeout := setting("VERIFY_TIMEOUT")
+	verifyResend := setting("VERIFY_RESEND")
+	retryAttempts := setting("RETRY_ATTEMPTS")
+	onExitValue := setting("ON_EXIT")
+	defaults := deviceProfile{
+		DeviceNo:     deviceNo,
+		AconDuration: setting("ACON_DURATION"),
+		Temp:         setting("TEMP"),
+		Mode:         setting("MODE"),
+		Wind:         setting("WIND"),
+	}
+	if flagBaseURL != "" {
+		apiBaseURL = flagBaseURL
+	}
+	if flagOnExit != "" {
+		onExitValue = flagOnExit
+	}
+	onExit, err := parseExitPolicy(onExitValue)
 	if err != nil {
-		return resp.StatusCode, "", "", fmt.Errorf("讀取 POST 響應體失敗: %w", err)
+		fmt.Fprintf(msgOut, "錯誤: ON_EXIT 或 --on-exit: %v\n", err)
+		return exitConfig
 	}
 
-	var operateResponse OperateAPIResponse
-	err = json.Unmarshal(body, &operateResponse)
+	// 3. 檢查所有必要變數是否已設置，[名稱] 配置段中的設備可單獨設定 TOKEN 與 STUDENTNAME
+	profiles, err := buildProfiles(defaults, config.Sections, secrets)
 	if err != nil {
-		return resp.StatusCode, "", "", fmt.Errorf("解析 POST JSON 失敗: %w, 原始響應體:\n%s", err, string(body))
+		fmt.Fprintf(msgOut, "錯誤: %s: %v\n", config.Path, err)
+		return exitConfig
 	}
-
-	if operateResponse.Code != 0 {
-		return resp.StatusCode, "", "", fmt.Errorf("空調操作 API 返回錯誤代碼: %d, 訊息: %s", operateResponse.Code, operateResponse.Msg)
+	if len(profiles) == 0 {
+		fmt.Fprintln(msgOut, "錯誤: DEVICENO 環境變數或 actool.env 中的 DEVICENO 未設定。請設定。")
+		return exitConfig
 	}
-
-	return resp.StatusCode, operateResponse.Data.MsgID, operateResponse.Data.DeviceNo, nil
-}
-
-// printDeviceInfo 函數用於輸出設備信息
-func printDeviceInfo(deviceInfo *DeviceInfo, statusCode int) {
-	fmt.Println("==reponse==")
-	fmt.Printf("回應狀態碼：%d\n", statusCode)
-	fmt.Println("==回應訊息==")
-	fmt.Printf("校   區：%s\n", deviceInfo.CampusTitle)
-	fmt.Printf("宿舍樓號：%s\n", deviceInfo.BuildingTitle)
-	fmt.Printf("樓   層：%s\n", deviceInfo.FloorTitle)
-	fmt.Printf("門牌號：%s\n", deviceInfo.RoomNo)
-	fmt.Printf("電費信息：%.2f\n", deviceInfo.Balance)
-
-	// 顯示定時器狀態
-	if timerActive {
-		remaining := timerEndTime.Sub(time.Now())
-		if remaining <= 0 {
-			fmt.Println("定時器狀態：已過期，等待關閉空調。")
-		} else {
-			hours := int(remaining.Hours())
-			minutes := int(remaining.Minutes()) % 60
-			seconds := int(remaining.Seconds()) % 60
-			fmt.Printf("定時器狀態：啟用中，將於 %s 後關閉空調 (%s 後，在 %s)。\n",
-				timerDescription,
-				fmt.Sprintf("%02d時%02d分%02d秒", hours, minutes, seconds),
-				timerEndTime.Format("15:04:05"))
+	for _, p := range profiles {
+		if token == "" && p.Token == "" {
+			fmt.Fprintln(msgOut, "錯誤: TOKEN 環境變數或 actool.env 中的 TOKEN 未設定。請設定 TOKEN、TOKEN_FILE、TOKEN_COMMAND 或 TOKEN_SECRET。")
+			return exitConfig
+		}
+		if studentName == "" && p.StudentName == "" {
+			fmt.Fprintln(msgOut, "錯誤: STUDENTNAME 環境變數或 actool.env 中的 STUDENTNAME 未設定。請設定。")
+			return exitConfig
+		}
+		for key, value := range map[string]string{"ACON_DURATION": p.AconDuration, "TEMP": p.Temp, "MODE": p.Mode, "WIND": p.Wind} {
+			if value == "" {
+				continue
+			}
+			if err := validateConfigValue(key, value); err != nil {
+				fmt.Fprintf(msgOut, "錯誤: 設備 [%s] 的 %s 無效: %v\n", p.Name, key, err)
+				return exitConfig
+			}
 		}
-	} else {
-		fmt.Println("定時器狀態：未啟用。")
 	}
-	fmt.Println("===========")
-}
-
-// printInteractiveHelpMessage 函數用於輸出互動模式下的使用幫助
-func printInteractiveHelpMessage() {
-	fmt.Println("===================================")
-	fmt.Println("         ACtool 使用幫助           ")
-	fmt.Println("===================================")
-	fmt.Println("輸入以下命令進行操作：")
-	fmt.Println("  /status  - 獲取設備的詳細資訊 (包括定時器狀態)")
-	fmt.Println("  /acon    - 開啟空調 (可選: /acon <分鐘>，設定分鐘定時)")
-	fmt.Println("  /acoff   - 關閉空調")
-	fmt.Println("  /timer <HH:MM> - 設定指定時間關閉空調 (24小時制)")
-	fmt.Println("  /help    - 顯示此幫助訊息")
-	fmt.Println("  /exit    - 退出程式")
-	fmt.Println("===================================")
-}
-
-// printCommandLineHelpMessage 函數用於輸出命令行參數的使用幫助
-func printCommandLineHelpMessage() {
-	fmt.Println("===================================")
-	fmt.Println("         ACtool 命令行使用幫助       ")
-	fmt.Println("===================================")
-	fmt.Println("使用以下參數啟動程式：")
-	fmt.Println("  --status  - 獲取設備的詳細資訊 (包括定時器狀態)")
-	fmt.Println("  --acon [分鐘] - 開啟空調 (可選: 帶分鐘參數，設定分鐘定時)")
-	fmt.Println("  --acoff   - 關閉空調")
-	fmt.Println("  --timer <HH:MM> - 設定指定時間關閉空調 (24小時制)")
-	fmt.Println("  --help    - 顯示此幫助訊息")
-	fmt.Println("===================================")
-}
+	profile, err := findProfile(profiles, flagDevice)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: --device: %v\n", err)
+		return exitUsage
+	}
 
-// handleTimer 處理定時器邏輯
-func handleTimer(deviceInfo *DeviceInfo, token, studentName string) {
-	// 如果定時器已經過期，則執行關閉操作
-	if timerActive && time.Now().After(timerEndTime) {
-		fmt.Println("\n定時器已到期，正在自動關閉空調...")
-		_, _, _, err := operateDevice(deviceInfo, token, "acoff", studentName)
+	// Ctrl-C 或 SIGTERM 會取消正在進行的請求並退出程式
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	c := client.New(token, studentName)
+	if apiBaseURL != "" {
+		c.BaseURL = apiBaseURL
+	}
+	if verifyTimeout != "" {
+		d, err := parseFlexibleDuration(verifyTimeout)
+		if verifyTimeout == "0" {
+			d, err = 0, nil // 0 表示不確認
+		}
 		if err != nil {
-			fmt.Printf("自動關閉空調失敗: %v\n", err)
-		} else {
-			fmt.Println("空調已自動關閉。")
+			fmt.Fprintf(msgOut, "錯誤: VERIFY_TIMEOUT 無效: %v\n", err)
+			return exitConfig
 		}
-		timerActive = false // 定時器完成
+		c.Verify.Timeout = d
+	}
+	if verifyResend != "" {
+		n, err := strconv.Atoi(verifyResend)
+		if err != nil || n < 0 {
+			fmt.Fprintln(msgOut, "錯誤: VERIFY_RESEND 無效，請輸入非負整數。")
+			return exitConfig
+		}
+		c.Verify.Resends = n
+	}
+	if flagTimeout != "" {
+		d, err := time.ParseDuration(flagTimeout)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(msgOut, "錯誤: --timeout 無效：%q，請使用 5s、30s 等格式。\n", flagTimeout)
+			return exitConfig
+		}
+		c.RequestTimeout = d
+	}
+	if retryAttempts != "" {
+		n, err := strconv.Atoi(retryAttempts)
+		if err != nil || n < 1 {
+			fmt.Fprintln(msgOut, "錯誤: RETRY_ATTEMPTS 無效，請輸入正整數。")
+			return exitConfig
+		}
+		c.Retry.MaxAttempts = n
 	}
-}
-
-func main() {
-	var token, deviceNo, studentName string
 
-	// 1. 嘗試從環境變數讀取
-	token = os.Getenv("TOKEN")
-	deviceNo = os.Getenv("DEVICENO")
-	studentName = os.Getenv("STUDENTNAME")
+	if authPath := setting("AUTH_PATH"); authPath != "" {
+		c.AuthPath = authPath
+	}
+	if experimental := setting("EXPERIMENTAL_SETTINGS"); experimental != "" {
+		enabled, err := strconv.ParseBool(experimental)
+		if err != nil {
+			fmt.Fprintln(msgOut, "錯誤: EXPERIMENTAL_SETTINGS 無效，請輸入 true 或 false。")
+			return exitConfig
+		}
+		c.ExperimentalSettings = enabled
+	}
 
-	// 2. 如果環境變數未設定，嘗試從 actool.env 檔案讀取
-	envFromFile, err := loadEnvFile("actool.env")
+	// 電費餘額不高於 BALANCE_FLOOR 時，所有模式下均拒絕開啟空調
+	balanceCfg, err := newBalanceWatchConfig(setting)
 	if err != nil {
-		fmt.Printf("警告: 無法讀取 actool.env 檔案: %v\n", err)
-	} else {
-		if token == "" {
-			token = envFromFile["TOKEN"]
-		}
-		if deviceNo == "" {
-			deviceNo = envFromFile["DEVICENO"]
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+	c.BalanceFloor = balanceCfg.Floor
+
+	// Token 過期時提示輸入微信 OAuth code 換取新的 Token，守護進程中只重新讀取配置中更新後的 Token
+	renewer := newTokenRenewer(config, secrets, len(os.Args) < 2 || (os.Args[1] != "daemon" && os.Args[1] != "serve"))
+	c.Reauth = renewer.hook(c, "")
+	devices := newDeviceSet(profiles, c)
+	devices.renewer = renewer
+	socketPath, socketErr := defaultSocketPath()
+
+	// 守護進程與 HTTP 控制接口模式下，設定了 MQTT_BROKER 時同時橋接到 MQTT
+	var mqttCfg *mqttConfig
+	if len(os.Args) >= 2 && (os.Args[1] == "daemon" || os.Args[1] == "serve") {
+		if mqttCfg, err = newMQTTConfig(setting); err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return exitConfig
 		}
-		if studentName == "" {
-			studentName = envFromFile["STUDENTNAME"]
+	}
+
+	// 守護進程模式，無需終端，擁有定時器並監聽控制 socket，每個設備運行各自的守護進程
+	if len(os.Args) >= 2 && os.Args[1] == "daemon" {
+		return runDaemonCommand(ctx, devices, profile, profileSocketPath(socketPath, profile.Name), onExit, mqttCfg, balanceCfg, os.Args[2:])
+	}
+
+	// HTTP 控制接口模式，在守護進程的基礎上於 TCP 地址提供以 Bearer Token 驗證的 REST 接口
+	if len(os.Args) >= 2 && os.Args[1] == "serve" {
+		if _, ok := config.Global["SERVE_TOKEN"]; ok && setting("SERVE_TOKEN") == "" {
+			fmt.Fprintln(msgOut, "錯誤: SERVE_TOKEN 不能為空，刪除該設定則每次啟動時隨機生成。")
+			return exitConfig
 		}
+		return runServeCommand(ctx, devices, profile, profileSocketPath(socketPath, profile.Name), onExit, mqttCfg, balanceCfg, setting("SERVE_LISTEN"), setting("SERVE_TOKEN"), os.Args[2:])
 	}
 
-	// 3. 檢查所有必要變數是否已設置
-	if token == "" {
-		fmt.Println("錯誤: TOKEN 環境變數或 actool.env 中的 TOKEN 未設定。請設定。")
-		os.Exit(1) // 退出程式
+	// 顯示電費餘額及監控配置，或發送測試提醒
+	if len(os.Args) >= 2 && os.Args[1] == "balance" {
+		return runBalanceCommand(ctx, devices, profile, balanceCfg, os.Args[2:])
 	}
-	if deviceNo == "" {
-		fmt.Println("錯誤: DEVICENO 環境變數或 actool.env 中的 DEVICENO 未設定。請設定。")
-		os.Exit(1) // 退出程式
+
+	// 對所有設備並發執行同一命令
+	if flagAll {
+		if len(os.Args) < 2 {
+			fmt.Fprintln(msgOut, "錯誤: --all 需要與 --status、--acon、--acoff、--temp、--mode 或 --wind 一起使用。")
+			return exitUsage
+		}
+		return exitCode(runAllDevices(ctx, devices, socketPath, os.Args[1:]))
 	}
-	if studentName == "" {
-		fmt.Println("錯誤: STUDENTNAME 環境變數或 actool.env 中的 STUDENTNAME 未設定。請設定。")
-		os.Exit(1) // 退出程式
+
+	// 若守護進程正在運行，則將命令轉交給它處理，終端無需保持打開
+	if len(os.Args) >= 2 && socketErr == nil {
+		if dc := dialDaemon(profileSocketPath(socketPath, profile.Name)); dc != nil {
+			if handled, err := forwardToDaemon(ctx, dc, profile, os.Args[1:]); handled {
+				return commandExit(err)
+			}
+		}
 	}
 
+	session := devices.open(ctx, profile)
+
 	// 判斷是否帶有命令行參數啟動
 	if len(os.Args) >= 2 {
-		arg := os.Args[1]
-		// 移除命令參數前的雙連字符 "--"
-		commandArg := strings.TrimPrefix(strings.ToLower(arg), "--") // 確保參數也是小寫
-
-		// 處理帶有時間參數的acon
-		if commandArg == "acon" && len(os.Args) >= 3 {
-			minutesStr := os.Args[2]
-			minutes, err := strconv.Atoi(minutesStr)
-			if err != nil || minutes <= 0 {
-				fmt.Println("錯誤: --acon 後的定時分鐘數無效。請輸入正整數。")
-				return
-			}
-			// 設置定時器
-			timerActive = true
-			timerEndTime = time.Now().Add(time.Duration(minutes) * time.Minute)
-			timerDuration = time.Duration(minutes) * time.Minute
-			timerDescription = fmt.Sprintf("%d分鐘", minutes)
-
-			fmt.Printf("\n空調將在 %d 分鐘後自動關閉。\n", minutes)
-			// 命令行啟動時，acon 後有時間參數，只執行開啟動作，不進入互動模式
-			deviceInfo, statusCode, err := getDeviceInfo(deviceNo, token)
-			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
-				return
-			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acon", studentName)
-			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
-				// 在單次命令行模式下設置了定時器，但程式會立即退出，因此定時器不會生效。
-				// 如果需要定時器生效，需要讓程式保持運行，這通常是互動模式或作為服務運行。
-				// 根據 mind.md，命令行模式下設置定時器應保持程式運行。
-				// 因此，對於帶有定時功能的命令行模式，程式不應立即退出，而應進入監聽模式。
-				fmt.Println("定時任務已設定。程式將保持運行以監聽定時器。")
-				runInteractiveMode(token, deviceNo, studentName) // 進入互動模式，監聽定時器
-			}
-			return // 處理完畢，退出命令行模式
-		} else if commandArg == "timer" && len(os.Args) >= 3 {
-			timeStr := os.Args[2] // HH:MM
-			now := time.Now()
-			targetTime, err := time.Parse("15:04", timeStr)
-			if err != nil {
-				fmt.Println("錯誤: --timer 後的時間格式無效。請使用 HH:MM 格式。")
-				return
-			}
+		// 帶有命令行參數時，執行完畢後直接退出，退出碼反映執行結果
+		return commandExit(runCommandLine(withPrompt(ctx, true), devices, session, onExit, os.Args[1:]))
+	}
 
-			// 構建目標時間的日期部分，考慮是否為第二天
-			targetDateTime := time.Date(now.Year(), now.Month(), now.Day(), targetTime.Hour(), targetTime.Minute(), 0, 0, now.Location())
-			if targetDateTime.Before(now) {
-				targetDateTime = targetDateTime.Add(24 * time.Hour) // 如果目標時間已過，則為第二天
-			}
+	// 若未接受到命令參數，進入互動模式
+	runInteractiveMode(withPrompt(ctx, true), devices, session, onExit)
+	return exitOK
+}
 
-			timerActive = true
-			timerEndTime = targetDateTime
-			timerDuration = targetDateTime.Sub(now)
-			timerDescription = fmt.Sprintf("指定時間 %s", timeStr)
+// runCommandLine 函數用於對 session 對應的設備執行命令行參數指定的操作，返回的錯誤決定程式的退出碼
+func runCommandLine(ctx context.Context, devices *deviceSet, session *deviceSession, onExit exitPolicy, args []string) error {
+	c, deviceNo, timers := session.client, session.profile.DeviceNo, session.timers
+	arg := args[0]
+	// 移除命令參數前的雙連字符 "--"
+	commandArg := strings.TrimPrefix(strings.ToLower(arg), "--") // 確保參數也是小寫
 
-			fmt.Printf("\n空調將在 %s 自動關閉。\n", timerEndTime.Format("2006-01-02 15:04:05"))
-			fmt.Println("定時任務已設定。程式將保持運行以監聽定時器。")
+	// 以 OAuth code 重新授權
+	if commandArg == "auth" {
+		return runAuth(ctx, devices, session, args[1:])
+	}
 
-			// 在設定定時後，開啟空調
-			deviceInfo, statusCode, err := getDeviceInfo(deviceNo, token)
-			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
-				return
+	// 取消已保存的定時器，不操作空調
+	if commandArg == "timer" && len(args) >= 2 && strings.ToLower(args[1]) == "cancel" {
+		return cancelTimers(timers, args[2:])
+	}
+
+	// 處理帶有定時參數的 acon 與 timer
+	// 配置了 ACON_DURATION 或默認設定時，不帶參數的 acon 也按定時開啟處理
+	if (commandArg == "acon" || commandArg == "timer") && (len(args) >= 2 || session.profile.hasAconDefaults()) {
+		req := aconRequest{}
+		if commandArg == "acon" {
+			var err error
+			if req, err = parseAconArgs(args[1:]); err != nil {
+				fmt.Fprintf(msgOut, "錯誤: --acon 的參數無效: %v\n", err)
+				return errUsage
 			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acon", studentName)
-			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
+		} else {
+			if len(args) < 2 {
+				fmt.Fprintln(msgOut, "錯誤: --timer 需要時間參數，例如 --timer 01:30。")
+				return errUsage
 			}
-			runInteractiveMode(token, deviceNo, studentName) // 進入互動模式，監聽定時器
-			return                                           // 處理完畢，退出命令行模式
+			req.Until = args[1]
+		}
+		req = session.profile.withDefaults(req)
+		// 帶有定時功能的命令行模式，程式不應立即退出，而應進入監聽模式。
+		action, err := runAcon(ctx, c, deviceNo, timers, req)
+		if action != nil {
+			fmt.Fprintln(msgOut, "定時任務已設定。程式將保持運行以監聽定時器。")
+			runInteractiveMode(ctx, devices, session, onExit) // 進入互動模式，監聽定時器
 		}
+		return err
+	}
 
-		switch commandArg {
-		case "status":
-			fmt.Println("\n正在獲取設備信息...")
-			deviceInfo, statusCode, err := getDeviceInfo(deviceNo, token)
-			if err != nil {
-				fmt.Printf("錯誤: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
-			} else {
-				printDeviceInfo(deviceInfo, statusCode)
-			}
-		case "acon": // 無定時參數的acon
-			fmt.Println("\n正在開啟空調...")
-			deviceInfo, statusCode, err := getDeviceInfo(deviceNo, token) // 重新獲取最新設備狀態
-			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
-				break // break from switch, then return from main
-			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acon", studentName) // Pass "acon" as action
-			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
-			}
-		case "acoff":
-			fmt.Println("\n正在關閉空調...")
-			deviceInfo, statusCode, err := getDeviceInfo(deviceNo, token) // 重新獲取最新設備狀態
-			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
-				break // break from switch, then return from main
-			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acoff", studentName) // Pass "acoff" as action
-			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
-			}
-		case "help":
-			printCommandLineHelpMessage() // 呼叫新的命令行幫助函數
-		default:
-			fmt.Printf("無效的啓動參數：\"%s\"。\n", arg)
-			fmt.Println("用法：./actool [--status | --acon [分鐘] | --acoff | --timer <HH:MM> | --help]")
-			fmt.Println("例如：./actool --acon 30 開啟空調30分鐘")
-			fmt.Println("例如：./actool --timer 23:30 在23:30關閉空調")
+	// 調整溫度、模式或風速
+	if kind, ok := parseSettingKind(commandArg); ok {
+		if len(args) < 2 {
+			printSettingUsage(kind, "--")
+			return errUsage
 		}
-		return // 帶有命令行參數時，執行完畢後直接退出
+		return adjustAC(ctx, c, deviceNo, acSetting{Kind: kind, Value: args[1]})
 	}
 
-	// 若未接受到命令參數，進入互動模式
-	runInteractiveMode(token, deviceNo, studentName)
+	switch commandArg {
+	case "status":
+		fmt.Fprintln(msgOut, "\n正在獲取設備信息...")
+		deviceInfo, statusCode, err := c.GetDevice(ctx, deviceNo)
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			fmt.Fprintf(msgOut, "回應狀態碼：%d\n", statusCode)
+			return err
+		}
+		printDeviceInfo(deviceInfo, statusCode, timers.Actions())
+	case "acon": // 無定時參數的acon
+		fmt.Fprintln(msgOut, "\n正在開啟空調...")
+		result, err := operateAC(ctx, c, deviceNo, client.CommandAirOpen)
+		if err != nil {
+			return err
+		}
+		return verifyResult(result)
+	case "acoff":
+		fmt.Fprintln(msgOut, "\n正在關閉空調...")
+		result, err := operateAC(ctx, c, deviceNo, client.CommandAirClose)
+		if err != nil {
+			return err
+		}
+		if timers.CancelAutoOff() {
+			fmt.Fprintln(msgOut, "定時器已取消。")
+		}
+		return verifyResult(result)
+	case "schedule":
+		if err := runScheduleCommand(localSchedules{timers}, "--schedule", args[1:]); err != nil {
+			return err
+		}
+		if len(args) >= 2 && strings.ToLower(args[1]) == "add" {
+			fmt.Fprintln(msgOut, "提示: 定期任務需要 actool daemon 或互動模式保持運行才會按時執行。")
+		}
+	case "help":
+		printCommandLineHelpMessage() // 呼叫新的命令行幫助函數
+	default:
+		fmt.Fprintf(msgOut, "無效的啓動參數：\"%s\"。\n", arg)
+		fmt.Fprintln(msgOut, "用法：./actool [--status | --acon [分鐘] | --acoff | --timer <HH:MM> | --temp <溫度> | --mode <模式> | --wind <風速> | --schedule ... | --help]")
+		fmt.Fprintln(msgOut, "例如：./actool --acon 30 開啟空調30分鐘")
+		fmt.Fprintln(msgOut, "例如：./actool --timer 23:30 在23:30關閉空調")
+		fmt.Fprintln(msgOut, "例如：./actool --acon --at 06:30 --for 1h 在06:30開啟空調1小時")
+		return errUsage
+	}
+	return nil
 }
 
-// runInteractiveMode 運行互動模式的主循環
-func runInteractiveMode(token, deviceNo, studentName string) {
+// runInteractiveMode 運行互動模式的主循環，/use 可切換操作的設備，原設備的定時器仍在後台執行
+// 以 /exit 或 Ctrl-C 退出時按 onExit 策略處理所有使用過的設備的空調及未完成的定時器
+func runInteractiveMode(ctx context.Context, devices *deviceSet, session *deviceSession, onExit exitPolicy) {
+	c, deviceNo, timers := session.client, session.profile.DeviceNo, session.timers
+	exitAll := func() {
+		for _, s := range devices.opened() {
+			applyExitPolicy(s.client, s.profile.DeviceNo, s.timers, onExit)
+		}
+	}
 	// 先獲取基本設備信息並顯示
-	fmt.Println("\n執行獲取設備信息功能...")
-	deviceInfo, statusCode, err := getDeviceInfo(deviceNo, token)
+	fmt.Fprintln(msgOut, "\n執行獲取設備信息功能...")
+	deviceInfo, statusCode, err := c.GetDevice(ctx, deviceNo)
 	if err != nil {
-		fmt.Printf("錯誤: %v\n", err)
-		fmt.Println("請檢查您的配置或稍後再試。")
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		fmt.Fprintln(msgOut, "請檢查您的配置或稍後再試。")
 	} else {
-		printDeviceInfo(deviceInfo, statusCode)
+		printDeviceInfo(deviceInfo, statusCode, timers.Actions())
 	}
 	// 在顯示設備資訊後再顯示進入互動模式的提示
-	fmt.Println("\n未檢測到命令行參數，進入互動模式。輸入 /help 獲取使用幫助。")
+	fmt.Fprintln(msgOut, "\n未檢測到命令行參數，進入互動模式。輸入 /help 獲取使用幫助。")
+
+	// 在後台讀取標準輸入，以便在等待輸入時也能響應 Ctrl-C
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	// 執行命令期間需要用戶輸入 (例如重新授權) 時，從同一來源讀取
+	readPromptLine = func(ctx context.Context) (string, error) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				return "", io.EOF
+			}
+			return line, nil
+		}
+	}
 
 	// 進入互動模式的無限循環
-	scanner := bufio.NewScanner(os.Stdin)
 	for {
-		// 在每次循環開始時處理定時器
-		if timerActive {
-			// 為了避免在每次循環中都進行網絡請求，只在必要時觸發關閉
-			// 或者在一個單獨的 goroutine 中進行定時監聽和操作
-			// 這裡為了簡化，仍然在主循環中檢查
-			remaining := timerEndTime.Sub(time.Now())
-			if remaining <= 0 {
-				fmt.Println("\n定時器已到期，正在自動關閉空調...")
-				deviceInfo, statusCode, err = getDeviceInfo(deviceNo, token) // 確保有最新的設備信息
-				if err != nil {
-					fmt.Printf("獲取設備信息失敗以執行自動關閉: %v\n", err)
-					fmt.Printf("回應狀態碼：%d\n", statusCode)
-				} else {
-					_, _, _, opErr := operateDevice(deviceInfo, token, "acoff", studentName)
-					if opErr != nil {
-						fmt.Printf("自動關閉空調失敗: %v\n", opErr)
-					} else {
-						fmt.Println("空調已自動關閉。")
-					}
+		if len(devices.profiles) > 1 {
+			fmt.Fprintf(msgOut, "[%s]> ", session.profile.Name) // 配置了多個設備時在提示符中顯示當前設備
+		} else {
+			fmt.Fprint(msgOut, "> ") // 將提示符改為 "> "
+		}
+		var line string
+		var ok bool
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(msgOut, "\n收到中斷信號。")
+			exitAll()
+			fmt.Fprintln(msgOut, "程式已退出。")
+			return
+		case line, ok = <-lines:
+		}
+		if !ok {
+			// 標準輸入已關閉 (例如在後台運行)，定時器由後台調度器負責，等待其完成後退出
+			for _, s := range devices.opened() {
+				if len(s.timers.Actions()) == 0 {
+					continue
+				}
+				fmt.Fprintf(msgOut, "\n標準輸入已關閉，等待設備 %s 的定時器到期...\n", s.profile.Name)
+				if s.timers.Wait(ctx) != nil {
+					exitAll()
+					break
 				}
-				timerActive = false // 定時器完成
 			}
+			return
 		}
-
-		fmt.Print("> ") // 將提示符改為 "> "
-		scanner.Scan()
-		input := strings.TrimSpace(scanner.Text())
-		commandParts := strings.Fields(strings.ToLower(input)) // 將輸入分割為命令和參數
+		input := strings.TrimSpace(line)
+		commandParts := strings.Fields(input) // 將輸入分割為命令和參數
 
 		if len(commandParts) == 0 {
 			continue // 忽略空輸入
 		}
 
-		command := commandParts[0]
-		args := commandParts[1:]
+		command := strings.ToLower(commandParts[0])
+		args := strings.Fields(strings.ToLower(strings.Join(commandParts[1:], " ")))
 
 		switch command {
+		case "/auth":
+			runAuth(ctx, devices, session, commandParts[1:]) // OAuth code 區分大小寫，使用原始輸入
 		case "/status":
-			fmt.Println("\n正在獲取設備信息...")
-			deviceInfo, statusCode, err = getDeviceInfo(deviceNo, token)
+			fmt.Fprintln(msgOut, "\n正在獲取設備信息...")
+			deviceInfo, statusCode, err := c.GetDevice(ctx, deviceNo)
 			if err != nil {
-				fmt.Printf("錯誤: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
+				fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+				fmt.Fprintf(msgOut, "回應狀態碼：%d\n", statusCode)
 			} else {
-				printDeviceInfo(deviceInfo, statusCode)
+				printDeviceInfo(deviceInfo, statusCode, timers.Actions())
 			}
 		case "/acon":
-			var minutes int
-			if len(args) > 0 {
-				minutesStr := args[0]
-				var parseErr error
-				minutes, parseErr = strconv.Atoi(minutesStr)
-				if parseErr != nil || minutes <= 0 {
-					fmt.Println("錯誤: /acon 後的定時分鐘數無效。請輸入正整數。")
-					break
-				}
-			}
-
-			fmt.Println("\n正在開啟空調...")
-			deviceInfo, statusCode, err = getDeviceInfo(deviceNo, token) // 重新獲取最新設備狀態
+			req, err := parseAconArgs(args)
 			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
+				fmt.Fprintf(msgOut, "錯誤: /acon 的參數無效: %v\n", err)
 				break
 			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acon", studentName)
-			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
-				if minutes > 0 {
-					timerActive = true
-					timerEndTime = time.Now().Add(time.Duration(minutes) * time.Minute)
-					timerDuration = time.Duration(minutes) * time.Minute
-					timerDescription = fmt.Sprintf("%d分鐘", minutes)
-					fmt.Printf("已設定空調在 %d 分鐘後自動關閉。\n", minutes)
-				} else {
-					timerActive = false // 無定時
-				}
+			req = session.profile.withDefaults(req)
+			if _, err := runAcon(ctx, c, deviceNo, timers, req); (err == nil || errors.Is(err, errUnconfirmed)) && !req.timed() {
+				timers.CancelAutoOff() // 無定時
 			}
 		case "/acoff":
-			fmt.Println("\n正在關閉空調...")
-			deviceInfo, statusCode, err = getDeviceInfo(deviceNo, token) // 重新獲取最新設備狀態
-			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
+			fmt.Fprintln(msgOut, "\n正在關閉空調...")
+			if _, err := operateAC(ctx, c, deviceNo, client.CommandAirClose); err != nil {
 				break
 			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acoff", studentName)
-			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
-				timerActive = false // 關閉空調時取消所有定時
-				fmt.Println("定時器已取消。")
+			if timers.CancelAutoOff() { // 關閉空調時取消自動關閉的定時
+				fmt.Fprintln(msgOut, "定時器已取消。")
 			}
 		case "/timer":
 			if len(args) == 0 {
-				fmt.Println("錯誤: /timer 需要時間參數，例如 /timer 01:30。")
+				fmt.Fprintln(msgOut, "錯誤: /timer 需要時間參數，例如 /timer 01:30。")
 				break
 			}
-			timeStr := args[0] // HH:MM
-			now := time.Now()
-			targetTime, parseErr := time.Parse("15:04", timeStr)
-			if parseErr != nil {
-				fmt.Println("錯誤: /timer 後的時間格式無效。請使用 HH:MM 格式。")
+			if args[0] == "cancel" {
+				cancelTimers(timers, args[1:])
 				break
 			}
-
-			// 構建目標時間的日期部分，考慮是否為第二天
-			targetDateTime := time.Date(now.Year(), now.Month(), now.Day(), targetTime.Hour(), targetTime.Minute(), 0, 0, now.Location())
-			if targetDateTime.Before(now) {
-				targetDateTime = targetDateTime.Add(24 * time.Hour) // 如果目標時間已過，則為第二天
+			// 開啟空調並設定指定時間關閉
+			runAcon(ctx, c, deviceNo, timers, session.profile.withDefaults(aconRequest{Until: args[0]}))
+		case "/temp", "/mode", "/wind":
+			kind, _ := parseSettingKind(command)
+			if len(args) == 0 {
+				printSettingUsage(kind, "/")
+				break
 			}
-
-			// 在設定定時後，開啟空調
-			fmt.Println("\n正在開啟空調並設定定時...")
-			deviceInfo, statusCode, err = getDeviceInfo(deviceNo, token) // 重新獲取最新設備狀態
-			if err != nil {
-				fmt.Printf("獲取設備信息失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", statusCode)
+			adjustAC(ctx, c, deviceNo, acSetting{Kind: kind, Value: args[0]})
+		case "/schedule":
+			runScheduleCommand(localSchedules{timers}, "/schedule", args)
+		case "/help":
+			printInteractiveHelpMessage() // 呼叫原有的互動模式幫助函數
+		case "/use":
+			if len(args) == 0 {
+				printProfiles(devices.profiles, session.profile.Name)
 				break
 			}
-			operateStatusCode, msgID, operateDeviceNo, err := operateDevice(deviceInfo, token, "acon", studentName)
+			p, err := findProfile(devices.profiles, args[0])
 			if err != nil {
-				fmt.Printf("空調操作失敗: %v\n", err)
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
+				fmt.Fprintf(msgOut, "錯誤: %v\n", err)
 				break
-			} else {
-				fmt.Println("==reponse==")
-				fmt.Printf("回應狀態碼：%d\n", operateStatusCode)
-				fmt.Println("==回應訊息==")
-				fmt.Printf("訊息：%s\n", msgID)
-				fmt.Printf("設備號：%s\n", operateDeviceNo)
-				fmt.Println("===========")
-
-				timerActive = true
-				timerEndTime = targetDateTime
-				timerDuration = targetDateTime.Sub(now)
-				timerDescription = fmt.Sprintf("指定時間 %s", timeStr)
-				fmt.Printf("已設定空調將在 %s (%s 後) 自動關閉。\n", timerEndTime.Format("15:04:05"), timerDuration.String())
 			}
-		case "/help":
-			printInteractiveHelpMessage() // 呼叫原有的互動模式幫助函數
+			session = devices.open(ctx, p)
+			c, deviceNo, timers = session.client, session.profile.DeviceNo, session.timers
+			fmt.Fprintf(msgOut, "已切換到設備 %s (%s)。\n", p.Name, p.DeviceNo)
 		case "/exit", "/quit": // 允許 /exit 或 /quit 退出
-			fmt.Println("程式已退出。")
+			exitAll()
+			fmt.Fprintln(msgOut, "程式已退出。")
 			return // 退出 main 函數，結束程式
 		default:
-			fmt.Println("無效的命令。請輸入 /help 查看可用命令。")
+			fmt.Fprintln(msgOut, "無效的命令。請輸入 /help 查看可用命令。")
 		}
 	}
 }
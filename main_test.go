@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// newMockSession 函數用於創建連接到模擬 hatch-api 的設備會話，定時器不會被保存，測試結束時停止調度器
+func newMockSession(t *testing.T, server *mockserver.Server) *deviceSession {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	c := client.New("t", "x")
+	c.BaseURL = ts.URL
+	c.Verify.Timeout = 0
+	c.Retry.MaxAttempts = 1
+	p := deviceProfile{Name: defaultProfileName, DeviceNo: server.Device().DeviceNo}
+	return &deviceSession{profile: p, client: c, timers: startScheduler(ctx, c, p.DeviceNo, nil)}
+}
+
+// waitFor 函數用於輪詢直到 cond 成立，超時則以 msg 使測試失敗
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待超時: %s", msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// hasCommand 函數用於判斷模擬服務是否收到了指定的操作指令
+func hasCommand(server *mockserver.Server, command string) bool {
+	return slices.Contains(server.Commands(), command)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"actool/client"
+)
+
+// metricsCacheTTL 為設備信息的緩存時間，避免頻繁抓取時過多地請求 hatch-api
+const metricsCacheTTL = 10 * time.Second
+
+// latencyBuckets 為 hatch-api 請求延遲直方圖的上界 (秒)
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram 結構體為一個累積直方圖
+type histogram struct {
+	counts []uint64 // 各上界的累積計數，與 latencyBuckets 對應
+	count  uint64
+	sum    float64
+}
+
+// observe 方法用於記錄一個觀測值
+func (h *histogram) observe(v float64) {
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// metricsCollector 結構體用於統計 hatch-api 請求並在 /metrics 以 Prometheus 文本格式輸出設備狀態
+type metricsCollector struct {
+	session *deviceSession
+
+	mu       sync.Mutex
+	latency  map[string]*histogram // 按接口名稱統計的請求延遲
+	errors   map[[2]string]uint64  // 按接口名稱及錯誤代碼統計的錯誤次數
+	device   *client.DeviceInfo    // 最近一次獲取的設備信息
+	fetched  time.Time             // 最近一次獲取設備信息的時間
+	up       bool                  // 最近一次獲取設備信息是否成功
+	onTime   time.Duration         // 累計觀測到空調開啟的時長
+	lastSeen time.Time             // 最近一次成功觀測空調狀態的時間
+	lastOn   bool                  // 最近一次觀測時空調是否開啟
+	fetching sync.Mutex            // 保證同一時間只有一個抓取請求向 hatch-api 獲取設備信息
+}
+
+// newMetricsCollector 函數用於創建統計器，須在設備會話創建後設定 session
+func newMetricsCollector() *metricsCollector {
+	return &metricsCollector{
+		latency: make(map[string]*histogram),
+		errors:  make(map[[2]string]uint64),
+	}
+}
+
+// observe 方法用作 client.Client 的 Observe 回調
+func (m *metricsCollector) observe(info client.RequestInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := m.latency[info.Endpoint]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[info.Endpoint] = h
+	}
+	h.observe(info.Duration.Seconds())
+	if info.Err != nil {
+		m.errors[[2]string{info.Endpoint, errorCodeLabel(info)}]++
+	}
+}
+
+// errorCodeLabel 函數用於返回錯誤的 code 標籤：API 錯誤為響應中的 code，HTTP 錯誤為 http_<狀態碼>，網絡錯誤為 network
+func errorCodeLabel(info client.RequestInfo) string {
+	var apiErr *client.APIError
+	switch {
+	case errors.As(info.Err, &apiErr):
+		return strconv.Itoa(info.Code)
+	case info.StatusCode >= 400:
+		return "http_" + strconv.Itoa(info.StatusCode)
+	case errors.Is(info.Err, context.Canceled), errors.Is(info.Err, context.DeadlineExceeded):
+		return "timeout"
+	case info.StatusCode == 0:
+		return "network"
+	}
+	return "invalid_response"
+}
+
+// refresh 方法用於在緩存過期時重新獲取設備信息，並累計空調開啟的時長
+// 開啟時長按相鄰兩次觀測估算：上次觀測時開啟，則將其間的時長計入
+func (m *metricsCollector) refresh(ctx context.Context) {
+	m.fetching.Lock()
+	defer m.fetching.Unlock()
+	m.mu.Lock()
+	fresh := time.Since(m.fetched) < metricsCacheTTL
+	m.mu.Unlock()
+	if fresh {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	s := m.session
+	device, _, err := s.client.GetDevice(ctx, s.profile.DeviceNo)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.fetched = now
+	m.up = err == nil
+	if err != nil {
+		return
+	}
+	m.device = device
+	on := device.DeviceFan != nil && device.DeviceFan.FanStatus == 1
+	if m.lastOn && !m.lastSeen.IsZero() {
+		m.onTime += now.Sub(m.lastSeen)
+	}
+	m.lastOn, m.lastSeen = on, now
+}
+
+// ServeHTTP 方法用於輸出 Prometheus 文本格式的指標
+func (m *metricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.refresh(r.Context())
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write 方法用於寫入所有指標
+func (m *metricsCollector) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	device := label("device", m.session.profile.DeviceNo)
+
+	up := 0.0
+	if m.up {
+		up = 1
+	}
+	writeMetric(w, "actool_device_up", "gauge", "最近一次獲取設備信息是否成功", device, up)
+	if d := m.device; d != nil {
+		writeMetric(w, "actool_balance", "gauge", "電費餘額", device, d.Balance)
+		if fan := d.DeviceFan; fan != nil {
+			writeMetric(w, "actool_current_temperature_celsius", "gauge", "室溫", device, fan.CurrentTemp)
+			writeMetric(w, "actool_return_temperature_celsius", "gauge", "回風溫度", device, fan.ReturnTemp)
+			writeMetric(w, "actool_temperature_setting_celsius", "gauge", "設定溫度", device, fan.TempSetting)
+			writeMetric(w, "actool_fan_status", "gauge", "空調是否開啟，1 為開啟", device, float64(fan.FanStatus))
+			writeMetric(w, "actool_fan_mode", "gauge", "deviceFan.fanModel 的原始值，各取值的含義未經驗證", device, float64(fan.FanModel))
+			writeMetric(w, "actool_wind_speed", "gauge", "deviceFan.windSpeed 的原始值，各取值的含義未經驗證", device, float64(fan.WindSpeed))
+		}
+	}
+	writeMetric(w, "actool_ac_on_seconds_total", "counter", "按相鄰兩次觀測估算的空調累計開啟時長", device, m.onTime.Seconds())
+	writeMetric(w, "actool_scheduled_timers", "gauge", "待執行的定時器數量", device, float64(len(m.session.timers.Actions())))
+
+	const latencyName = "actool_hatch_api_request_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s hatch-api 請求的延遲，每次重試分別計算\n# TYPE %s histogram\n", latencyName, latencyName)
+	for _, endpoint := range slices.Sorted(maps.Keys(m.latency)) {
+		h := m.latency[endpoint]
+		labels := label("endpoint", endpoint)
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", latencyName, labels, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", latencyName, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", latencyName, labels, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", latencyName, labels, h.count)
+	}
+
+	const errorsName = "actool_hatch_api_errors_total"
+	fmt.Fprintf(w, "# HELP %s hatch-api 請求失敗的次數，code 為響應中的錯誤代碼、http_<狀態碼>、network 或 timeout\n# TYPE %s counter\n", errorsName, errorsName)
+	keys := make([][2]string, 0, len(m.errors))
+	for k := range m.errors {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b [2]string) int {
+		return strings.Compare(a[0]+"\x00"+a[1], b[0]+"\x00"+b[1])
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s,%s} %d\n", errorsName, label("endpoint", k[0]), label("code", k[1]), m.errors[k])
+	}
+}
+
+// writeMetric 函數用於寫入只有一個樣本的指標
+func writeMetric(w io.Writer, name, kind, help, labels string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s{%s} %s\n", name, help, name, kind, name, labels, formatFloat(value))
+}
+
+// label 函數用於格式化一個標籤，並轉義標籤值中的反斜線、引號與換行
+func label(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return name + `="` + value + `"`
+}
+
+// formatFloat 函數用於格式化指標的數值
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+)
+
+// TestErrorCodeLabel 測試請求錯誤的 code 標籤分類
+func TestErrorCodeLabel(t *testing.T) {
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name string
+		info client.RequestInfo
+		want string
+	}{
+		{"API 錯誤", client.RequestInfo{StatusCode: 200, Code: 401, Err: &client.APIError{Code: 401}}, "401"},
+		{"HTTP 錯誤狀態碼的 API 錯誤", client.RequestInfo{StatusCode: 500, Code: 1, Err: fmt.Errorf("空調操作: %w", &client.APIError{Code: 1, StatusCode: 500})}, "1"},
+		{"HTTP 錯誤", client.RequestInfo{StatusCode: 502, Err: errors.New("HTTP 502")}, "http_502"},
+		{"超時", client.RequestInfo{Err: fmt.Errorf("發送請求失敗: %w", context.DeadlineExceeded)}, "timeout"},
+		{"取消", client.RequestInfo{Err: context.Canceled}, "timeout"},
+		{"網絡錯誤", client.RequestInfo{Err: netErr}, "network"},
+		{"無效響應", client.RequestInfo{StatusCode: 200, Err: errors.New("解析響應失敗")}, "invalid_response"},
+	}
+	for _, tt := range tests {
+		if got := errorCodeLabel(tt.info); got != tt.want {
+			t.Errorf("%s: errorCodeLabel = %q，應為 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestMetricsWrite 測試 /metrics 的完整輸出，包括直方圖的累積計數
+func TestMetricsWrite(t *testing.T) {
+	s := newMockSession(t, mockserver.New("D1"))
+	s.timers.Schedule(scheduledAction{Command: client.CommandAirClose, At: time.Now().Add(time.Hour)})
+	m := newMetricsCollector()
+	m.session = s
+	m.up = true
+	m.onTime = 90 * time.Second
+	m.device = &client.DeviceInfo{Balance: 12.5, DeviceFan: &client.DeviceFan{
+		FanStatus: 1, TempSetting: 26, FanModel: 1, WindSpeed: 3, ReturnTemp: 27.5, CurrentTemp: 28,
+	}}
+
+	for _, info := range []client.RequestInfo{
+		{Endpoint: client.EndpointGetDevice, Duration: 50 * time.Millisecond, StatusCode: 200},
+		{Endpoint: client.EndpointGetDevice, Duration: 300 * time.Millisecond, StatusCode: 200},
+		{Endpoint: client.EndpointGetDevice, Duration: 4 * time.Second, Err: context.DeadlineExceeded},
+		{Endpoint: client.EndpointOperate, Duration: 125 * time.Millisecond, StatusCode: 503, Err: errors.New("HTTP 503")},
+		{Endpoint: client.EndpointOperate, Duration: 12 * time.Second, StatusCode: 503, Err: errors.New("HTTP 503")},
+		{Endpoint: client.EndpointOperate, Duration: time.Second, StatusCode: 200, Code: 7, Err: &client.APIError{Code: 7}},
+	} {
+		m.observe(info)
+	}
+
+	var b strings.Builder
+	m.write(&b)
+	want := `# HELP actool_device_up 最近一次獲取設備信息是否成功
+# TYPE actool_device_up gauge
+actool_device_up{device="D1"} 1
+# HELP actool_balance 電費餘額
+# TYPE actool_balance gauge
+actool_balance{device="D1"} 12.5
+# HELP actool_current_temperature_celsius 室溫
+# TYPE actool_current_temperature_celsius gauge
+actool_current_temperature_celsius{device="D1"} 28
+# HELP actool_return_temperature_celsius 回風溫度
+# TYPE actool_return_temperature_celsius gauge
+actool_return_temperature_celsius{device="D1"} 27.5
+# HELP actool_temperature_setting_celsius 設定溫度
+# TYPE actool_temperature_setting_celsius gauge
+actool_temperature_setting_celsius{device="D1"} 26
+# HELP actool_fan_status 空調是否開啟，1 為開啟
+# TYPE actool_fan_status gauge
+actool_fan_status{device="D1"} 1
+# HELP actool_fan_mode deviceFan.fanModel 的原始值，各取值的含義未經驗證
+# TYPE actool_fan_mode gauge
+actool_fan_mode{device="D1"} 1
+# HELP actool_wind_speed deviceFan.windSpeed 的原始值，各取值的含義未經驗證
+# TYPE actool_wind_speed gauge
+actool_wind_speed{device="D1"} 3
+# HELP actool_ac_on_seconds_total 按相鄰兩次觀測估算的空調累計開啟時長
+# TYPE actool_ac_on_seconds_total counter
+actool_ac_on_seconds_total{device="D1"} 90
+# HELP actool_scheduled_timers 待執行的定時器數量
+# TYPE actool_scheduled_timers gauge
+actool_scheduled_timers{device="D1"} 1
+# HELP actool_hatch_api_request_duration_seconds hatch-api 請求的延遲，每次重試分別計算
+# TYPE actool_hatch_api_request_duration_seconds histogram
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="0.05"} 1
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="0.1"} 1
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="0.25"} 1
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="0.5"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="1"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="2.5"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="5"} 3
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="10"} 3
+actool_hatch_api_request_duration_seconds_bucket{endpoint="getDeviceByNo",le="+Inf"} 3
+actool_hatch_api_request_duration_seconds_sum{endpoint="getDeviceByNo"} 4.35
+actool_hatch_api_request_duration_seconds_count{endpoint="getDeviceByNo"} 3
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="0.05"} 0
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="0.1"} 0
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="0.25"} 1
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="0.5"} 1
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="1"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="2.5"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="5"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="10"} 2
+actool_hatch_api_request_duration_seconds_bucket{endpoint="operateDevice",le="+Inf"} 3
+actool_hatch_api_request_duration_seconds_sum{endpoint="operateDevice"} 13.125
+actool_hatch_api_request_duration_seconds_count{endpoint="operateDevice"} 3
+# HELP actool_hatch_api_errors_total hatch-api 請求失敗的次數，code 為響應中的錯誤代碼、http_<狀態碼>、network 或 timeout
+# TYPE actool_hatch_api_errors_total counter
+actool_hatch_api_errors_total{endpoint="getDeviceByNo",code="timeout"} 1
+actool_hatch_api_errors_total{endpoint="operateDevice",code="7"} 1
+actool_hatch_api_errors_total{endpoint="operateDevice",code="http_503"} 2
+`
+	if got := b.String(); got != want {
+		t.Errorf("輸出不符，實際為:\n%s", got)
+	}
+}
+
+// TestLabelEscape 測試標籤值中的特殊字符被轉義
+func TestLabelEscape(t *testing.T) {
+	if got, want := label("device", "a\"b\\c\nd"), `device="a\"b\\c\nd"`; got != want {
+		t.Errorf("label = %s，應為 %s", got, want)
+	}
+}
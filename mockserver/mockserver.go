@@ -0,0 +1,332 @@
+// Package mockserver 提供一個本地的 hatch-api 模擬服務，
+// 內含一台有狀態的假設備，便於離線開發與集成測試。
+package mockserver
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"actool/client"
+)
+
+// 模擬服務使用的錯誤代碼
+const (
+	CodeOK           = 0   // 成功
+	CodeBadRequest   = 400 // 請求參數錯誤
+	CodeUnauthorized = 401 // Token 無效
+	CodeNotFound     = 404 // 設備不存在
+)
+
+// AuthPath 為模擬服務中以 OAuth code 換取 Token 的接口路徑，真實 hatch-api 的路徑未知
+const AuthPath = "/wechat/login"
+
+// DefaultRatePerHour 為空調開啟時每小時扣除的默認電費
+const DefaultRatePerHour = 1.0
+
+// Server 結構體為模擬的 hatch-api 服務，實現 http.Handler
+type Server struct {
+	Token       string        // 若不為空，則要求請求頭中的 Token 與之相同；以 OAuth code 換取 Token 後會被替換
+	TokenTTL    time.Duration // 若大於 0，Token 在簽發 (或服務啟動) 後經過該時長即過期
+	RatePerHour float64       // 空調開啟時每小時扣除的電費
+	DropRate    float64       // 開關指令返回成功但未被設備執行的機率 (0-1)，用於模擬指令未送達
+	Compression string        // 若為 "gzip" 或 "deflate" 且請求接受該編碼，則壓縮響應體
+
+	mu       sync.Mutex
+	issued   time.Time       // 當前 Token 的簽發時間
+	codes    map[string]bool // 已使用的 OAuth code，每個 code 只能使用一次
+	device   client.DeviceInfo
+	settled  time.Time // 上次結算電費的時間
+	msgSeq   int64
+	commands []string // 已收到的操作指令，按順序記錄
+	now      func() time.Time
+	mux      *http.ServeMux
+}
+
+// New 函數用於創建一台指定設備號的模擬設備，初始為關閉狀態
+func New(deviceNo string) *Server {
+	s := &Server{
+		RatePerHour: DefaultRatePerHour,
+		now:         time.Now,
+		device:      defaultDevice(deviceNo),
+	}
+	s.settled = s.now()
+	s.issued = s.now()
+	s.codes = make(map[string]bool)
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /device/getDeviceByNo", s.handleGetDevice)
+	s.mux.HandleFunc("POST /device/operateDevice", s.handleOperate)
+	s.mux.HandleFunc("GET "+AuthPath, s.handleAuth)
+	return s
+}
+
+// ServeHTTP 方法用於分發請求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Compression == "" || !strings.Contains(r.Header.Get("Accept-Encoding"), s.Compression) {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+
+	var zw io.WriteCloser
+	switch s.Compression {
+	case "gzip":
+		zw = gzip.NewWriter(w)
+	case "deflate":
+		zw = zlib.NewWriter(w)
+	default:
+		http.Error(w, "不支持的壓縮方式: "+s.Compression, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Encoding", s.Compression)
+	w.Header().Add("Vary", "Accept-Encoding")
+	s.mux.ServeHTTP(compressWriter{ResponseWriter: w, w: zw}, r)
+	zw.Close()
+}
+
+// compressWriter 結構體用於將響應體寫入壓縮器
+type compressWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+// Write 方法用於寫入壓縮後的響應體
+func (cw compressWriter) Write(p []byte) (int, error) {
+	return cw.w.Write(p)
+}
+
+// Device 方法用於返回當前模擬設備狀態的副本
+func (s *Server) Device() client.DeviceInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settleLocked()
+	return s.copyLocked()
+}
+
+// SetBalance 方法用於調整模擬設備的電費餘額
+func (s *Server) SetBalance(balance float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settleLocked()
+	s.device.Balance = balance
+}
+
+// Commands 方法用於返回已收到的操作指令列表
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// handleGetDevice 方法用於處理 getDeviceByNo 請求
+func (s *Server) handleGetDevice(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.URL.Query().Get("deviceNo") != s.device.DeviceNo {
+		writeJSON(w, map[string]any{"code": CodeNotFound, "msg": "設備不存在", "data": nil})
+		return
+	}
+	s.settleLocked()
+	writeJSON(w, client.GetAPIResponse{Code: CodeOK, Msg: "success", Data: s.copyLocked()})
+}
+
+// handleOperate 方法用於處理 operateDevice 請求
+func (s *Server) handleOperate(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var payload client.DeviceInfo
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "請求格式錯誤: " + err.Error(), "data": nil})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if payload.DeviceNo != s.device.DeviceNo {
+		writeJSON(w, map[string]any{"code": CodeNotFound, "msg": "設備不存在", "data": nil})
+		return
+	}
+
+	s.settleLocked()
+	fan := s.device.DeviceFan
+	switch payload.CommandKey {
+	case client.CommandAirOpen:
+		if s.device.Balance <= 0 {
+			writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "餘額不足", "data": nil})
+			return
+		}
+		if s.droppedLocked() {
+			break
+		}
+		fan.FanStatusOld = fan.FanStatus
+		fan.FanStatus = 1
+	case client.CommandAirClose:
+		if s.droppedLocked() {
+			break
+		}
+		fan.FanStatusOld = fan.FanStatus
+		fan.FanStatus = 0
+	case client.CommandAirTemp:
+		if payload.DeviceFan == nil || client.ValidateTemp(&s.device, payload.DeviceFan.TempSetting) != nil {
+			writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "溫度超出範圍", "data": nil})
+			return
+		}
+		fan.TempSetting = payload.DeviceFan.TempSetting
+	case client.CommandAirMode:
+		if payload.DeviceFan == nil || !client.ValidMode(payload.DeviceFan.FanModel) {
+			writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "未知的運行模式", "data": nil})
+			return
+		}
+		fan.FanModel = payload.DeviceFan.FanModel
+	case client.CommandAirWind:
+		if payload.DeviceFan == nil || !client.ValidWind(payload.DeviceFan.WindSpeed) {
+			writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "未知的風速", "data": nil})
+			return
+		}
+		fan.WindSpeed = payload.DeviceFan.WindSpeed
+	default:
+		writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "未知的 commandKey: " + payload.CommandKey, "data": nil})
+		return
+	}
+	s.device.CommandKey = payload.CommandKey
+	s.device.LastCommunication = s.now().Format("2006-01-02 15:04:05")
+	s.commands = append(s.commands, payload.CommandKey)
+
+	s.msgSeq++
+	var resp client.OperateAPIResponse
+	resp.Code = CodeOK
+	resp.Msg = "success"
+	resp.Data.MsgID = strconv.FormatInt(s.now().UnixNano()/1000+s.msgSeq, 10)
+	resp.Data.DeviceNo = s.device.DeviceNo
+	writeJSON(w, resp)
+}
+
+// handleAuth 方法用於處理以 OAuth code 換取 Token 的請求，任意未使用過的 code 均可換取一個新的 Token
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code == "" || s.codes[code] {
+		writeJSON(w, map[string]any{"code": CodeBadRequest, "msg": "code 無效或已使用", "data": nil})
+		return
+	}
+	s.codes[code] = true
+	s.msgSeq++
+	s.Token = fmt.Sprintf("mock-token-%d-%d", s.now().Unix(), s.msgSeq)
+	s.issued = s.now()
+
+	var resp client.AuthAPIResponse
+	resp.Code = CodeOK
+	resp.Msg = "success"
+	resp.Data.Token = s.Token
+	writeJSON(w, resp)
+}
+
+// droppedLocked 方法用於按 DropRate 決定開關指令是否被丟棄，調用前須持有鎖
+// 與真實服務相同，被丟棄的指令仍返回成功，但設備狀態不變
+func (s *Server) droppedLocked() bool {
+	return s.DropRate > 0 && rand.Float64() < s.DropRate
+}
+
+// authorized 方法用於校驗請求頭中的 Token
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := r.Header.Get("Token")
+	if token == "" || (s.Token != "" && token != s.Token) {
+		writeJSON(w, map[string]any{"code": CodeUnauthorized, "msg": "token 無效或已過期", "data": nil})
+		return false
+	}
+	if s.TokenTTL > 0 && s.now().Sub(s.issued) > s.TokenTTL {
+		writeJSON(w, map[string]any{"code": CodeUnauthorized, "msg": "登錄已過期，請重新授權", "data": nil})
+		return false
+	}
+	return true
+}
+
+// settleLocked 方法用於按空調開啟的時長扣除電費，調用前須持有鎖
+func (s *Server) settleLocked() {
+	now := s.now()
+	if s.device.DeviceFan.FanStatus == 1 {
+		s.device.Balance -= now.Sub(s.settled).Hours() * s.RatePerHour
+		if s.device.Balance <= 0 {
+			// 欠費時設備自動斷電
+			s.device.Balance = 0
+			s.device.DeviceFan.FanStatus = 0
+		}
+	}
+	s.settled = now
+}
+
+// copyLocked 方法用於返回設備狀態的深拷貝，調用前須持有鎖
+func (s *Server) copyLocked() client.DeviceInfo {
+	device := s.device
+	fan := *s.device.DeviceFan
+	device.DeviceFan = &fan
+	return device
+}
+
+// writeJSON 函數用於輸出 JSON 響應，hatch-api 無論成功與否都返回 200
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, fmt.Sprintf("編碼響應失敗: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// defaultDevice 函數用於構建模擬設備的初始狀態
+func defaultDevice(deviceNo string) client.DeviceInfo {
+	return client.DeviceInfo{
+		ID:              "mock-device",
+		ManufactorID:    "mock-manufactor",
+		ModelID:         "mock-model",
+		GatewayID:       "mock-gateway",
+		CampusID:        "mock-campus",
+		BuildingID:      "mock-building",
+		FloorID:         "mock-floor",
+		RoomID:          "mock-room",
+		DeviceType:      2,
+		DeviceNo:        deviceNo,
+		Status:          1,
+		CampusTitle:     "模擬校區",
+		BuildingTitle:   "1號樓",
+		FloorTitle:      "3層",
+		RoomNo:          "301",
+		ManufactorTitle: "模擬廠商",
+		ModelTitle:      "模擬空調",
+		GatewayNo:       "000000000000",
+		IsInstallFinish: 1,
+		Balance:         50,
+		DeviceFan: &client.DeviceFan{
+			ID:          "mock-fan",
+			DeviceID:    "mock-device",
+			FanType:     1,
+			FanStatus:   0,
+			TempSetting: 26,
+			FanModel:    1,
+			WindSpeed:   0,
+			MaxTemp:     30,
+			MinTemp:     16,
+			ReturnTemp:  28,
+			CurrentTemp: 28,
+		},
+	}
+}
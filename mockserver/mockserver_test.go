@@ -0,0 +1,153 @@
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"actool/client"
+)
+
+// fakeClock 結構體為可手動推進的時鐘，用於測試電費結算
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now 方法用於返回當前的模擬時間
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance 方法用於推進模擬時間
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestClient 函數用於創建連接到模擬服務的客戶端，不重試且不確認指令送達
+func newTestClient(t *testing.T, s *Server) *client.Client {
+	t.Helper()
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	c := client.New("t", "x")
+	c.BaseURL = ts.URL
+	c.Retry.MaxAttempts = 1
+	c.Verify.Timeout = 0
+	return c
+}
+
+// TestSwitchSettlement 測試經客戶端獲取設備、開關空調，以及按開啟時長結算電費直至欠費斷電
+func TestSwitchSettlement(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)}
+	s := New("D1")
+	s.now = clock.Now
+	s.settled = clock.Now()
+	s.RatePerHour = 2
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	device, _, err := c.GetDevice(ctx, "D1")
+	if err != nil {
+		t.Fatalf("GetDevice: %v", err)
+	}
+	if device.DeviceFan.FanStatus != 0 || device.Balance != 50 {
+		t.Fatalf("初始設備狀態為 %+v", device)
+	}
+	if _, _, err := c.GetDevice(ctx, "D2"); !errors.Is(err, client.ErrDeviceOffline) {
+		t.Errorf("不存在的設備返回 %v", err)
+	}
+
+	if _, err := c.Switch(ctx, "D1", client.CommandAirOpen); err != nil {
+		t.Fatalf("Switch 開啟: %v", err)
+	}
+	clock.Advance(90 * time.Minute)
+	if got := s.Device(); got.DeviceFan.FanStatus != 1 || math.Abs(got.Balance-47) > 1e-9 {
+		t.Errorf("開啟 1.5 小時後狀態為 %d，餘額為 %g，應為 1 與 47", got.DeviceFan.FanStatus, got.Balance)
+	}
+
+	if _, err := c.Switch(ctx, "D1", client.CommandAirClose); err != nil {
+		t.Fatalf("Switch 關閉: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if got := s.Device(); got.DeviceFan.FanStatus != 0 || math.Abs(got.Balance-47) > 1e-9 {
+		t.Errorf("關閉後狀態為 %d，餘額為 %g，應不再扣費", got.DeviceFan.FanStatus, got.Balance)
+	}
+	if got := s.Commands(); !slices.Equal(got, []string{client.CommandAirOpen, client.CommandAirClose}) {
+		t.Errorf("收到的指令為 %v", got)
+	}
+
+	// 餘額耗盡時設備自動斷電，之後拒絕開啟
+	s.SetBalance(1)
+	if _, err := c.Switch(ctx, "D1", client.CommandAirOpen); err != nil {
+		t.Fatalf("Switch 開啟: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if got := s.Device(); got.DeviceFan.FanStatus != 0 || got.Balance != 0 {
+		t.Errorf("欠費後狀態為 %d，餘額為 %g，應斷電且餘額為 0", got.DeviceFan.FanStatus, got.Balance)
+	}
+	var apiErr *client.APIError
+	if _, err := c.Switch(ctx, "D1", client.CommandAirOpen); !errors.As(err, &apiErr) || apiErr.Code != CodeBadRequest {
+		t.Errorf("餘額為 0 時開啟返回 %v", err)
+	}
+}
+
+// TestDropRate 測試被丟棄的指令返回成功但設備狀態不變，Switch 的確認結果為未確認
+func TestDropRate(t *testing.T) {
+	s := New("D1")
+	s.DropRate = 1
+	c := newTestClient(t, s)
+	c.Verify = client.VerifyOptions{Timeout: 50 * time.Millisecond, InitialDelay: 10 * time.Millisecond, Resends: 1}
+
+	result, err := c.Switch(context.Background(), "D1", client.CommandAirOpen)
+	if err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if v := result.Verification; v == nil || v.Confirmed || v.FanStatus != 0 || v.Resent != 1 {
+		t.Errorf("確認結果為 %+v，應為未確認且重發 1 次", v)
+	}
+	if got := s.Commands(); len(got) != 2 {
+		t.Errorf("收到 %d 個指令，應為 2 個 (含重發)", len(got))
+	}
+	if s.Device().DeviceFan.FanStatus != 0 {
+		t.Error("被丟棄的指令改變了設備狀態")
+	}
+}
+
+// TestCompression 測試按 Compression 壓縮響應，客戶端不接受該編碼時返回原文
+func TestCompression(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			s := New("D1")
+			s.Compression = encoding
+			c := newTestClient(t, s)
+			if device, _, err := c.GetDevice(context.Background(), "D1"); err != nil || device.DeviceNo != "D1" {
+				t.Fatalf("GetDevice = %+v, %v", device, err)
+			}
+
+			for _, accept := range []string{encoding, "identity"} {
+				req := httptest.NewRequest(http.MethodGet, "/device/getDeviceByNo?deviceNo=D1", nil)
+				req.Header.Set("Token", "t")
+				req.Header.Set("Accept-Encoding", accept)
+				rec := httptest.NewRecorder()
+				s.ServeHTTP(rec, req)
+				want := ""
+				if accept == encoding {
+					want = encoding
+				}
+				if got := rec.Header().Get("Content-Encoding"); got != want {
+					t.Errorf("Accept-Encoding 為 %s 時 Content-Encoding = %q，應為 %q", accept, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Broker 結構體為一個本地的 MQTT 代理，只在內存中保存訂閱與保留訊息，用於離線開發與集成測試
+// 所有訂閱均以 QoS 0 投遞；收到 QoS 1 的發布時回覆 PUBACK，不支持 QoS 2
+type Broker struct {
+	Username string // 若不為空，則要求客戶端提供相同的用戶名與密碼
+	Password string
+
+	mu       sync.Mutex
+	conns    map[*brokerConn]bool
+	retained map[string]Message
+	closed   bool
+}
+
+// brokerConn 結構體為代理上的一個客戶端連接
+type brokerConn struct {
+	conn    net.Conn
+	wmu     sync.Mutex
+	id      string
+	filters map[string]bool // 受 Broker.mu 保護
+	will    *Message
+}
+
+// NewBroker 函數用於創建代理
+func NewBroker() *Broker {
+	return &Broker{conns: make(map[*brokerConn]bool), retained: make(map[string]Message)}
+}
+
+// Serve 方法用於在 l 上接受連接，直到 l 被關閉
+func (b *Broker) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			b.mu.Lock()
+			closed := b.closed
+			b.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go b.handle(conn)
+	}
+}
+
+// Close 方法用於斷開所有客戶端，不發布遺囑訊息
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	conns := b.conns
+	b.conns = make(map[*brokerConn]bool)
+	b.mu.Unlock()
+	for c := range conns {
+		c.conn.Close()
+	}
+}
+
+// Retained 方法用於返回主題當前的保留訊息
+func (b *Broker) Retained(topic string) (Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.retained[topic]
+	return m, ok
+}
+
+// Publish 方法用於由代理本身發布訊息
+func (b *Broker) Publish(m Message) {
+	m.QoS = 0
+	b.mu.Lock()
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	var targets []*brokerConn
+	for c := range b.conns {
+		for f := range c.filters {
+			if matchTopic(f, m.Topic) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	m.Retain = false // 轉發給已訂閱的客戶端時不帶保留標誌
+	for _, c := range targets {
+		c.send(m)
+	}
+}
+
+// handle 方法用於處理一個客戶端連接
+func (b *Broker) handle(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	p, err := readPacket(br)
+	if err != nil || p.kind != packetConnect {
+		return
+	}
+	c, keepAlive, code := b.accept(conn, p)
+	writePacket(conn, packetConnack, 0, []byte{0, code})
+	if code != 0 {
+		return
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	// 同一客戶端標識符重新連接時斷開舊的連接
+	for old := range b.conns {
+		if old.id == c.id && c.id != "" {
+			delete(b.conns, old)
+			old.conn.Close()
+		}
+	}
+	b.conns[c] = true
+	b.mu.Unlock()
+
+	clean := c.serve(b, br, keepAlive)
+
+	b.mu.Lock()
+	_, current := b.conns[c]
+	delete(b.conns, c)
+	b.mu.Unlock()
+	if !clean && current && c.will != nil {
+		b.Publish(*c.will)
+	}
+}
+
+// accept 方法用於解析 CONNECT 報文，返回 CONNACK 的返回碼
+func (b *Broker) accept(conn net.Conn, p packet) (*brokerConn, time.Duration, byte) {
+	r := &reader{b: p.body}
+	protocol := r.string()
+	level := r.byte()
+	flags := r.byte()
+	keepAlive := time.Duration(r.uint16()) * time.Second
+	c := &brokerConn{conn: conn, id: r.string(), filters: make(map[string]bool)}
+	if r.err != nil || protocol != "MQTT" {
+		return nil, 0, 1
+	}
+	if level != 4 {
+		return nil, 0, 1
+	}
+	if flags&flagWill != 0 {
+		topic, payload := r.string(), r.bytes()
+		c.will = &Message{Topic: topic, Payload: append([]byte(nil), payload...), Retain: flags&flagWillRetain != 0}
+	}
+	var username, password string
+	if flags&flagUsername != 0 {
+		username = r.string()
+	}
+	if flags&flagPassword != 0 {
+		password = r.string()
+	}
+	if r.err != nil {
+		return nil, 0, 2
+	}
+	if b.Username != "" && (username != b.Username || password != b.Password) {
+		return nil, 0, 4
+	}
+	return c, keepAlive, 0
+}
+
+// serve 方法用於處理已連接客戶端的報文，客戶端發送 DISCONNECT 時返回 true
+func (c *brokerConn) serve(b *Broker, br *bufio.Reader, keepAlive time.Duration) bool {
+	for {
+		if keepAlive > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+		} else {
+			c.conn.SetReadDeadline(time.Time{})
+		}
+		p, err := readPacket(br)
+		if err != nil {
+			return false
+		}
+		switch p.kind {
+		case packetPublish:
+			m, id, err := decodePublish(p)
+			if err != nil || m.QoS == 2 || ValidTopic(m.Topic) != nil {
+				return false
+			}
+			if m.QoS == 1 {
+				c.write(packetPuback, 0, binary.BigEndian.AppendUint16(nil, id))
+			}
+			b.Publish(m)
+		case packetSubscribe:
+			if !c.subscribe(b, p) {
+				return false
+			}
+		case packetUnsubscribe:
+			r := &reader{b: p.body}
+			id := r.uint16()
+			b.mu.Lock()
+			for len(r.b) > 0 && r.err == nil {
+				delete(c.filters, r.string())
+			}
+			b.mu.Unlock()
+			if r.err != nil {
+				return false
+			}
+			c.write(packetUnsuback, 0, binary.BigEndian.AppendUint16(nil, id))
+		case packetPingreq:
+			c.write(packetPingresp, 0, nil)
+		case packetDisconnect:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// subscribe 方法用於處理 SUBSCRIBE 報文，確認後投遞匹配的保留訊息
+func (c *brokerConn) subscribe(b *Broker, p packet) bool {
+	r := &reader{b: p.body}
+	id := r.uint16()
+	var filters []string
+	for len(r.b) > 0 && r.err == nil {
+		filters = append(filters, r.string())
+		r.byte() // 請求的 QoS，均以 QoS 0 授予
+	}
+	if r.err != nil || len(filters) == 0 {
+		return false
+	}
+
+	codes := binary.BigEndian.AppendUint16(nil, id)
+	var retained []Message
+	b.mu.Lock()
+	for _, f := range filters {
+		if !validFilter(f) {
+			codes = append(codes, 0x80)
+			continue
+		}
+		codes = append(codes, 0)
+		c.filters[f] = true
+		for topic, m := range b.retained {
+			if matchTopic(f, topic) {
+				retained = append(retained, m)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	if c.write(packetSuback, 0, codes) != nil {
+		return false
+	}
+	for _, m := range retained {
+		c.send(m)
+	}
+	return true
+}
+
+// send 方法用於向客戶端投遞訊息
+func (c *brokerConn) send(m Message) {
+	flags, body := encodePublish(m, 0)
+	c.write(packetPublish, flags, body)
+}
+
+// write 方法用於寫入報文，客戶端過慢時斷開連接
+func (c *brokerConn) write(kind, flags byte, body []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := writePacket(c.conn, kind, flags, body); err != nil {
+		c.conn.Close()
+		return errors.Join(ErrClosed, err)
+	}
+	return nil
+}
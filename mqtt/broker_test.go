@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// startBroker 函數用於在隨機端口啟動代理，測試結束時關閉，configure 不為 nil 時在啟動前調用
+func startBroker(t *testing.T, configure func(*Broker)) (*Broker, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBroker()
+	if configure != nil {
+		configure(b)
+	}
+	go b.Serve(l)
+	t.Cleanup(func() {
+		l.Close()
+		b.Close()
+	})
+	return b, "tcp://" + l.Addr().String()
+}
+
+// dial 函數用於連接代理，測試結束時斷開
+func dial(t *testing.T, broker string, opts Options) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, broker, opts)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// subscribe 函數用於訂閱主題過濾器
+func subscribe(t *testing.T, c *Client, filters ...string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Subscribe(ctx, filters...); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+}
+
+// receive 函數用於等待下一條訊息
+func receive(t *testing.T, c *Client) Message {
+	t.Helper()
+	select {
+	case m := <-c.Messages():
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待訊息超時")
+		return Message{}
+	}
+}
+
+// TestBrokerPublishSubscribe 測試訂閱後收到匹配的訊息，以及訂閱時收到已有的保留訊息
+func TestBrokerPublishSubscribe(t *testing.T) {
+	b, addr := startBroker(t, nil)
+	pub := dial(t, addr, Options{ClientID: "pub"})
+	if err := pub.Publish("actool/D1/state", []byte("on"), true); err != nil {
+		t.Fatal(err)
+	}
+	// 發布是異步的，等待代理保存保留訊息
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := b.Retained("actool/D1/state"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("保留訊息未被保存")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sub := dial(t, addr, Options{ClientID: "sub"})
+	subscribe(t, sub, "actool/+/state", "actool/D1/+/set")
+	if m := receive(t, sub); m.Topic != "actool/D1/state" || string(m.Payload) != "on" {
+		t.Errorf("保留訊息為 %s=%s", m.Topic, m.Payload)
+	}
+
+	pub.Publish("other/topic", []byte("ignored"), false)
+	pub.Publish("actool/D1/power/set", []byte("OFF"), false)
+	if m := receive(t, sub); m.Topic != "actool/D1/power/set" || string(m.Payload) != "OFF" || m.Retain {
+		t.Errorf("收到 %+v", m)
+	}
+
+	// 發布空的保留訊息會清除保留訊息
+	b.Publish(Message{Topic: "actool/D1/state", Retain: true})
+	receive(t, sub)
+	if _, ok := b.Retained("actool/D1/state"); ok {
+		t.Error("空的保留訊息未清除保留訊息")
+	}
+}
+
+// TestBrokerWill 測試連接異常斷開時發布遺囑訊息，正常斷開時不發布
+func TestBrokerWill(t *testing.T) {
+	b, addr := startBroker(t, nil)
+	watcher := dial(t, addr, Options{ClientID: "watcher"})
+	subscribe(t, watcher, "+/availability")
+
+	will := &Message{Topic: "clean/availability", Payload: []byte("offline"), Retain: true}
+	clean := dial(t, addr, Options{ClientID: "clean", Will: will})
+	clean.Close()
+
+	will = &Message{Topic: "dropped/availability", Payload: []byte("offline"), Retain: true}
+	dropped := dial(t, addr, Options{ClientID: "dropped", Will: will})
+	dropped.conn.Close() // 不發送 DISCONNECT
+
+	if m := receive(t, watcher); m.Topic != "dropped/availability" || string(m.Payload) != "offline" {
+		t.Errorf("收到 %s=%s，應為 dropped 的遺囑訊息", m.Topic, m.Payload)
+	}
+	if m, ok := b.Retained("dropped/availability"); !ok || string(m.Payload) != "offline" {
+		t.Error("遺囑訊息未被保留")
+	}
+	if _, ok := b.Retained("clean/availability"); ok {
+		t.Error("正常斷開時不應發布遺囑訊息")
+	}
+}
+
+// TestBrokerTakeover 測試同一客戶端標識符重新連接時斷開舊的連接，且不發布舊連接的遺囑訊息
+func TestBrokerTakeover(t *testing.T) {
+	b, addr := startBroker(t, nil)
+	will := &Message{Topic: "dev/availability", Payload: []byte("offline"), Retain: true}
+	old := dial(t, addr, Options{ClientID: "dev", Will: will})
+	dial(t, addr, Options{ClientID: "dev", Will: will})
+
+	select {
+	case <-old.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("舊的連接未被斷開")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := b.Retained("dev/availability"); ok {
+		t.Error("被接管的連接不應發布遺囑訊息")
+	}
+}
+
+// TestBrokerAuth 測試用戶名與密碼驗證
+func TestBrokerAuth(t *testing.T) {
+	_, addr := startBroker(t, func(b *Broker) { b.Username, b.Password = "user", "secret" })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if c, err := Dial(ctx, addr, Options{ClientID: "x", Username: "user", Password: "wrong"}); err == nil {
+		c.Close()
+		t.Fatal("密碼錯誤時應拒絕連接")
+	}
+	dial(t, addr, Options{ClientID: "x", Username: "user", Password: "secret"})
+}
+
+// TestParseBrokerURL 測試代理地址的解析
+func TestParseBrokerURL(t *testing.T) {
+	tests := []struct {
+		broker string
+		addr   string
+		tls    bool
+	}{
+		{"tcp://127.0.0.1:1883", "127.0.0.1:1883", false},
+		{"mqtt://broker", "broker:1883", false},
+		{"mqtts://broker", "broker:8883", true},
+		{"ssl://broker:1884", "broker:1884", true},
+		{"broker.local", "broker.local:1883", false},
+		{"[::1]:1883", "[::1]:1883", false},
+	}
+	for _, tt := range tests {
+		addr, useTLS, err := ParseBrokerURL(tt.broker)
+		if err != nil || addr != tt.addr || useTLS != tt.tls {
+			t.Errorf("ParseBrokerURL(%q) = %q, %v, %v", tt.broker, addr, useTLS, err)
+		}
+	}
+	for _, broker := range []string{"", "http://broker"} {
+		if _, _, err := ParseBrokerURL(broker); err == nil {
+			t.Errorf("ParseBrokerURL(%q) 應返回錯誤", broker)
+		}
+	}
+}
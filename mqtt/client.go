@@ -0,0 +1,349 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultKeepAlive 為默認的心跳間隔
+const DefaultKeepAlive = 60 * time.Second
+
+// ErrClosed 表示連接已關閉
+var ErrClosed = errors.New("MQTT 連接已關閉")
+
+// connackErrors 為 CONNACK 返回碼對應的錯誤描述
+var connackErrors = map[byte]string{
+	1: "不支持的協議版本",
+	2: "客戶端標識符被拒絕",
+	3: "服務不可用",
+	4: "用戶名或密碼錯誤",
+	5: "未獲授權",
+}
+
+// Options 結構體為連接代理時使用的選項
+type Options struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration // 心跳間隔，0 為 DefaultKeepAlive
+	Will      *Message      // 連接異常斷開時由代理發布的遺囑訊息
+}
+
+// Client 結構體為一個 MQTT 連接，僅支持 clean session
+type Client struct {
+	conn     net.Conn
+	messages chan Message
+
+	wmu sync.Mutex // 保護對 conn 的寫入
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan []byte // 等待 SUBACK 的訂閱請求
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// ParseBrokerURL 函數用於解析代理地址，返回 host:port 及是否使用 TLS
+// 支持 tcp://、mqtt://、ssl://、tls://、mqtts:// 前綴或不帶前綴的 host[:port]，默認端口為 1883，TLS 為 8883
+func ParseBrokerURL(broker string) (addr string, useTLS bool, err error) {
+	hostport := broker
+	if u, err := url.Parse(broker); err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "tcp", "mqtt":
+		case "ssl", "tls", "mqtts":
+			useTLS = true
+		default:
+			return "", false, fmt.Errorf("不支持的協議 %q，請使用 tcp:// 或 mqtts://", u.Scheme)
+		}
+		hostport = u.Host
+	}
+	if hostport == "" {
+		return "", false, errors.New("代理地址不能為空")
+	}
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		port := "1883"
+		if useTLS {
+			port = "8883"
+		}
+		hostport = net.JoinHostPort(hostport, port)
+	}
+	return hostport, useTLS, nil
+}
+
+// Dial 函數用於連接 MQTT 代理，broker 的格式見 ParseBrokerURL
+func Dial(ctx context.Context, broker string, opts Options) (*Client, error) {
+	addr, useTLS, err := ParseBrokerURL(broker)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("連接 MQTT 代理 %s 失敗: %w", addr, err)
+	}
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("與 MQTT 代理 %s 的 TLS 握手失敗: %w", addr, err)
+		}
+		conn = tlsConn
+	}
+
+	c, err := handshake(ctx, conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake 函數用於發送 CONNECT 並等待 CONNACK，成功後開始接收報文
+func handshake(ctx context.Context, conn net.Conn, opts Options) (*Client, error) {
+	keepAlive := opts.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = DefaultKeepAlive
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	flags := byte(flagCleanSession)
+	if opts.Will != nil {
+		flags |= flagWill | opts.Will.QoS<<3
+		if opts.Will.Retain {
+			flags |= flagWillRetain
+		}
+	}
+	if opts.Username != "" {
+		flags |= flagUsername
+	}
+	if opts.Password != "" {
+		flags |= flagPassword
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(keepAlive/time.Second))
+	body = appendString(body, opts.ClientID)
+	if opts.Will != nil {
+		body = appendString(body, opts.Will.Topic)
+		body = appendString(body, string(opts.Will.Payload))
+	}
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendString(body, opts.Password)
+	}
+	if err := writePacket(conn, packetConnect, 0, body); err != nil {
+		return nil, fmt.Errorf("發送 CONNECT 失敗: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	p, err := readPacket(br)
+	if err != nil {
+		return nil, fmt.Errorf("等待 CONNACK 失敗: %w", err)
+	}
+	if p.kind != packetConnack || len(p.body) != 2 {
+		return nil, fmt.Errorf("%w：預期 CONNACK，收到類型 %d", errMalformed, p.kind)
+	}
+	if code := p.body[1]; code != 0 {
+		if msg, ok := connackErrors[code]; ok {
+			return nil, fmt.Errorf("MQTT 代理拒絕連接: %s", msg)
+		}
+		return nil, fmt.Errorf("MQTT 代理拒絕連接，返回碼 %d", code)
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := &Client{
+		conn:     conn,
+		messages: make(chan Message, 16),
+		pending:  make(map[uint16]chan []byte),
+		done:     make(chan struct{}),
+	}
+	go c.readLoop(br, keepAlive)
+	go c.keepAlive(keepAlive)
+	return c, nil
+}
+
+// Messages 方法用於返回已訂閱主題收到的訊息
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Done 方法用於返回連接關閉時關閉的通道
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err 方法用於返回連接關閉的原因，主動調用 Close 時為 ErrClosed
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Publish 方法用於以 QoS 0 發布訊息
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	if err := ValidTopic(topic); err != nil {
+		return err
+	}
+	flags, body := encodePublish(Message{Topic: topic, Payload: payload, Retain: retain}, 0)
+	return c.write(packetPublish, flags, body)
+}
+
+// Subscribe 方法用於以 QoS 0 訂閱主題過濾器，並等待代理確認
+func (c *Client) Subscribe(ctx context.Context, filters ...string) error {
+	c.mu.Lock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	id := c.nextID
+	ack := make(chan []byte, 1)
+	c.pending[id] = ack
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, f := range filters {
+		if !validFilter(f) {
+			return fmt.Errorf("無效的主題過濾器 %q", f)
+		}
+		body = appendString(body, f)
+		body = append(body, 0)
+	}
+	if err := c.write(packetSubscribe, 0x02, body); err != nil {
+		return err
+	}
+	select {
+	case codes := <-ack:
+		for i, code := range codes {
+			if code == 0x80 && i < len(filters) {
+				return fmt.Errorf("MQTT 代理拒絕訂閱 %s", filters[i])
+			}
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close 方法用於發送 DISCONNECT 並關閉連接，代理不會發布遺囑訊息
+func (c *Client) Close() error {
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	c.write(packetDisconnect, 0, nil)
+	c.fail(ErrClosed)
+	return nil
+}
+
+// write 方法用於寫入報文，寫入超時或失敗時關閉連接
+func (c *Client) write(kind, flags byte, body []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := writePacket(c.conn, kind, flags, body); err != nil {
+		c.fail(fmt.Errorf("寫入 MQTT 報文失敗: %w", err))
+		return err
+	}
+	return nil
+}
+
+// fail 方法用於以 err 關閉連接，只有第一次調用生效
+func (c *Client) fail(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// readLoop 方法用於接收並處理代理發送的報文，超過 1.5 倍心跳間隔未收到任何報文時視為連接中斷
+func (c *Client) readLoop(br *bufio.Reader, keepAlive time.Duration) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+		p, err := readPacket(br)
+		if err != nil {
+			c.fail(fmt.Errorf("MQTT 連接中斷: %w", err))
+			return
+		}
+		switch p.kind {
+		case packetPublish:
+			m, id, err := decodePublish(p)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+			if m.QoS == 1 {
+				c.write(packetPuback, 0, binary.BigEndian.AppendUint16(nil, id))
+			}
+			select {
+			case c.messages <- m:
+			case <-c.done:
+				return
+			}
+		case packetSuback:
+			r := &reader{b: p.body}
+			id := r.uint16()
+			if r.err != nil {
+				c.fail(errMalformed)
+				return
+			}
+			c.mu.Lock()
+			ack := c.pending[id]
+			c.mu.Unlock()
+			if ack != nil {
+				ack <- r.b
+			}
+		case packetPingresp, packetPuback, packetUnsuback:
+		default:
+			c.fail(fmt.Errorf("%w：未預期的報文類型 %d", errMalformed, p.kind))
+			return
+		}
+	}
+}
+
+// keepAlive 方法用於每半個心跳間隔發送一次 PINGREQ
+func (c *Client) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.write(packetPingreq, 0, nil)
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package mqtt 提供精簡的 MQTT 3.1.1 客戶端與本地代理 (broker)，
+// 只使用標準庫，支持 QoS 0 發布與訂閱、保留訊息 (retain) 及遺囑訊息 (will)。
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 控制報文類型
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+// CONNECT 報文的連接標誌
+const (
+	flagCleanSession = 0x02
+	flagWill         = 0x04
+	flagWillRetain   = 0x20
+	flagPassword     = 0x40
+	flagUsername     = 0x80
+)
+
+// maxPacketSize 為接受的報文剩餘長度上限，避免異常報文佔用過多內存
+const maxPacketSize = 1 << 20
+
+// errMalformed 表示報文格式錯誤
+var errMalformed = errors.New("MQTT 報文格式錯誤")
+
+// Message 結構體為一條 MQTT 訊息
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// packet 結構體為一個控制報文
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// readPacket 函數用於讀取一個控制報文
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	var length, shift int
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, fmt.Errorf("%w：剩餘長度超過 4 字節", errMalformed)
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if length > maxPacketSize {
+		return packet{}, fmt.Errorf("%w：報文過大 (%d 字節)", errMalformed, length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// writePacket 函數用於寫入一個控制報文
+func writePacket(w io.Writer, kind, flags byte, body []byte) error {
+	buf := make([]byte, 0, 5+len(body))
+	buf = append(buf, kind<<4|flags)
+	length := len(body)
+	for {
+		b := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if length == 0 {
+			break
+		}
+	}
+	buf = append(buf, body...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// appendString 函數用於以 2 字節長度前綴寫入字串
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// reader 結構體用於依次讀取報文中的欄位，出錯後的讀取均返回零值
+type reader struct {
+	b   []byte
+	err error
+}
+
+// byte 方法用於讀取一個字節
+func (r *reader) byte() byte {
+	if r.err != nil || len(r.b) < 1 {
+		r.err = errMalformed
+		return 0
+	}
+	v := r.b[0]
+	r.b = r.b[1:]
+	return v
+}
+
+// uint16 方法用於讀取一個 2 字節整數
+func (r *reader) uint16() uint16 {
+	if r.err != nil || len(r.b) < 2 {
+		r.err = errMalformed
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return v
+}
+
+// bytes 方法用於讀取一段帶 2 字節長度前綴的數據
+func (r *reader) bytes() []byte {
+	n := int(r.uint16())
+	if r.err != nil || len(r.b) < n {
+		r.err = errMalformed
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+// string 方法用於讀取一個字串
+func (r *reader) string() string {
+	return string(r.bytes())
+}
+
+// encodePublish 函數用於編碼 PUBLISH 報文，QoS 大於 0 時帶上報文標識符
+func encodePublish(m Message, id uint16) (flags byte, body []byte) {
+	flags = m.QoS << 1
+	if m.Retain {
+		flags |= 0x01
+	}
+	body = appendString(nil, m.Topic)
+	if m.QoS > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	return flags, append(body, m.Payload...)
+}
+
+// decodePublish 函數用於解碼 PUBLISH 報文
+func decodePublish(p packet) (m Message, id uint16, err error) {
+	r := &reader{b: p.body}
+	m.Topic = r.string()
+	m.QoS = (p.flags >> 1) & 0x03
+	m.Retain = p.flags&0x01 != 0
+	if m.QoS > 0 {
+		id = r.uint16()
+	}
+	if r.err != nil || m.QoS > 2 {
+		return Message{}, 0, errMalformed
+	}
+	m.Payload = r.b
+	return m, id, nil
+}
+
+// ValidTopic 函數用於檢查發布用的主題名，不能為空或包含通配符
+func ValidTopic(topic string) error {
+	if topic == "" {
+		return errors.New("主題不能為空")
+	}
+	for _, c := range topic {
+		if c == '+' || c == '#' || c == 0 {
+			return fmt.Errorf("主題 %q 不能包含 +、# 或空字符", topic)
+		}
+	}
+	return nil
+}
+
+// validFilter 函數用於檢查訂閱用的主題過濾器，"+" 須佔據整個層級，"#" 須位於最後一層
+func validFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	levels := splitTopic(filter)
+	for i, level := range levels {
+		switch {
+		case level == "#":
+			if i != len(levels)-1 {
+				return false
+			}
+		case level == "+":
+		default:
+			for _, c := range level {
+				if c == '+' || c == '#' || c == 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// matchTopic 函數用於判斷主題是否匹配過濾器
+// 以 "$" 開頭的主題 (例如 $SYS) 不匹配首層為通配符的過濾器
+func matchTopic(filter, topic string) bool {
+	f, t := splitTopic(filter), splitTopic(topic)
+	if len(t[0]) > 0 && t[0][0] == '$' && (f[0] == "+" || f[0] == "#") {
+		return false
+	}
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// splitTopic 函數用於按 "/" 分割主題的層級
+func splitTopic(topic string) []string {
+	var levels []string
+	start := 0
+	for i := 0; i < len(topic); i++ {
+		if topic[i] == '/' {
+			levels = append(levels, topic[start:i])
+			start = i + 1
+		}
+	}
+	return append(levels, topic[start:])
+}
@@ -0,0 +1,158 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestPacketRoundTrip 測試報文的編碼與解碼，包括剩餘長度在各字節數邊界上的編碼
+func TestPacketRoundTrip(t *testing.T) {
+	tests := []struct {
+		length     int
+		lengthSize int // 剩餘長度佔用的字節數
+	}{
+		{0, 1}, {1, 1}, {127, 1}, {128, 2}, {16383, 2}, {16384, 3}, {maxPacketSize, 3},
+	}
+	for _, tt := range tests {
+		body := bytes.Repeat([]byte{0xa5}, tt.length)
+		var buf bytes.Buffer
+		if err := writePacket(&buf, packetPublish, 0x03, body); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.Len(), 1+tt.lengthSize+tt.length; got != want {
+			t.Errorf("剩餘長度 %d: 報文長度為 %d，應為 %d", tt.length, got, want)
+		}
+		p, err := readPacket(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatalf("剩餘長度 %d: %v", tt.length, err)
+		}
+		if p.kind != packetPublish || p.flags != 0x03 || !bytes.Equal(p.body, body) {
+			t.Errorf("剩餘長度 %d: 解碼結果不一致 (kind=%d flags=%#x len=%d)", tt.length, p.kind, p.flags, len(p.body))
+		}
+	}
+}
+
+// TestReadPacketMalformed 測試讀取格式錯誤或過大的報文
+func TestReadPacketMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"剩餘長度超過 4 字節": {packetPingreq << 4, 0x80, 0x80, 0x80, 0x80, 0x01},
+		"報文過大":        {packetPublish << 4, 0x81, 0x80, 0x40}, // 1 MiB + 1
+	}
+	for name, raw := range tests {
+		if _, err := readPacket(bufio.NewReader(bytes.NewReader(raw))); !errors.Is(err, errMalformed) {
+			t.Errorf("%s: 錯誤為 %v，應為 errMalformed", name, err)
+		}
+	}
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{packetPublish << 4, 5, 0, 1}))); err == nil {
+		t.Error("報文體不完整時應返回錯誤")
+	}
+}
+
+// TestPublishRoundTrip 測試 PUBLISH 報文的編碼與解碼
+func TestPublishRoundTrip(t *testing.T) {
+	tests := []struct {
+		m  Message
+		id uint16
+	}{
+		{Message{Topic: "actool/D1/state", Payload: []byte(`{"fanStatus":1}`), Retain: true}, 0},
+		{Message{Topic: "a", Payload: nil}, 0},
+		{Message{Topic: "a/b", Payload: []byte("x"), QoS: 1}, 513},
+	}
+	for _, tt := range tests {
+		flags, body := encodePublish(tt.m, tt.id)
+		m, id, err := decodePublish(packet{kind: packetPublish, flags: flags, body: body})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.m.Topic, err)
+		}
+		if m.Topic != tt.m.Topic || !bytes.Equal(m.Payload, tt.m.Payload) || m.QoS != tt.m.QoS || m.Retain != tt.m.Retain || id != tt.id {
+			t.Errorf("%s: 解碼為 %+v (id=%d)，應為 %+v (id=%d)", tt.m.Topic, m, id, tt.m, tt.id)
+		}
+	}
+	if _, _, err := decodePublish(packet{kind: packetPublish, flags: 0x06, body: appendString(nil, "a")}); err == nil {
+		t.Error("QoS 3 應返回錯誤")
+	}
+	if _, _, err := decodePublish(packet{kind: packetPublish, body: []byte{0, 5, 'a'}}); err == nil {
+		t.Error("主題長度超出報文時應返回錯誤")
+	}
+}
+
+// TestMatchTopic 測試主題過濾器的匹配，用例取自 MQTT 3.1.1 規範第 4.7 節
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"sport/tennis/player1/#", "sport/tennis/player1", true},
+		{"sport/tennis/player1/#", "sport/tennis/player1/ranking", true},
+		{"sport/tennis/player1/#", "sport/tennis/player1/score/wimbledon", true},
+		{"sport/#", "sport", true},
+		{"#", "sport/tennis", true},
+		{"#", "/", true},
+		{"sport/tennis/+", "sport/tennis/player1", true},
+		{"sport/tennis/+", "sport/tennis/player1/ranking", false},
+		{"sport/+", "sport", false},
+		{"sport/+", "sport/", true},
+		{"+/+", "/finance", true},
+		{"/+", "/finance", true},
+		{"+", "/finance", false},
+		{"actool/+/set", "actool/power/set", true},
+		{"actool/D1/+/set", "actool/D1/power/set", true},
+		{"actool/D1/+/set", "actool/D2/power/set", false},
+		{"a/b", "a/b/c", false},
+		{"a/b/c", "a/b", false},
+		{"#", "$SYS/broker/uptime", false},
+		{"+/broker/uptime", "$SYS/broker/uptime", false},
+		{"$SYS/#", "$SYS/broker/uptime", true},
+		{"$SYS/+/uptime", "$SYS/broker/uptime", true},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v，應為 %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+// TestValidFilter 測試主題過濾器的檢查
+func TestValidFilter(t *testing.T) {
+	tests := map[string]bool{
+		"#":                true,
+		"+":                true,
+		"a/#":              true,
+		"+/+/#":            true,
+		"/":                true,
+		"$SYS/#":           true,
+		"":                 false,
+		"a/#/b":            false,
+		"a#":               false,
+		"a/b#":             false,
+		"a+":               false,
+		"a/+b/c":           false,
+		"a/\x00":           false,
+		"actool/D1/+/set":  true,
+		"homeassistant/#/": false,
+	}
+	for filter, want := range tests {
+		if got := validFilter(filter); got != want {
+			t.Errorf("validFilter(%q) = %v，應為 %v", filter, got, want)
+		}
+	}
+}
+
+// TestValidTopic 測試發布用主題名的檢查
+func TestValidTopic(t *testing.T) {
+	for _, topic := range []string{"a", "a/b", "/", "$SYS/x", "actool/D1/state"} {
+		if err := ValidTopic(topic); err != nil {
+			t.Errorf("ValidTopic(%q) = %v", topic, err)
+		}
+	}
+	for _, topic := range []string{"", "a/+", "#", "a/#/b", "a\x00b"} {
+		if err := ValidTopic(topic); err == nil {
+			t.Errorf("ValidTopic(%q) 應返回錯誤", topic)
+		} else if topic != "" && !strings.Contains(err.Error(), "不能包含") {
+			t.Errorf("ValidTopic(%q) = %v", topic, err)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"actool/client"
+	"actool/mqtt"
+)
+
+// MQTT 橋接的默認配置
+const (
+	defaultMQTTPrefix          = "actool"
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+	defaultMQTTInterval        = time.Minute
+)
+
+// haModes 為 Home Assistant climate 實體的模式與 client.ParseMode 名稱的對應
+// client 中模式與風速的編號及設定指令均為未經驗證的推測，見 client.CommandAirMode
+var haModes = map[string]string{
+	"cool":     "cool",
+	"heat":     "heat",
+	"fan_only": "fan",
+	"dry":      "dry",
+}
+
+// haFanModes 為 Home Assistant 的風速名稱與 client.ParseWind 名稱的對應
+var haFanModes = map[string]string{
+	"auto":   "auto",
+	"low":    "low",
+	"medium": "mid",
+	"high":   "high",
+}
+
+// mqttConfig 結構體為 MQTT 橋接的配置，來自 MQTT_* 配置項
+type mqttConfig struct {
+	Broker          string
+	Username        string
+	Password        string
+	Prefix          string        // 狀態與命令主題的前綴
+	DiscoveryPrefix string        // Home Assistant 自動發現的主題前綴，"none" 為不發布
+	Interval        time.Duration // 定期發布設備狀態的間隔
+}
+
+// mqttState 結構體為發布到狀態主題的設備狀態
+type mqttState struct {
+	FanStatus   int     `json:"fanStatus"`
+	Mode        string  `json:"mode"` // Home Assistant 的模式，關閉時為 off
+	FanMode     string  `json:"fanMode"`
+	TempSetting float64 `json:"tempSetting"`
+	CurrentTemp float64 `json:"currentTemp"`
+	Balance     float64 `json:"balance"`
+	Timers      int     `json:"timers"` // 待執行的定時器數量
+}
+
+// mqttBridge 結構體用於將設備狀態發布到 MQTT 代理，並將命令主題上的訊息轉換為空調操作
+type mqttBridge struct {
+	cfg     mqttConfig
+	session *deviceSession
+	base    string // 該設備的主題前綴，例如 actool/<設備號>
+	conn    *mqtt.Client
+}
+
+// newMQTTConfig 函數用於讀取 MQTT_* 配置，未設定 MQTT_BROKER 時返回 nil
+func newMQTTConfig(setting func(string) string) (*mqttConfig, error) {
+	broker := setting("MQTT_BROKER")
+	if broker == "" {
+		return nil, nil
+	}
+	cfg := &mqttConfig{
+		Broker:          broker,
+		Username:        setting("MQTT_USERNAME"),
+		Password:        setting("MQTT_PASSWORD"),
+		Prefix:          cmp.Or(setting("MQTT_PREFIX"), defaultMQTTPrefix),
+		DiscoveryPrefix: cmp.Or(setting("MQTT_DISCOVERY_PREFIX"), defaultMQTTDiscoveryPrefix),
+		Interval:        defaultMQTTInterval,
+	}
+	for _, key := range []string{"MQTT_BROKER", "MQTT_PREFIX", "MQTT_DISCOVERY_PREFIX", "MQTT_INTERVAL"} {
+		if value := setting(key); value != "" {
+			if err := validateConfigValue(key, value); err != nil {
+				return nil, fmt.Errorf("%s 無效: %w", key, err)
+			}
+		}
+	}
+	if interval := setting("MQTT_INTERVAL"); interval != "" {
+		cfg.Interval, _ = parseFlexibleDuration(interval)
+	}
+	return cfg, nil
+}
+
+// startMQTTBridge 函數用於在背景運行 s 對應設備的 MQTT 橋接，返回的函數用於停止橋接並等待其發布離線狀態
+// cfg 為 nil 時不做任何事
+func startMQTTBridge(ctx context.Context, cfg *mqttConfig, s *deviceSession) (stop func()) {
+	if cfg == nil {
+		return func() {}
+	}
+	b := &mqttBridge{cfg: *cfg, session: s, base: cfg.Prefix + "/" + s.profile.DeviceNo}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// topic 方法用於返回該設備下的主題
+func (b *mqttBridge) topic(name string) string {
+	return b.base + "/" + name
+}
+
+// run 方法用於保持與代理的連接，斷開後以指數退避重新連接，直到 ctx 被取消
+func (b *mqttBridge) run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		connected, err := b.serveOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		fmt.Fprintf(msgOut, "MQTT: %v，%s後重新連接。\n", err, formatDuration(backoff))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// serveOnce 方法用於建立一次連接並處理訊息，直到連接中斷或 ctx 被取消，返回是否曾成功連接
+func (b *mqttBridge) serveOnce(ctx context.Context) (bool, error) {
+	availability := b.topic("availability")
+	dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	conn, err := mqtt.Dial(dialCtx, b.cfg.Broker, mqtt.Options{
+		ClientID: "actool-" + b.session.profile.DeviceNo,
+		Username: b.cfg.Username,
+		Password: b.cfg.Password,
+		Will:     &mqtt.Message{Topic: availability, Payload: []byte("offline"), Retain: true},
+	})
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	b.conn = conn
+	defer conn.Close()
+
+	if err := conn.Subscribe(ctx, b.topic("+/set")); err != nil {
+		return true, fmt.Errorf("訂閱命令主題失敗: %w", err)
+	}
+	device := b.publishState(ctx)
+	if err := b.publishDiscovery(device); err != nil {
+		return true, fmt.Errorf("發布自動發現配置失敗: %w", err)
+	}
+	if err := conn.Publish(availability, []byte("online"), true); err != nil {
+		return true, err
+	}
+	fmt.Fprintf(msgOut, "MQTT: 已連接 %s，狀態主題 %s，命令主題 %s\n", b.cfg.Broker, b.topic("state"), b.topic("+/set"))
+
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			conn.Publish(availability, []byte("offline"), true)
+			return true, ctx.Err()
+		case <-conn.Done():
+			return true, conn.Err()
+		case <-ticker.C:
+			b.publishState(ctx)
+		case m := <-conn.Messages():
+			b.handleCommand(ctx, m)
+			b.publishState(ctx)
+		}
+	}
+}
+
+// publishState 方法用於獲取設備信息並發布狀態，返回獲取到的設備信息，失敗時為 nil
+func (b *mqttBridge) publishState(ctx context.Context) *client.DeviceInfo {
+	s := b.session
+	device, _, err := s.client.GetDevice(ctx, s.profile.DeviceNo)
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(msgOut, "MQTT: 獲取設備信息失敗: %v\n", err)
+		}
+		return nil
+	}
+	state := mqttState{Balance: math.Round(device.Balance*100) / 100, Mode: "off", Timers: len(s.timers.Actions())}
+	if fan := device.DeviceFan; fan != nil {
+		state.FanStatus = fan.FanStatus
+		state.TempSetting = fan.TempSetting
+		state.CurrentTemp = fan.CurrentTemp
+		state.FanMode = haName(haFanModes, client.WindName(fan.WindSpeed))
+		if fan.FanStatus == 1 {
+			state.Mode = haName(haModes, client.ModeName(fan.FanModel))
+		}
+	}
+	payload, _ := json.Marshal(state)
+	if err := b.conn.Publish(b.topic("state"), payload, true); err != nil {
+		fmt.Fprintf(msgOut, "MQTT: 發布狀態失敗: %v\n", err)
+	}
+	return device
+}
+
+// publishDiscovery 方法用於發布 Home Assistant 的自動發現配置，使設備顯示為 climate 實體，電費餘額為 sensor 實體
+// device 為 nil 時溫度範圍使用默認值
+func (b *mqttBridge) publishDiscovery(device *client.DeviceInfo) error {
+	if b.cfg.DiscoveryPrefix == "none" {
+		return nil
+	}
+	p := b.session.profile
+	id := "actool_" + p.DeviceNo
+	name := "宿舍空調"
+	if p.Name != defaultProfileName {
+		name += " " + p.Name
+	}
+	haDevice := map[string]any{
+		"identifiers":  []string{id},
+		"name":         name,
+		"manufacturer": "ACtool",
+		"model":        "hatch-api",
+	}
+	var fan *client.DeviceFan
+	if device != nil {
+		fan = device.DeviceFan
+		if device.RoomNo != "" {
+			haDevice["suggested_area"] = device.BuildingTitle + " " + device.RoomNo
+		}
+		if device.ModelTitle != "" {
+			haDevice["model"] = device.ModelTitle
+		}
+	}
+	minTemp, maxTemp := client.TempRange(fan)
+
+	state := b.topic("state")
+	climate := map[string]any{
+		"name":                         nil, // 沿用設備名稱
+		"unique_id":                    id,
+		"device":                       haDevice,
+		"availability_topic":           b.topic("availability"),
+		"modes":                        []string{"off", "cool", "heat", "fan_only", "dry"},
+		"mode_command_topic":           b.topic("mode/set"),
+		"mode_state_topic":             state,
+		"mode_state_template":          "{{ value_json.mode }}",
+		"power_command_topic":          b.topic("power/set"),
+		"temperature_command_topic":    b.topic("temperature/set"),
+		"temperature_state_topic":      state,
+		"temperature_state_template":   "{{ value_json.tempSetting }}",
+		"current_temperature_topic":    state,
+		"current_temperature_template": "{{ value_json.currentTemp }}",
+		"fan_modes":                    []string{"auto", "low", "medium", "high"},
+		"fan_mode_command_topic":       b.topic("fan_mode/set"),
+		"fan_mode_state_topic":         state,
+		"fan_mode_state_template":      "{{ value_json.fanMode }}",
+		"min_temp":                     minTemp,
+		"max_temp":                     maxTemp,
+		"temp_step":                    1,
+		"precision":                    1.0,
+		"temperature_unit":             "C",
+	}
+	if !b.session.client.ExperimentalSettings {
+		// 設定指令未啟用時溫度與風速只顯示不可調，選擇運行模式亦只會開啟空調後返回錯誤
+		delete(climate, "temperature_command_topic")
+		delete(climate, "fan_mode_command_topic")
+	}
+	balance := map[string]any{
+		"name":                "電費餘額",
+		"unique_id":           id + "_balance",
+		"device":              haDevice,
+		"availability_topic":  b.topic("availability"),
+		"state_topic":         state,
+		"value_template":      "{{ value_json.balance }}",
+		"state_class":         "measurement",
+		"icon":                "mdi:cash",
+		"unit_of_measurement": "元",
+	}
+	for topic, config := range map[string]any{
+		b.cfg.DiscoveryPrefix + "/climate/" + id + "/config":        climate,
+		b.cfg.DiscoveryPrefix + "/sensor/" + id + "_balance/config": balance,
+	} {
+		payload, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		if err := b.conn.Publish(topic, payload, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleCommand 方法用於執行命令主題上收到的操作，失敗時只輸出錯誤
+func (b *mqttBridge) handleCommand(ctx context.Context, m mqtt.Message) {
+	command := strings.TrimSuffix(strings.TrimPrefix(m.Topic, b.base+"/"), "/set")
+	value := strings.TrimSpace(string(m.Payload))
+	fmt.Fprintf(msgOut, "MQTT: 收到命令 %s = %s\n", command, value)
+	var err error
+	switch command {
+	case "power":
+		switch strings.ToUpper(value) {
+		case "ON":
+			err = b.turnOn(ctx)
+		case "OFF":
+			err = b.turnOff(ctx)
+		default:
+			err = fmt.Errorf("無效的開關命令 %q，請使用 ON 或 OFF", value)
+		}
+	case "mode":
+		err = b.setMode(ctx, value)
+	case "temperature":
+		// Home Assistant 發送的溫度帶有小數，例如 "26.0"
+		temp, perr := strconv.ParseFloat(value, 64)
+		if perr != nil {
+			err = fmt.Errorf("無效的溫度 %q", value)
+			break
+		}
+		err = b.apply(ctx, acSetting{Kind: "temp", Value: formatMQTTTemp(temp)})
+	case "fan_mode":
+		wind, ok := haFanModes[value]
+		if !ok {
+			err = fmt.Errorf("無效的風速 %q", value)
+			break
+		}
+		err = b.apply(ctx, acSetting{Kind: "wind", Value: wind})
+	default:
+		err = fmt.Errorf("未知的命令主題 %s", m.Topic)
+	}
+	if err != nil {
+		fmt.Fprintf(msgOut, "MQTT: 執行命令 %s 失敗: %v\n", command, err)
+	}
+}
+
+// setMode 方法用於按 Home Assistant 的模式開關空調或調整模式，空調關閉時先開啟
+func (b *mqttBridge) setMode(ctx context.Context, value string) error {
+	if value == "off" {
+		return b.turnOff(ctx)
+	}
+	mode, ok := haModes[value]
+	if !ok {
+		return fmt.Errorf("無效的模式 %q", value)
+	}
+	s := b.session
+	device, _, err := s.client.GetDevice(ctx, s.profile.DeviceNo)
+	if err != nil {
+		return err
+	}
+	if device.DeviceFan == nil || device.DeviceFan.FanStatus != 1 {
+		if err := b.turnOn(ctx); err != nil {
+			return err
+		}
+	}
+	return b.apply(ctx, acSetting{Kind: "mode", Value: mode})
+}
+
+// turnOn 方法用於按設備的默認設定開啟空調，與不帶參數的 actool --acon 相同
+func (b *mqttBridge) turnOn(ctx context.Context) error {
+	s := b.session
+	p, err := s.profile.withDefaults(aconRequest{}).plan(time.Now())
+	if err != nil {
+		return err
+	}
+	_, action, err := startAC(ctx, s.client, s.profile.DeviceNo, s.timers, p)
+	if err != nil && !errors.Is(err, errUnconfirmed) {
+		return err
+	}
+	if action != nil {
+		fmt.Fprintf(msgOut, "MQTT: 已開啟空調，定時器 #%d (%s)。\n", action.ID, action.Description)
+	} else {
+		fmt.Fprintln(msgOut, "MQTT: 已開啟空調。")
+	}
+	return err
+}
+
+// turnOff 方法用於關閉空調並取消自動關閉的定時器
+func (b *mqttBridge) turnOff(ctx context.Context) error {
+	s := b.session
+	if _, err := s.client.Switch(ctx, s.profile.DeviceNo, client.CommandAirClose); err != nil {
+		return err
+	}
+	s.timers.CancelAutoOff()
+	fmt.Fprintln(msgOut, "MQTT: 已關閉空調。")
+	return nil
+}
+
+// apply 方法用於調整溫度、模式或風速
+func (b *mqttBridge) apply(ctx context.Context, setting acSetting) error {
+	if _, err := setting.apply(ctx, b.session.client, b.session.profile.DeviceNo); err != nil {
+		return err
+	}
+	fmt.Fprintf(msgOut, "MQTT: 已設定%s。\n", setting)
+	return nil
+}
+
+// haName 函數用於將 client 的設定名稱轉換為 Home Assistant 的名稱
+func haName(names map[string]string, name string) string {
+	for ha, n := range names {
+		if n == name {
+			return ha
+		}
+	}
+	return name
+}
+
+// formatMQTTTemp 函數用於格式化溫度
+func formatMQTTTemp(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
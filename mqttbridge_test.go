@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"actool/client"
+	"actool/mockserver"
+	"actool/mqtt"
+)
+
+// TestMQTTBridge 測試 MQTT 橋接在進程內代理上發布狀態與自動發現配置，並執行命令主題上的操作
+func TestMQTTBridge(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := mqtt.NewBroker()
+	go broker.Serve(l)
+	defer broker.Close()
+	defer l.Close()
+
+	server := mockserver.New("D1")
+	s := newMockSession(t, server)
+	s.client.ExperimentalSettings = true
+	cfg := &mqttConfig{
+		Broker:          "tcp://" + l.Addr().String(),
+		Prefix:          "actool",
+		DiscoveryPrefix: "homeassistant",
+		Interval:        time.Minute,
+	}
+	stop := startMQTTBridge(context.Background(), cfg, s)
+	stopped := false
+	defer func() {
+		if !stopped {
+			stop()
+		}
+	}()
+
+	retained := func(topic string) string {
+		m, _ := broker.Retained(topic)
+		return string(m.Payload)
+	}
+	waitFor(t, "橋接上線", func() bool { return retained("actool/D1/availability") == "online" })
+
+	var state mqttState
+	if err := json.Unmarshal([]byte(retained("actool/D1/state")), &state); err != nil {
+		t.Fatalf("狀態不是有效的 JSON: %v", err)
+	}
+	want := server.Device()
+	if state.Mode != "off" || state.FanStatus != 0 || state.TempSetting != want.DeviceFan.TempSetting || state.Balance != want.Balance {
+		t.Errorf("狀態為 %+v", state)
+	}
+
+	var climate map[string]any
+	if err := json.Unmarshal([]byte(retained("homeassistant/climate/actool_D1/config")), &climate); err != nil {
+		t.Fatalf("climate 自動發現配置無效: %v", err)
+	}
+	for key, want := range map[string]any{
+		"unique_id":                 "actool_D1",
+		"availability_topic":        "actool/D1/availability",
+		"mode_state_topic":          "actool/D1/state",
+		"mode_command_topic":        "actool/D1/mode/set",
+		"power_command_topic":       "actool/D1/power/set",
+		"temperature_command_topic": "actool/D1/temperature/set",
+		"fan_mode_command_topic":    "actool/D1/fan_mode/set",
+	} {
+		if climate[key] != want {
+			t.Errorf("climate 配置中 %s = %v，應為 %v", key, climate[key], want)
+		}
+	}
+	var sensor map[string]any
+	if err := json.Unmarshal([]byte(retained("homeassistant/sensor/actool_D1_balance/config")), &sensor); err != nil {
+		t.Fatalf("sensor 自動發現配置無效: %v", err)
+	}
+	if sensor["state_topic"] != "actool/D1/state" || sensor["value_template"] != "{{ value_json.balance }}" {
+		t.Errorf("sensor 配置為 %v", sensor)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pub, err := mqtt.Dial(ctx, cfg.Broker, mqtt.Options{ClientID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+
+	pub.Publish("actool/D1/power/set", []byte("ON"), false)
+	waitFor(t, "power/set ON 開啟空調", func() bool {
+		return hasCommand(server, client.CommandAirOpen) && server.Device().DeviceFan.FanStatus == 1
+	})
+	waitFor(t, "狀態更新為開啟", func() bool {
+		json.Unmarshal([]byte(retained("actool/D1/state")), &state)
+		return state.FanStatus == 1 && state.Mode != "off"
+	})
+
+	pub.Publish("actool/D1/temperature/set", []byte("26.0"), false)
+	waitFor(t, "temperature/set 26.0 設定溫度", func() bool {
+		return hasCommand(server, client.CommandAirTemp) && server.Device().DeviceFan.TempSetting == 26
+	})
+
+	stop()
+	stopped = true
+	waitFor(t, "停止後發布 offline", func() bool { return retained("actool/D1/availability") == "offline" })
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// 提醒的級別
+const (
+	alertWarning   = "warning"   // 餘額低於提醒閾值
+	alertFloor     = "floor"     // 餘額不高於下限，已拒絕開啟或強制關閉空調
+	alertRecovered = "recovered" // 充值後餘額回升到所有閾值以上
+	alertTest      = "test"      // actool balance --test 發送的測試提醒
+)
+
+// balanceAlert 結構體為一條電費餘額提醒，亦作為 webhook 的請求體
+type balanceAlert struct {
+	Level     string    `json:"level"`
+	DeviceNo  string    `json:"deviceNo"`
+	Room      string    `json:"room,omitempty"` // 例如 "1號樓 301"
+	Balance   float64   `json:"balance"`
+	Threshold float64   `json:"threshold,omitempty"` // 觸發提醒的閾值或下限
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time"`
+}
+
+// notifier 接口為發送提醒的渠道
+type notifier interface {
+	Name() string
+	Notify(ctx context.Context, a balanceAlert) error
+}
+
+// webhookNotifier 結構體用於以 POST JSON 的方式將提醒發送到 NOTIFY_WEBHOOK
+type webhookNotifier struct {
+	url string
+}
+
+// Name 方法用於返回渠道名稱
+func (n *webhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Notify 方法用於發送提醒，響應狀態碼不為 2xx 時返回錯誤
+func (n *webhookNotifier) Notify(ctx context.Context, a balanceAlert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("創建 webhook 請求失敗: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("發送 webhook 失敗: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook 返回 HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// commandNotifier 結構體用於執行 NOTIFY_COMMAND 發送提醒
+// 提醒內容寫入命令的標準輸入，各欄位亦以 ACTOOL_ALERT_* 環境變數提供
+type commandNotifier struct {
+	command string
+}
+
+// Name 方法用於返回渠道名稱
+func (n *commandNotifier) Name() string {
+	return "command"
+}
+
+// Notify 方法用於執行命令，超過 30 秒未結束時終止
+func (n *commandNotifier) Notify(ctx context.Context, a balanceAlert) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	cmd := shellCommand(ctx, n.command)
+	cmd.Stdin = strings.NewReader(a.Message + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"ACTOOL_ALERT_LEVEL="+a.Level,
+		"ACTOOL_ALERT_DEVICENO="+a.DeviceNo,
+		"ACTOOL_ALERT_BALANCE="+formatMoney(a.Balance),
+		"ACTOOL_ALERT_THRESHOLD="+formatMoney(a.Threshold),
+		"ACTOOL_ALERT_MESSAGE="+a.Message,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("NOTIFY_COMMAND 執行失敗: %w", err)
+	}
+	return nil
+}
+
+// smtpNotifier 結構體用於通過 SMTP 服務 (通常為本機的 MTA) 發送提醒郵件，不進行身份驗證
+type smtpNotifier struct {
+	addr string
+	from string
+	to   []string
+}
+
+// Name 方法用於返回渠道名稱
+func (n *smtpNotifier) Name() string {
+	return "smtp"
+}
+
+// Notify 方法用於發送郵件，超過 30 秒未完成或 ctx 被取消時中止
+func (n *smtpNotifier) Notify(ctx context.Context, a balanceAlert) error {
+	subject := "[ACtool] " + firstLine(a.Message)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", a.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(a.Message, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	from, _ := mail.ParseAddress(n.from)
+	to := make([]string, 0, len(n.to))
+	for _, addr := range n.to {
+		parsed, _ := mail.ParseAddress(addr)
+		to = append(to, parsed.Address)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := sendMail(ctx, n.addr, from.Address, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("發送郵件失敗: %w", err)
+	}
+	return nil
+}
+
+// sendMail 函數用於以 ctx 控制連接及整個 SMTP 會話的發送郵件，流程與 smtp.SendMail 相同
+// 服務支持 STARTTLS 時加密連接
+func sendMail(ctx context.Context, addr, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() }) // ctx 被取消時中斷阻塞中的讀寫
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// newNotifiers 函數用於按 NOTIFY_* 配置創建提醒渠道，配置的格式已由 validateConfigValue 檢查
+func newNotifiers(setting func(string) string) []notifier {
+	var notifiers []notifier
+	if url := setting("NOTIFY_WEBHOOK"); url != "" {
+		notifiers = append(notifiers, &webhookNotifier{url: url})
+	}
+	if command := setting("NOTIFY_COMMAND"); command != "" {
+		notifiers = append(notifiers, &commandNotifier{command: command})
+	}
+	if to := setting("NOTIFY_EMAIL_TO"); to != "" {
+		hostname, _ := os.Hostname()
+		notifiers = append(notifiers, &smtpNotifier{
+			addr: cmp.Or(setting("NOTIFY_SMTP"), "localhost:25"),
+			from: cmp.Or(setting("NOTIFY_EMAIL_FROM"), "actool@"+cmp.Or(hostname, "localhost")),
+			to:   splitList(to),
+		})
+	}
+	return notifiers
+}
+
+// sendAlert 函數用於輸出提醒並通過所有渠道發送，個別渠道失敗時只輸出錯誤
+func sendAlert(ctx context.Context, notifiers []notifier, a balanceAlert) {
+	fmt.Fprintf(msgOut, "[%s] %s\n", a.Time.Format("2006-01-02 15:04:05"), a.Message)
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, a); err != nil {
+			fmt.Fprintf(msgOut, "錯誤: 以 %s 發送提醒失敗: %v\n", n.Name(), err)
+		}
+	}
+}
+
+// splitList 函數用於分割以逗號分隔的列表，並去除各項的空白及空項
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// firstLine 函數用於返回字串的第一行
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// formatMoney 函數用於格式化金額
+func formatMoney(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveSMTP 函數用於在 l 上運行一個只處理一次會話的極簡 SMTP 服務，收到的郵件內容寫入 received
+func serveSMTP(l net.Listener, received chan<- string) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 test ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+		case "EHLO", "HELO", "MAIL", "RCPT":
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, _ := tp.ReadDotBytes()
+			received <- string(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unsupported")
+		}
+	}
+}
+
+// TestSMTPNotifier 測試以 SMTP 發送提醒郵件
+func TestSMTPNotifier(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan string, 1)
+	go serveSMTP(l, received)
+
+	n := &smtpNotifier{addr: l.Addr().String(), from: "actool@localhost", to: []string{"User <user@example.com>"}}
+	a := balanceAlert{Level: alertWarning, Message: "電費餘額不足\n請及時充值", Time: time.Now()}
+	if err := n.Notify(context.Background(), a); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	msg := <-received
+	if !strings.Contains(msg, "To: User <user@example.com>") || !strings.Contains(msg, "請及時充值") {
+		t.Errorf("郵件內容為 %q", msg)
+	}
+}
+
+// TestSMTPNotifierCancel 測試 SMTP 服務無響應時 Notify 隨 ctx 結束而返回，不會一直阻塞
+func TestSMTPNotifierCancel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bufio.NewReader(conn).ReadString(0) // 不發送問候語
+	}()
+
+	n := &smtpNotifier{addr: l.Addr().String(), from: "actool@localhost", to: []string{"user@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := n.Notify(ctx, balanceAlert{Message: "test", Time: time.Now()}); err == nil {
+		t.Fatal("SMTP 服務無響應時應返回錯誤")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Notify 在 %s 後才返回", d)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// outputFormat 為命令行模式下結果的輸出格式
+type outputFormat string
+
+const (
+	outputText  outputFormat = "text"  // 默認的人類可讀格式
+	outputJSON  outputFormat = "json"  // 縮進的 JSON
+	outputYAML  outputFormat = "yaml"  // YAML，字段順序與 JSON 相同
+	outputTable outputFormat = "table" // 展開嵌套字段後的兩欄表格
+)
+
+// output 為當前的輸出格式，由 --output 指定
+var output = outputText
+
+// resultOut 為結構化結果的輸出目標
+var resultOut io.Writer = os.Stdout
+
+// msgOut 為命令行模式下進度與提示訊息的輸出目標
+// 非 text 格式時改為標準錯誤，使標準輸出只包含結果
+var msgOut io.Writer = os.Stdout
+
+// parseOutputFormat 函數用於解析 --output 的值，空字串視為 text
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(s)); f {
+	case "":
+		return outputText, nil
+	case outputText, outputJSON, outputYAML, outputTable:
+		return f, nil
+	}
+	return "", fmt.Errorf("無效的輸出格式 %q，可選值為 text、json、yaml、table", s)
+}
+
+// setOutputFormat 函數用於切換輸出格式，非 text 格式時將提示訊息改到標準錯誤，以便通過管道處理結果
+func setOutputFormat(f outputFormat) {
+	output = f
+	msgOut = os.Stdout
+	if f != outputText {
+		msgOut = os.Stderr
+	}
+}
+
+// structuredOutput 函數用於判斷是否應以結構化格式輸出結果
+func structuredOutput() bool {
+	return output != outputText
+}
+
+// emit 函數用於按當前輸出格式輸出結構化結果，v 須可被 JSON 序列化
+func emit(v any) {
+	var err error
+	switch output {
+	case outputJSON:
+		enc := json.NewEncoder(resultOut)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(v)
+	case outputYAML:
+		err = writeYAML(resultOut, v)
+	case outputTable:
+		err = writeTable(resultOut, v)
+	}
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 輸出結果失敗: %v\n", err)
+	}
+}
+
+// commandExit 函數用於返回命令的退出碼，結構化輸出時同時輸出錯誤信息，格式與守護進程的錯誤響應相同
+func commandExit(err error) int {
+	if err != nil && structuredOutput() {
+		emit(daemonError{Message: err.Error(), Kind: errorKind(err)})
+	}
+	return exitCode(err)
+}
+
+// jsonField 結構體為 JSON 對象中的一個字段
+type jsonField struct {
+	Key   string
+	Value any
+}
+
+// jsonObject 為保留字段順序的 JSON 對象
+type jsonObject []jsonField
+
+// decodeOrdered 函數用於將 v 序列化為 JSON 後重新解析，對象保留字段順序
+// 返回值為 jsonObject、[]any、string、json.Number、bool 或 nil
+func decodeOrdered(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return readJSONValue(dec)
+}
+
+// readJSONValue 函數用於從 dec 讀取一個完整的 JSON 值
+func readJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, jsonField{Key: key.(string), Value: value})
+		}
+		_, err := dec.Token() // 讀取 '}'
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			value, err := readJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := dec.Token() // 讀取 ']'
+		return arr, err
+	}
+	return tok, nil
+}
+
+// writeYAML 函數用於將 v 以 YAML 文檔輸出，每個文檔以 "---" 開始
+func writeYAML(w io.Writer, v any) error {
+	value, err := decodeOrdered(v)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("---")
+	writeYAMLValue(&b, value, 0)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// writeYAMLValue 函數用於在 "key:" 或 "-" 之後寫入值，嵌套的對象與數組換行並縮進
+func writeYAMLValue(b *strings.Builder, v any, indent int) {
+	switch v := v.(type) {
+	case jsonObject:
+		if len(v) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		for _, f := range v {
+			b.WriteString(strings.Repeat("  ", indent))
+			b.WriteString(yamlScalar(f.Key) + ":")
+			writeYAMLValue(b, f.Value, indent+1)
+		}
+	case []any:
+		if len(v) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		for _, item := range v {
+			b.WriteString(strings.Repeat("  ", indent))
+			b.WriteString("-")
+			writeYAMLValue(b, item, indent+1)
+		}
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+// yamlScalar 函數用於格式化 YAML 標量，可能被誤解析的字串加上雙引號
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlNeedsQuote(v) {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// yamlNeedsQuote 函數用於判斷字串作為 YAML 純量時是否需要加引號
+func yamlNeedsQuote(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	return strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.ContainsAny(s, "\n\r\t")
+}
+
+// writeTable 函數用於將 v 展開為 "字段 值" 兩欄表格輸出，嵌套字段以 "." 與 "[n]" 表示路徑
+func writeTable(w io.Writer, v any) error {
+	value, err := decodeOrdered(v)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "字段\t值")
+	writeTableRows(tw, "", value)
+	return tw.Flush()
+}
+
+// writeTableRows 函數用於遞歸輸出 v 中的每個葉子字段
+func writeTableRows(w io.Writer, path string, v any) {
+	switch v := v.(type) {
+	case jsonObject:
+		if len(v) == 0 && path != "" {
+			fmt.Fprintf(w, "%s\t{}\n", path)
+		}
+		for _, f := range v {
+			key := f.Key
+			if path != "" {
+				key = path + "." + f.Key
+			}
+			writeTableRows(w, key, f.Value)
+		}
+	case []any:
+		if len(v) == 0 {
+			fmt.Fprintf(w, "%s\t[]\n", path)
+		}
+		for i, item := range v {
+			writeTableRows(w, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case nil:
+		fmt.Fprintf(w, "%s\t-\n", path)
+	case string:
+		fmt.Fprintf(w, "%s\t%s\n", path, v)
+	default:
+		fmt.Fprintf(w, "%s\t%s\n", path, yamlScalar(v))
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestYAMLNeedsQuote 測試可能被 YAML 誤解析為其他類型或結構的字串會加上引號
+func TestYAMLNeedsQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"", true},
+		{" abc", true},
+		{"abc ", true},
+		{"true", true},
+		{"No", true},
+		{"null", true},
+		{"~", true},
+		{"12", true},
+		{"-1.5", true},
+		{"1e3", true},
+		{"abc:", true},
+		{"a: b", true},
+		{"a #b", true},
+		{"- item", true},
+		{"*ref", true},
+		{"{x}", true},
+		{"'a'", true},
+		{"a\nb", true},
+		{"abc", false},
+		{"a:b", false},
+		{"a#b", false},
+		{"D1-2", false},
+		{"開啟", false},
+	}
+	for _, tt := range tests {
+		if got := yamlNeedsQuote(tt.s); got != tt.want {
+			t.Errorf("yamlNeedsQuote(%q) = %v，應為 %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+// TestWriteYAML 測試 YAML 輸出保留字段順序、縮進嵌套的對象與數組，並為需要的字串加上引號
+func TestWriteYAML(t *testing.T) {
+	v := struct {
+		Name    string         `json:"name"`
+		Count   int            `json:"count"`
+		On      bool           `json:"on"`
+		Note    string         `json:"note"`
+		Missing *int           `json:"missing"`
+		Tags    []string       `json:"tags"`
+		Empty   []int          `json:"empty"`
+		Extra   map[string]int `json:"extra"`
+		Items   []any          `json:"items"`
+		Nested  any            `json:"nested"`
+	}{
+		Name:  "abc:",
+		Count: 3,
+		On:    true,
+		Note:  "a: b",
+		Tags:  []string{"x", "true", "12"},
+		Empty: []int{},
+		Extra: map[string]int{},
+		Items: []any{map[string]string{"id": "1"}, []int{1, 2}},
+		Nested: map[string]any{
+			"key": "value",
+		},
+	}
+	want := `---
+name: "abc:"
+count: 3
+"on": true
+note: "a: b"
+missing: null
+tags:
+  - x
+  - "true"
+  - "12"
+empty: []
+extra: {}
+items:
+  -
+    id: "1"
+  -
+    - 1
+    - 2
+nested:
+  key: value
+`
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, v); err != nil {
+		t.Fatalf("writeYAML: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("writeYAML 輸出為:\n%s\n應為:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := writeYAML(&buf, "abc:"); err != nil || buf.String() != "--- \"abc:\"\n" {
+		t.Errorf("標量的輸出為 %q, %v", buf.String(), err)
+	}
+	if err := writeYAML(&buf, func() {}); err == nil {
+		t.Error("無法序列化的值應返回錯誤")
+	}
+}
+
+// TestWriteTable 測試表格輸出將嵌套字段展開為以 "." 與 "[n]" 表示的路徑，nil 顯示為 "-"
+func TestWriteTable(t *testing.T) {
+	v := map[string]any{
+		"device": map[string]any{"deviceNo": "D1", "balance": 12.5},
+		"actions": []any{
+			map[string]any{"id": 1, "at": nil},
+		},
+		"empty":  []int{},
+		"extra":  map[string]int{},
+		"status": "開啟",
+	}
+	var buf bytes.Buffer
+	if err := writeTable(&buf, v); err != nil {
+		t.Fatalf("writeTable: %v", err)
+	}
+	got := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	want := [][2]string{
+		{"字段", "值"},
+		{"actions[0].at", "-"},
+		{"actions[0].id", "1"},
+		{"device.balance", "12.5"},
+		{"device.deviceNo", "D1"},
+		{"empty", "[]"},
+		{"extra", "{}"},
+		{"status", "開啟"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("writeTable 輸出 %d 行，應為 %d 行:\n%s", len(got), len(want), buf.String())
+	}
+	for i, w := range want {
+		if fields := strings.Fields(got[i]); len(fields) != 2 || fields[0] != w[0] || fields[1] != w[1] {
+			t.Errorf("第 %d 行為 %q，應為 %q", i+1, got[i], w)
+		}
+	}
+}
+
+// TestSetOutputFormat 測試結構化輸出時提示訊息改到標準錯誤，結果仍輸出到標準輸出
+func TestSetOutputFormat(t *testing.T) {
+	defer setOutputFormat(outputText)
+	setOutputFormat(outputJSON)
+	if msgOut == resultOut {
+		t.Error("JSON 格式下提示訊息不應與結果輸出到同一目標")
+	}
+	setOutputFormat(outputText)
+	if msgOut != resultOut {
+		t.Error("text 格式下提示訊息應輸出到標準輸出")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"actool/client"
+)
+
+// defaultProfileName 為全局 DEVICENO 對應的設備名稱
+const defaultProfileName = "default"
+
+// deviceProfile 結構體為一個命名的設備，未設定的 Token 等沿用全局配置
+type deviceProfile struct {
+	Name        string
+	DeviceNo    string
+	Token       string
+	StudentName string
+	APIBaseURL  string
+
+	// 開啟空調時的默認值，配置段中未設定時沿用全局配置
+	AconDuration string // 不帶定時參數開啟時的持續時間
+	Temp         string
+	Mode         string
+	Wind         string
+}
+
+// profileNamePattern 為設備名稱的格式，名稱會用於 socket 檔案名
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// buildProfiles 函數用於由全局配置及各配置段生成設備列表
+// base 為全局配置，其 DEVICENO 對應名為 default 的設備並排在最前，配置段中須設定 DEVICENO
+// 配置段的 Token 由 secrets 按 TOKEN、TOKEN_FILE、TOKEN_COMMAND 或 TOKEN_SECRET 解析
+func buildProfiles(base deviceProfile, sections []envSection, secrets *secretResolver) ([]deviceProfile, error) {
+	var profiles []deviceProfile
+	seen := make(map[string]bool)
+	if base.DeviceNo != "" {
+		base.Name = defaultProfileName
+		profiles = append(profiles, base)
+		seen[defaultProfileName] = true
+	}
+	for _, section := range sections {
+		name := strings.ToLower(section.Name)
+		if !profileNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("無效的設備名稱 [%s]，只能包含字母、數字、\"-\" 與 \"_\"", section.Name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("設備 [%s] 重複定義", section.Name)
+		}
+		seen[name] = true
+		token, err := secrets.token(func(key string) string { return section.Values[key] })
+		if err != nil {
+			return nil, fmt.Errorf("設備 [%s]: %w", section.Name, err)
+		}
+		p := deviceProfile{
+			Name:        name,
+			DeviceNo:    section.Values["DEVICENO"],
+			Token:       token,
+			StudentName: section.Values["STUDENTNAME"],
+			APIBaseURL:  section.Values["API_BASE_URL"],
+
+			AconDuration: cmp.Or(section.Values["ACON_DURATION"], base.AconDuration),
+			Temp:         cmp.Or(section.Values["TEMP"], base.Temp),
+			Mode:         cmp.Or(section.Values["MODE"], base.Mode),
+			Wind:         cmp.Or(section.Values["WIND"], base.Wind),
+		}
+		if p.DeviceNo == "" {
+			return nil, fmt.Errorf("設備 [%s] 未設定 DEVICENO", section.Name)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// findProfile 函數用於按名稱或設備號查找設備，name 為空時返回第一個設備
+func findProfile(profiles []deviceProfile, name string) (deviceProfile, error) {
+	if name == "" && len(profiles) > 0 {
+		return profiles[0], nil
+	}
+	for _, p := range profiles {
+		if p.Name == strings.ToLower(name) || p.DeviceNo == name {
+			return p, nil
+		}
+	}
+	names := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		names = append(names, p.Name)
+	}
+	return deviceProfile{}, fmt.Errorf("設備 %q 不存在，已配置的設備: %s", name, strings.Join(names, ", "))
+}
+
+// profileSocketPath 函數用於返回設備對應的守護進程 socket 路徑
+// default 設備使用原路徑，其他設備在檔案名後加上 "-名稱"，使每個設備可運行各自的守護進程
+func profileSocketPath(path, name string) string {
+	if path == "" || name == defaultProfileName {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
+}
+
+// settingDefaults 方法用於返回開啟空調後需套用的默認設定
+func (p deviceProfile) settingDefaults() []acSetting {
+	var settings []acSetting
+	for _, s := range []acSetting{{Kind: "temp", Value: p.Temp}, {Kind: "mode", Value: p.Mode}, {Kind: "wind", Value: p.Wind}} {
+		if s.Value != "" {
+			settings = append(settings, s)
+		}
+	}
+	return settings
+}
+
+// hasAconDefaults 方法用於判斷設備是否配置了開啟空調時的默認值
+func (p deviceProfile) hasAconDefaults() bool {
+	return p.AconDuration != "" || p.Temp != "" || p.Mode != "" || p.Wind != ""
+}
+
+// withDefaults 方法用於為開啟請求補充設備的默認值
+// 未帶定時參數時使用 ACON_DURATION 作為持續時間，未帶設定時附上開啟後需套用的默認設定
+func (p deviceProfile) withDefaults(req aconRequest) aconRequest {
+	if !req.timed() && p.AconDuration != "" {
+		req.For = p.AconDuration
+	}
+	if len(req.Settings) == 0 {
+		req.Settings = p.settingDefaults()
+	}
+	return req
+}
+
+// deviceSession 結構體為一個已啟用的設備，持有其客戶端及定時器
+type deviceSession struct {
+	profile deviceProfile
+	client  *client.Client
+	timers  *scheduler
+}
+
+// deviceSet 結構體用於管理已配置的設備，並在首次使用時為其創建客戶端與調度器
+type deviceSet struct {
+	profiles []deviceProfile
+	base     *client.Client // 按全局配置創建的客戶端，各設備在其基礎上覆蓋 Token 等
+	store    *timerStore    // 所有設備共用的狀態檔案，nil 表示不保存
+	sessions map[string]*deviceSession
+	renewer  *tokenRenewer // Token 過期時用於重新授權，nil 表示不重新授權
+}
+
+// newDeviceSet 函數用於創建設備集合
+func newDeviceSet(profiles []deviceProfile, base *client.Client) *deviceSet {
+	ds := &deviceSet{profiles: profiles, base: base, sessions: make(map[string]*deviceSession)}
+	if path, err := defaultTimerStorePath(); err != nil {
+		fmt.Fprintf(msgOut, "警告: %v，定時器將不會被保存。\n", err)
+	} else {
+		ds.store = &timerStore{path: path}
+	}
+	return ds
+}
+
+// clientFor 方法用於返回設備使用的客戶端，設備未覆蓋任何配置時直接共用全局客戶端
+func (ds *deviceSet) clientFor(p deviceProfile) *client.Client {
+	if p.Token == "" && p.StudentName == "" && p.APIBaseURL == "" {
+		return ds.base
+	}
+	c := ds.base.Clone()
+	if ds.renewer != nil {
+		c.Reauth = ds.renewer.hook(c, tokenScope(p))
+	}
+	if p.Token != "" {
+		c.Token = p.Token
+	}
+	if p.StudentName != "" {
+		c.StudentName = p.StudentName
+	}
+	if p.APIBaseURL != "" {
+		c.BaseURL = p.APIBaseURL
+	}
+	return c
+}
+
+// open 方法用於返回設備的會話，首次使用時啟動其調度器並恢復保存的定時器
+func (ds *deviceSet) open(ctx context.Context, p deviceProfile) *deviceSession {
+	if s, ok := ds.sessions[p.Name]; ok {
+		return s
+	}
+	c := ds.clientFor(p)
+	s := &deviceSession{profile: p, client: c, timers: startScheduler(ctx, c, p.DeviceNo, ds.store)}
+	ds.sessions[p.Name] = s
+	return s
+}
+
+// opened 方法用於按配置順序返回所有已啟用的設備會話
+func (ds *deviceSet) opened() []*deviceSession {
+	var sessions []*deviceSession
+	for _, p := range ds.profiles {
+		if s, ok := ds.sessions[p.Name]; ok {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+// printProfiles 函數用於輸出已配置的設備列表，並標記當前設備
+func printProfiles(profiles []deviceProfile, current string) {
+	fmt.Fprintln(msgOut, "已配置的設備：")
+	for _, p := range profiles {
+		mark := " "
+		if p.Name == current {
+			mark = "*"
+		}
+		fmt.Fprintf(msgOut, " %s %-12s %s\n", mark, p.Name, p.DeviceNo)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"actool/client"
+)
+
+// scheduleManager 接口用於管理定期任務，由本地調度器 (localSchedules) 或守護進程 (daemonSchedules) 實現
+// RemoveRule 在任務不存在時返回 errRuleNotFound
+type scheduleManager interface {
+	AddRule(command, spec string) (scheduleRule, error)
+	RemoveRule(id int) error
+	Rules() ([]scheduleRule, error)
+}
+
+// localSchedules 結構體用於將本地調度器適配為 scheduleManager
+type localSchedules struct {
+	*scheduler
+}
+
+// Rules 方法用於返回本地調度器的所有定期任務，不會失敗
+func (ls localSchedules) Rules() ([]scheduleRule, error) {
+	return ls.scheduler.Rules(), nil
+}
+
+// parseCommandWord 函數用於將 on/off 等用戶輸入轉換為 commandKey
+func parseCommandWord(word string) (string, bool) {
+	switch strings.ToLower(word) {
+	case "on", "acon", "open", "airopen":
+		return client.CommandAirOpen, true
+	case "off", "acoff", "close", "airclose":
+		return client.CommandAirClose, true
+	}
+	return "", false
+}
+
+// commandLabel 函數用於返回 commandKey 的中文描述
+func commandLabel(command string) string {
+	switch command {
+	case client.CommandAirOpen:
+		return "開啟空調"
+	case client.CommandAirClose:
+		return "關閉空調"
+	}
+	return command
+}
+
+// printScheduleUsage 函數用於輸出定期任務命令的用法
+func printScheduleUsage(prefix string) {
+	cronExample := "0 23 * * *"
+	if strings.HasPrefix(prefix, "--") {
+		cronExample = `"0 23 * * *"` // 命令行中須加引號，以免 * 被 shell 展開為檔名
+	}
+	fmt.Fprintf(msgOut, "用法：%s add <on|off> <HH:MM> [星期]   例如 %s add on 13:00 mon-fri\n", prefix, prefix)
+	fmt.Fprintf(msgOut, "      %s add <on|off> <cron 表達式> 例如 %s add off %s\n", prefix, prefix, cronExample)
+	fmt.Fprintf(msgOut, "      %s list\n", prefix)
+	fmt.Fprintf(msgOut, "      %s remove <編號>\n", prefix)
+	fmt.Fprintln(msgOut, "星期可使用 mon-fri、fri-sun (首尾相接)、sat,sun、weekdays、weekends 等寫法。")
+}
+
+// runScheduleCommand 函數用於處理 /schedule 與 --schedule 子命令，參數無效時返回 errUsage
+func runScheduleCommand(mgr scheduleManager, prefix string, args []string) error {
+	if len(args) == 0 {
+		printScheduleUsage(prefix)
+		return errUsage
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if len(args) < 3 {
+			printScheduleUsage(prefix)
+			return errUsage
+		}
+		command, ok := parseCommandWord(args[1])
+		if !ok {
+			fmt.Fprintf(msgOut, "錯誤: 無效的操作 \"%s\"，請使用 on 或 off。\n", args[1])
+			return errUsage
+		}
+		spec, err := parseScheduleSpec(args[2:])
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return fmt.Errorf("%w: %w", errUsage, err)
+		}
+		rule, err := mgr.AddRule(command, spec)
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: 添加定期任務失敗: %v\n", err)
+			return err
+		}
+		fmt.Fprintf(msgOut, "已添加定期任務 #%d：%s (%s)，下次執行於 %s。\n",
+			rule.ID, commandLabel(rule.Command), rule.Spec, rule.Next.Local().Format("2006-01-02 15:04"))
+	case "list", "ls":
+		rules, err := mgr.Rules()
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: 獲取定期任務失敗: %v\n", err)
+			return err
+		}
+		printScheduleRules(rules)
+	case "remove", "rm", "del":
+		if len(args) < 2 {
+			printScheduleUsage(prefix)
+			return errUsage
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			fmt.Fprintf(msgOut, "錯誤: 無效的任務編號 \"%s\"。\n", args[1])
+			return errUsage
+		}
+		if err := mgr.RemoveRule(id); err != nil {
+			if errors.Is(err, errRuleNotFound) {
+				fmt.Fprintf(msgOut, "錯誤: 定期任務 #%d 不存在。\n", id)
+			} else {
+				fmt.Fprintf(msgOut, "錯誤: 刪除定期任務失敗: %v\n", err)
+			}
+			return err
+		}
+		fmt.Fprintf(msgOut, "已刪除定期任務 #%d。\n", id)
+	default:
+		printScheduleUsage(prefix)
+		return errUsage
+	}
+	return nil
+}
+
+// printScheduleRules 函數用於輸出定期任務列表
+func printScheduleRules(rules []scheduleRule) {
+	if structuredOutput() {
+		emit(rules)
+		return
+	}
+	fmt.Fprintln(msgOut, "==定期任務==")
+	if len(rules) == 0 {
+		fmt.Fprintln(msgOut, "尚未設定任何定期任務。")
+	}
+	for _, rule := range rules {
+		next := "不會再觸發"
+		if !rule.Next.IsZero() {
+			next = rule.Next.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(msgOut, "#%-3d %s  %-20s 下次執行：%s\n", rule.ID, commandLabel(rule.Command), rule.Spec, next)
+	}
+	fmt.Fprintln(msgOut, "===========")
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"actool/client"
+)
+
+// maxSchedulerWait 為調度器單次等待的上限
+// 單調時鐘在系統休眠期間可能停止，定期按牆上時間重新檢查可避免錯過到期時間
+const maxSchedulerWait = time.Minute
+
+// shutdownRetry 為定時關閉空調失敗或未確認時的重試策略
+// 定時關閉失敗會導致空調一直開啟並持續扣費，因此不論錯誤類型均不限次數地重試 (忽略 MaxAttempts)，
+// 直到成功或被用戶取消；等待時間以 MaxDelay 為上限，每次重試均重新排程，不阻塞其他定時器
+var shutdownRetry = client.RetryPolicy{InitialDelay: 10 * time.Second, MaxDelay: 5 * time.Minute, Jitter: 0.2}
+
+// shutdownWarnEvery 為定時關閉連續失敗時輸出醒目警告的間隔次數
+const shutdownWarnEvery = 10
+
+// scheduledAction 結構體為一次性的定時動作，例如定時關閉或延遲開啟空調
+type scheduledAction struct {
+	ID          int           `json:"id"`
+	Command     string        `json:"command"` // client.CommandAirOpen 或 client.CommandAirClose
+	At          time.Time     `json:"at"`
+	Duration    time.Duration `json:"duration,omitempty"` // 僅用於開啟：成功開啟後持續多久自動關閉，0 為不自動關閉
+	Description string        `json:"description,omitempty"`
+	Settings    []acSetting   `json:"settings,omitempty"` // 僅用於開啟：開啟後依次套用的設定
+	Attempts    int           `json:"attempts,omitempty"` // 僅用於關閉：已失敗的嘗試次數
+}
+
+// scheduleRule 結構體為一條按 cron 表達式重複執行的定期任務
+type scheduleRule struct {
+	ID      int       `json:"id"`
+	Command string    `json:"command"` // client.CommandAirOpen 或 client.CommandAirClose
+	Spec    string    `json:"spec"`    // 五段式 cron 表達式
+	Next    time.Time `json:"next,omitzero"`
+
+	cron *cronSpec
+}
+
+// scheduler 結構體用於管理一次性的定時動作及定期任務
+// 由後台 goroutine (Run) 負責在到期時執行，不依賴標準輸入
+type scheduler struct {
+	mu           sync.Mutex
+	actions      []scheduledAction // 按執行時間排序
+	rules        []*scheduleRule
+	nextActionID int           // 下一個定時動作的編號
+	nextID       int           // 下一條定期任務的編號
+	firing       int           // 正在執行的定時動作數量
+	wake         chan struct{} // 定時器被修改時通知 Run 重新計算等待時間
+	changed      chan struct{} // 每次狀態變化時關閉並替換，供 Wait 使用
+	onFire       func(ctx context.Context, a scheduledAction) error
+	onRule       func(ctx context.Context, rule scheduleRule) error
+	persist      func(actions []scheduledAction, rules []scheduleRule) // 若不為空，每次狀態變化時調用以保存定時器
+}
+
+// newScheduler 函數用於創建調度器
+// onFire 在定時動作到期時、onRule 在定期任務觸發時於後台 goroutine 中調用
+// 兩者返回錯誤時，關閉動作會按 shutdownRetry 重新排程
+func newScheduler(onFire func(ctx context.Context, a scheduledAction) error, onRule func(ctx context.Context, rule scheduleRule) error) *scheduler {
+	return &scheduler{
+		nextActionID: 1,
+		nextID:       1,
+		wake:         make(chan struct{}, 1),
+		changed:      make(chan struct{}),
+		onFire:       onFire,
+		onRule:       onRule,
+	}
+}
+
+// Schedule 方法用於添加一個定時動作，a.ID 為 0 時自動分配編號
+func (s *scheduler) Schedule(a scheduledAction) scheduledAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a = s.addLocked(a)
+	s.notifyLocked()
+	return a
+}
+
+// SetAutoOff 方法用於設定 (或覆蓋) 自動關閉空調的定時器
+func (s *scheduler) SetAutoOff(endTime time.Time, description string) scheduledAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(func(a scheduledAction) bool { return a.Command == client.CommandAirClose })
+	a := s.addLocked(scheduledAction{Command: client.CommandAirClose, At: endTime, Description: description})
+	s.notifyLocked()
+	return a
+}
+
+// CancelAutoOff 方法用於取消所有自動關閉空調的定時器，返回是否有定時器被取消
+func (s *scheduler) CancelAutoOff() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := s.removeLocked(func(a scheduledAction) bool { return a.Command == client.CommandAirClose })
+	s.notifyLocked()
+	return removed
+}
+
+// CancelAction 方法用於取消指定編號的定時動作，返回該動作是否存在
+func (s *scheduler) CancelAction(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := s.removeLocked(func(a scheduledAction) bool { return a.ID == id })
+	s.notifyLocked()
+	return removed
+}
+
+// CancelAll 方法用於取消所有定時動作，返回是否有定時動作被取消
+func (s *scheduler) CancelAll() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := len(s.actions) > 0
+	s.actions = nil
+	s.notifyLocked()
+	return removed
+}
+
+// Actions 方法用於返回所有待執行的定時動作，按執行時間排序
+func (s *scheduler) Actions() []scheduledAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]scheduledAction{}, s.actions...) // 無定時動作時返回空切片，JSON 輸出為 []
+}
+
+// Persistent 方法用於判斷定時器是否會被保存，未啟用持久化或由其他進程管理時返回 false
+func (s *scheduler) Persistent() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.persist != nil
+}
+
+// addLocked 方法用於插入定時動作並保持排序，調用前須持有鎖
+func (s *scheduler) addLocked(a scheduledAction) scheduledAction {
+	if a.ID == 0 {
+		a.ID = s.nextActionID
+	}
+	s.nextActionID = max(s.nextActionID, a.ID+1)
+	a.At = a.At.Round(0) // 去除單調時鐘讀數，按牆上時間比較
+	i, _ := slices.BinarySearchFunc(s.actions, a.At, func(e scheduledAction, t time.Time) int {
+		return e.At.Compare(t)
+	})
+	s.actions = slices.Insert(s.actions, i, a)
+	return a
+}
+
+// removeLocked 方法用於刪除符合條件的定時動作，調用前須持有鎖
+func (s *scheduler) removeLocked(match func(a scheduledAction) bool) bool {
+	before := len(s.actions)
+	s.actions = slices.DeleteFunc(s.actions, match)
+	return len(s.actions) != before
+}
+
+// AddRule 方法用於添加一條定期任務，spec 為五段式 cron 表達式
+func (s *scheduler) AddRule(command, spec string) (scheduleRule, error) {
+	if command != client.CommandAirOpen && command != client.CommandAirClose {
+		return scheduleRule{}, fmt.Errorf("無效的操作指令：%s", command)
+	}
+	cron, err := parseCron(spec)
+	if err != nil {
+		return scheduleRule{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule := &scheduleRule{ID: s.nextID, Command: command, Spec: spec, cron: cron, Next: cron.Next(time.Now())}
+	if rule.Next.IsZero() {
+		return scheduleRule{}, fmt.Errorf("cron 表達式 %q 永遠不會觸發", spec)
+	}
+	s.nextID++
+	s.rules = append(s.rules, rule)
+	s.notifyLocked()
+	return *rule, nil
+}
+
+// restoreRule 方法用於恢復已保存的定期任務，保留其原有編號
+func (s *scheduler) restoreRule(rule scheduleRule) error {
+	cron, err := parseCron(rule.Spec)
+	if err != nil {
+		return fmt.Errorf("定期任務 #%d 的 cron 表達式無效: %w", rule.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rule.cron = cron
+	rule.Next = cron.Next(time.Now()) // 重啟期間錯過的觸發不會補執行
+	s.rules = append(s.rules, &rule)
+	s.nextID = max(s.nextID, rule.ID+1)
+	s.notifyLocked()
+	return nil
+}
+
+// RemoveRule 方法用於刪除指定編號的定期任務，該任務不存在時返回 errRuleNotFound
+func (s *scheduler) RemoveRule(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.ID == id {
+			s.rules = append(s.rules[:i], s.rules[i+1:]...)
+			s.notifyLocked()
+			return nil
+		}
+	}
+	return fmt.Errorf("定期任務 #%d: %w", id, errRuleNotFound)
+}
+
+// Rules 方法用於返回所有定期任務
+func (s *scheduler) Rules() []scheduleRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rulesLocked()
+}
+
+// rulesLocked 方法用於複製定期任務列表，調用前須持有鎖
+func (s *scheduler) rulesLocked() []scheduleRule {
+	rules := make([]scheduleRule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// Wait 方法用於阻塞直到沒有待執行的定時動作或 ctx 被取消
+func (s *scheduler) Wait(ctx context.Context) error {
+	return s.waitUntil(ctx, func() bool { return len(s.actions) == 0 && s.firing == 0 })
+}
+
+// WaitAction 方法用於阻塞直到指定編號的定時動作已執行 (含失敗後的重試) 或被取消，或 ctx 被取消
+func (s *scheduler) WaitAction(ctx context.Context, id int) error {
+	return s.waitUntil(ctx, func() bool {
+		return s.firing == 0 && !slices.ContainsFunc(s.actions, func(a scheduledAction) bool { return a.ID == id })
+	})
+}
+
+// waitUntil 方法用於在每次狀態變化後檢查 done (持有鎖時調用)，直到其成立或 ctx 被取消
+func (s *scheduler) waitUntil(ctx context.Context, done func() bool) error {
+	for {
+		s.mu.Lock()
+		pending, changed := !done(), s.changed
+		s.mu.Unlock()
+		if !pending {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Run 方法為調度器的主循環，直到 ctx 被取消才返回
+func (s *scheduler) Run(ctx context.Context) {
+	for {
+		s.mu.Lock()
+		wait := maxSchedulerWait
+		if len(s.actions) > 0 {
+			wait = min(wait, time.Until(s.actions[0].At))
+		}
+		for _, rule := range s.rules {
+			if !rule.Next.IsZero() {
+				wait = min(wait, time.Until(rule.Next))
+			}
+		}
+		s.mu.Unlock()
+
+		if wait <= 0 {
+			s.FireDue(ctx)
+			s.fireDueRules(ctx)
+			continue
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-s.wake:
+			t.Stop()
+		case <-t.C:
+		}
+	}
+}
+
+// FireDue 方法用於依次執行所有已到期的定時動作，未到期則不做任何事
+// 開啟動作成功且帶有持續時間時，會自動排程對應的關閉動作
+// 關閉動作失敗時會按 shutdownRetry 重新排程，以免空調一直開啟；ctx 被取消時保留原動作，不計入失敗次數
+func (s *scheduler) FireDue(ctx context.Context) {
+	now := time.Now()
+	s.mu.Lock()
+	i := 0
+	for i < len(s.actions) && !now.Before(s.actions[i].At) {
+		i++
+	}
+	due := slices.Clone(s.actions[:i])
+	s.actions = slices.Delete(s.actions, 0, i)
+	s.firing += len(due)
+	s.mu.Unlock()
+
+	for _, a := range due {
+		err := s.onFire(ctx, a)
+
+		s.mu.Lock()
+		s.firing--
+		if err != nil && a.Command == client.CommandAirClose {
+			s.retryCloseLocked(ctx, a)
+		}
+		if err == nil && a.Command == client.CommandAirOpen && a.Duration > 0 {
+			s.removeLocked(func(a scheduledAction) bool { return a.Command == client.CommandAirClose })
+			s.addLocked(scheduledAction{
+				Command:     client.CommandAirClose,
+				At:          time.Now().Add(a.Duration),
+				Description: formatDuration(a.Duration),
+			})
+		}
+		s.notifyLocked()
+		s.mu.Unlock()
+	}
+}
+
+// fireDueRules 方法用於執行所有已到期的定期任務，並計算其下一次觸發時間
+func (s *scheduler) fireDueRules(ctx context.Context) {
+	now := time.Now()
+	var due []scheduleRule
+	s.mu.Lock()
+	for _, rule := range s.rules {
+		if !rule.Next.IsZero() && !now.Before(rule.Next) {
+			due = append(due, *rule)
+			rule.Next = rule.cron.Next(now)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, rule := range due {
+		if err := s.onRule(ctx, rule); err != nil && rule.Command == client.CommandAirClose {
+			s.mu.Lock()
+			s.retryCloseLocked(ctx, scheduledAction{Command: client.CommandAirClose, At: time.Now(), Description: fmt.Sprintf("定期任務 #%d", rule.ID)})
+			s.notifyLocked()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// retryCloseLocked 方法用於將失敗的關閉動作按 shutdownRetry 重新排程，不限次數，調用前須持有鎖
+func (s *scheduler) retryCloseLocked(ctx context.Context, a scheduledAction) {
+	if ctx.Err() != nil {
+		// 程序正在退出，保留原動作以便下次啟動時繼續執行
+		s.addLocked(a)
+		return
+	}
+	a.Attempts++
+	if a.Attempts%shutdownWarnEvery == 0 {
+		fmt.Fprintf(msgOut, "警告: 定時關閉 #%d 已連續失敗 %d 次，空調可能仍在運行並持續扣費，請檢查網絡或 Token，或手動關閉空調。\n", a.ID, a.Attempts)
+	}
+	delay := shutdownRetry.Backoff(a.Attempts)
+	a.At = time.Now().Add(delay)
+	a = s.addLocked(a)
+	fmt.Fprintf(msgOut, "定時關閉 #%d 將在 %s 後重試 (已失敗 %d 次)。\n", a.ID, delay.Round(time.Second), a.Attempts)
+}
+
+// notifyLocked 方法用於保存狀態、喚醒 Run 並通知等待者，調用前須持有鎖
+func (s *scheduler) notifyLocked() {
+	if s.persist != nil {
+		s.persist(slices.Clone(s.actions), s.rulesLocked())
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// formatDuration 函數用於將時長格式化為 "1小時30分鐘" 的形式
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours > 0 && minutes > 0:
+		return fmt.Sprintf("%d小時%d分鐘", hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%d小時", hours)
+	default:
+		return fmt.Sprintf("%d分鐘", minutes)
+	}
+}
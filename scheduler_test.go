@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"actool/client"
+)
+
+// TestSchedulerRetryClose 測試關閉動作失敗時按退避時間重新排程而不阻塞其他定時器，且不限重試次數
+func TestSchedulerRetryClose(t *testing.T) {
+	var fired []scheduledAction
+	s := newScheduler(func(ctx context.Context, a scheduledAction) error {
+		fired = append(fired, a)
+		if a.Command == client.CommandAirClose {
+			return errors.New("失敗")
+		}
+		return nil
+	}, nil)
+
+	now := time.Now()
+	s.Schedule(scheduledAction{Command: client.CommandAirClose, At: now.Add(-time.Second)})
+	s.Schedule(scheduledAction{Command: client.CommandAirOpen, At: now.Add(-time.Second)})
+	s.FireDue(context.Background())
+	if len(fired) != 2 {
+		t.Fatalf("執行了 %d 個定時動作，應為 2 個", len(fired))
+	}
+
+	actions := s.Actions()
+	if len(actions) != 1 || actions[0].Attempts != 1 || !actions[0].At.After(now) {
+		t.Fatalf("失敗的關閉動作應延後重試，實際為 %+v", actions)
+	}
+
+	// 多次失敗後仍繼續重試，等待時間不超過 MaxDelay 加上隨機浮動
+	s.CancelAll()
+	s.Schedule(scheduledAction{Command: client.CommandAirClose, At: now, Attempts: 99})
+	s.FireDue(context.Background())
+	maxWait := time.Duration(float64(shutdownRetry.MaxDelay) * (1 + shutdownRetry.Jitter))
+	if actions := s.Actions(); len(actions) != 1 || actions[0].Attempts != 100 || time.Until(actions[0].At) > maxWait {
+		t.Errorf("失敗 100 次後的定時動作為 %+v，應在 %s 內重試", actions, maxWait)
+	}
+
+	// ctx 被取消時保留原動作，不計入失敗次數
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.CancelAll()
+	s.Schedule(scheduledAction{Command: client.CommandAirClose, At: now})
+	s.FireDue(ctx)
+	if actions := s.Actions(); len(actions) != 1 || actions[0].Attempts != 0 {
+		t.Errorf("ctx 被取消後的定時動作為 %+v", actions)
+	}
+}
+
+// TestSchedulerRemoveRule 測試刪除定期任務，編號不存在時返回 errRuleNotFound
+func TestSchedulerRemoveRule(t *testing.T) {
+	s := newScheduler(nil, nil)
+	rule, err := s.AddRule(client.CommandAirClose, "0 23 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveRule(rule.ID + 1); !errors.Is(err, errRuleNotFound) || exitCode(err) != exitUsage {
+		t.Errorf("刪除不存在的任務返回 %v", err)
+	}
+	if err := s.RemoveRule(rule.ID); err != nil {
+		t.Errorf("RemoveRule: %v", err)
+	}
+	if rules := s.Rules(); len(rules) != 0 {
+		t.Errorf("刪除後仍有定期任務 %+v", rules)
+	}
+}
+
+// TestSchedulerRunFires 測試後台 goroutine 在到期時執行定時動作，不需要標準輸入，開啟動作帶持續時間時自動排程關閉
+func TestSchedulerRunFires(t *testing.T) {
+	fired := make(chan scheduledAction, 4)
+	s := newScheduler(func(ctx context.Context, a scheduledAction) error {
+		fired <- a
+		return nil
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	// Run 已在等待時添加的定時動作同樣會按時執行
+	open := s.Schedule(scheduledAction{Command: client.CommandAirOpen, At: time.Now().Add(20 * time.Millisecond), Duration: time.Hour})
+	select {
+	case a := <-fired:
+		if a.ID != open.ID {
+			t.Errorf("執行了定時動作 %+v，應為 #%d", a, open.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("定時動作未在到期後執行")
+	}
+
+	waitFor(t, "開啟後排程自動關閉", func() bool {
+		actions := s.Actions()
+		return len(actions) == 1 && actions[0].Command == client.CommandAirClose && actions[0].Description == "1小時"
+	})
+}
+
+// TestSchedulerCancel 測試取消定時動作後不再執行，SetAutoOff 覆蓋已有的自動關閉
+func TestSchedulerCancel(t *testing.T) {
+	var fired []scheduledAction
+	s := newScheduler(func(ctx context.Context, a scheduledAction) error {
+		fired = append(fired, a)
+		return nil
+	}, nil)
+
+	past := time.Now().Add(-time.Second)
+	s.SetAutoOff(past, "舊")
+	off := s.SetAutoOff(past, "新")
+	open := s.Schedule(scheduledAction{Command: client.CommandAirOpen, At: past})
+	if actions := s.Actions(); len(actions) != 2 {
+		t.Fatalf("SetAutoOff 未覆蓋原有的自動關閉: %+v", actions)
+	}
+	if !s.CancelAction(open.ID) || s.CancelAction(open.ID) {
+		t.Error("CancelAction 返回值不正確")
+	}
+	if !s.CancelAutoOff() || s.CancelAutoOff() {
+		t.Error("CancelAutoOff 返回值不正確")
+	}
+	s.FireDue(context.Background())
+	if len(fired) != 0 {
+		t.Errorf("已取消的定時動作被執行: %+v", fired)
+	}
+
+	s.Schedule(scheduledAction{Command: client.CommandAirClose, At: past, ID: off.ID})
+	s.Schedule(scheduledAction{Command: client.CommandAirOpen, At: time.Now().Add(time.Hour)})
+	s.FireDue(context.Background())
+	if len(fired) != 1 || fired[0].ID != off.ID {
+		t.Errorf("FireDue 執行了 %+v，應只執行到期的 #%d", fired, off.ID)
+	}
+	if !s.CancelAll() || len(s.Actions()) != 0 {
+		t.Error("CancelAll 未取消未到期的定時動作")
+	}
+	if err := s.Wait(context.Background()); err != nil {
+		t.Errorf("沒有定時動作時 Wait 返回 %v", err)
+	}
+}
+
+// TestFormatDuration 測試時長的中文格式
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		30 * time.Minute:                "30分鐘",
+		2 * time.Hour:                   "2小時",
+		90*time.Minute + 20*time.Second: "1小時30分鐘",
+		0:                               "0分鐘",
+	}
+	for d, want := range tests {
+		if got := formatDuration(d); got != want {
+			t.Errorf("formatDuration(%v) = %q，應為 %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// tokenKeys 為 Token 的來源配置項，每個設備只能設定其中一個
+// TOKEN 為明文，TOKEN_FILE 從檔案讀取，TOKEN_COMMAND 執行命令並讀取其輸出，TOKEN_SECRET 從加密的密鑰庫讀取
+var tokenKeys = []string{"TOKEN", "TOKEN_FILE", "TOKEN_COMMAND", "TOKEN_SECRET"}
+
+// tokenCommandTimeout 為 TOKEN_COMMAND 的最長執行時間
+const tokenCommandTimeout = 30 * time.Second
+
+// 密鑰庫的加密參數：以 PBKDF2-SHA256 由口令派生 AES-256-GCM 密鑰
+const (
+	vaultVersion    = 1
+	vaultKDF        = "pbkdf2-sha256"
+	vaultIterations = 600000
+	vaultSaltSize   = 16
+	vaultKeySize    = 32
+)
+
+// vaultFile 結構體為密鑰庫檔案的格式，Data 為加密後的 JSON 對象 (名稱到密鑰的映射)
+type vaultFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// secretVault 結構體為以口令加密的本地密鑰庫
+type secretVault struct {
+	path       string
+	passphrase string
+	secrets    map[string]string
+}
+
+// defaultVaultPath 函數用於返回密鑰庫的默認路徑 $XDG_CONFIG_HOME/actool/secrets.enc
+// 可用 ACTOOL_SECRETS 環境變數指定其他路徑
+func defaultVaultPath() (string, error) {
+	if path := os.Getenv("ACTOOL_SECRETS"); path != "" {
+		return path, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "secrets.enc"), nil
+}
+
+// vaultExists 函數用於判斷密鑰庫檔案是否存在
+func vaultExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// openVault 函數用於以口令解密密鑰庫，檔案不存在時返回空的密鑰庫
+func openVault(path, passphrase string) (*secretVault, error) {
+	v := &secretVault{path: path, passphrase: passphrase, secrets: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return v, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("無法讀取密鑰庫 %s: %w", path, err)
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("密鑰庫 %s 格式錯誤: %w", path, err)
+	}
+	if f.Version != vaultVersion || f.KDF != vaultKDF || f.Iterations <= 0 {
+		return nil, fmt.Errorf("不支持的密鑰庫格式 %s (版本 %d)", path, f.Version)
+	}
+	gcm, err := vaultCipher(passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("密鑰庫 %s 已損壞", path)
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("無法解密密鑰庫 %s: 口令錯誤或檔案已損壞", path)
+	}
+	if err := json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, fmt.Errorf("密鑰庫 %s 內容格式錯誤: %w", path, err)
+	}
+	return v, nil
+}
+
+// vaultCipher 函數用於由口令與鹽派生密鑰並創建 AES-GCM
+func vaultCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, vaultKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("派生密鑰失敗: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// save 方法用於以新的鹽與隨機數加密並寫入密鑰庫，檔案權限為 0600
+func (v *secretVault) save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	f := vaultFile{Version: vaultVersion, KDF: vaultKDF, Iterations: vaultIterations, Salt: make([]byte, vaultSaltSize)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := vaultCipher(v.passphrase, f.Salt, f.Iterations)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(v.path, append(data, '\n'))
+}
+
+// names 方法用於返回已保存的密鑰名稱，按字母排序
+func (v *secretVault) names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// readPassphrase 函數用於獲取密鑰庫的口令，優先使用 ACTOOL_PASSPHRASE 環境變數，否則在終端中提示輸入
+// confirm 為 true 時 (創建新的密鑰庫) 要求輸入兩次
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv("ACTOOL_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !stdinIsTerminal() {
+		return "", errors.New("標準輸入不是終端，請使用 ACTOOL_PASSPHRASE 環境變數提供密鑰庫口令")
+	}
+	passphrase, err := readHiddenLine("密鑰庫口令: ")
+	if err == io.EOF {
+		return "", errors.New("未輸入口令")
+	}
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("口令不能為空")
+	}
+	if confirm {
+		again, err := readHiddenLine("再次輸入口令: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("兩次輸入的口令不一致")
+		}
+	}
+	return passphrase, nil
+}
+
+// stdinIsTerminal 函數用於判斷標準輸入是否為終端
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readHiddenLine 函數用於提示並讀取一行輸入，標準輸入為終端時不回顯
+// 讀取期間自行捕獲 Ctrl-C 與 SIGTERM，被中斷時恢復終端回顯後返回 context.Canceled
+func readHiddenLine(prompt string) (string, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprint(msgOut, prompt)
+	if stdinIsTerminal() && setTerminalEcho(false) == nil {
+		defer func() {
+			setTerminalEcho(true)
+			fmt.Fprintln(msgOut)
+		}()
+	}
+	line, err := readLineContext(ctx, os.Stdin)
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("輸入已中斷: %w", context.Canceled)
+	}
+	return line, err
+}
+
+// readLine 函數用於逐字節讀取一行，不會多讀取之後的輸入，以便與互動模式共用標準輸入
+// 輸入結束且沒有內容時返回 io.EOF
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// readLineContext 函數用於讀取一行，ctx 被取消 (例如按下 Ctrl-C) 時立即返回 ctx.Err()
+// 被取消後讀取的 goroutine 仍阻塞在 r 上，之後輸入的一行會被丟棄
+func readLineContext(ctx context.Context, r io.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := readLine(r)
+		done <- result{line, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-done:
+		return res.line, res.err
+	}
+}
+
+// secretResolver 結構體用於按配置解析設備的 Token，密鑰庫在首次需要時才解密
+type secretResolver struct {
+	baseDir    string // 相對的 TOKEN_FILE 路徑以配置檔案所在目錄為基準
+	vaultPath  string
+	vault      *secretVault
+	passphrase string // 已輸入的口令，重新載入密鑰庫時無需再次輸入
+}
+
+// newSecretResolver 函數用於創建 Token 解析器，configPath 為使用的配置檔案路徑
+func newSecretResolver(configPath string) *secretResolver {
+	r := &secretResolver{baseDir: "."}
+	if configPath != "" {
+		r.baseDir = filepath.Dir(configPath)
+	}
+	r.vaultPath, _ = defaultVaultPath()
+	return r
+}
+
+// token 方法用於按 lookup 返回的配置項獲取 Token，未設定任何來源時返回空字串
+func (r *secretResolver) token(lookup func(string) string) (string, error) {
+	if token := lookup("TOKEN"); token != "" {
+		return token, nil
+	}
+	if path := lookup("TOKEN_FILE"); path != "" {
+		return r.tokenFromFile(path)
+	}
+	if command := lookup("TOKEN_COMMAND"); command != "" {
+		return tokenFromCommand(command)
+	}
+	if name := lookup("TOKEN_SECRET"); name != "" {
+		return r.tokenFromVault(name)
+	}
+	return "", nil
+}
+
+// tokenFilePath 方法用於展開 TOKEN_FILE 中的 "~/" 並將相對路徑轉換為以配置檔案所在目錄為基準的路徑
+func (r *secretResolver) tokenFilePath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.baseDir, path)
+	}
+	return path
+}
+
+// tokenFromFile 方法用於讀取 TOKEN_FILE 指定的檔案，檔案可被其他用戶讀取時發出警告
+func (r *secretResolver) tokenFromFile(path string) (string, error) {
+	path = r.tokenFilePath(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("TOKEN_FILE: %w", err)
+	}
+	if warning := permissionWarning(path); warning != "" {
+		fmt.Fprintf(msgOut, "警告: %s\n", warning)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("TOKEN_FILE: %s 為空", path)
+	}
+	return token, nil
+}
+
+// tokenFromCommand 函數用於執行 TOKEN_COMMAND 並以其標準輸出的第一行作為 Token，例如 pass show actool
+func tokenFromCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+	cmd := shellCommand(ctx, command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("TOKEN_COMMAND 執行失敗: %w", err)
+	}
+	token, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if token = strings.TrimSpace(token); token == "" {
+		return "", errors.New("TOKEN_COMMAND 沒有輸出")
+	}
+	return token, nil
+}
+
+// tokenFromVault 方法用於從密鑰庫讀取指定名稱的 Token，首次調用時提示輸入口令
+func (r *secretResolver) tokenFromVault(name string) (string, error) {
+	if r.vault == nil {
+		if r.vaultPath == "" || !vaultExists(r.vaultPath) {
+			return "", fmt.Errorf("TOKEN_SECRET: 密鑰庫不存在，請先執行 actool secret set %s", name)
+		}
+		if err := r.openVault(); err != nil {
+			return "", fmt.Errorf("TOKEN_SECRET: %w", err)
+		}
+	}
+	token, ok := r.vault.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("TOKEN_SECRET: 密鑰庫中沒有 %q，可執行 actool secret list 查看", name)
+	}
+	return token, nil
+}
+
+// openVault 方法用於解密密鑰庫，已輸入過口令時直接使用
+func (r *secretResolver) openVault() error {
+	passphrase := r.passphrase
+	if passphrase == "" {
+		var err error
+		if passphrase, err = readPassphrase(!vaultExists(r.vaultPath)); err != nil {
+			return err
+		}
+	}
+	v, err := openVault(r.vaultPath, passphrase)
+	if err != nil {
+		return err
+	}
+	r.vault, r.passphrase = v, passphrase
+	return nil
+}
+
+// reload 方法用於丟棄已解密的密鑰庫，下次讀取時重新載入檔案，以取得其他進程更新的密鑰
+func (r *secretResolver) reload() {
+	r.vault = nil
+}
+
+// storeSecret 方法用於將密鑰保存到密鑰庫
+func (r *secretResolver) storeSecret(name, value string) error {
+	if r.vaultPath == "" {
+		return errors.New("無法確定密鑰庫的路徑")
+	}
+	r.reload()
+	if err := r.openVault(); err != nil {
+		return err
+	}
+	r.vault.secrets[name] = value
+	if err := r.vault.save(); err != nil {
+		return fmt.Errorf("保存密鑰庫失敗: %w", err)
+	}
+	return nil
+}
+
+// permissionWarning 函數用於在檔案可被同組或其他用戶讀取時返回警告訊息
+func permissionWarning(path string) string {
+	if perm, ok := readableByOthers(path); ok {
+		return fmt.Sprintf("%s 包含 Token 且可被其他用戶讀取 (權限 %04o)，建議執行 chmod 600 %s", path, perm, path)
+	}
+	return ""
+}
+
+// runSecretCommand 函數用於處理 actool secret 子命令，管理加密的密鑰庫，返回退出碼
+func runSecretCommand(args []string) int {
+	if len(args) == 0 {
+		printSecretUsage()
+		return exitUsage
+	}
+	path, err := defaultVaultPath()
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+	command := args[0]
+	switch {
+	case command == "list" && len(args) == 1:
+	case (command == "set" || command == "remove") && len(args) == 2:
+	default:
+		printSecretUsage()
+		return exitUsage
+	}
+	if command != "set" && !vaultExists(path) {
+		fmt.Fprintf(msgOut, "密鑰庫 %s 不存在。\n", path)
+		return exitConfig
+	}
+
+	passphrase, err := readPassphrase(command == "set" && !vaultExists(path))
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		if errors.Is(err, context.Canceled) {
+			return exitInterrupted
+		}
+		return exitConfig
+	}
+	v, err := openVault(path, passphrase)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitConfig
+	}
+
+	switch command {
+	case "list":
+		if len(v.secrets) == 0 {
+			fmt.Fprintln(msgOut, "密鑰庫為空。")
+		}
+		for _, name := range v.names() {
+			fmt.Fprintln(msgOut, name)
+		}
+		return exitOK
+	case "set":
+		value, err := readHiddenLine(fmt.Sprintf("%s 的值: ", args[1]))
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return exitInterrupted
+		}
+		if err != nil || strings.TrimSpace(value) == "" {
+			fmt.Fprintln(msgOut, "錯誤: 未輸入密鑰。")
+			return exitUsage
+		}
+		v.secrets[args[1]] = strings.TrimSpace(value)
+	case "remove":
+		if _, ok := v.secrets[args[1]]; !ok {
+			fmt.Fprintf(msgOut, "錯誤: 密鑰庫中沒有 %q。\n", args[1])
+			return exitUsage
+		}
+		delete(v.secrets, args[1])
+	}
+	if err := v.save(); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: 保存密鑰庫失敗: %v\n", err)
+		return exitFailure
+	}
+	if command == "set" {
+		fmt.Fprintf(msgOut, "已保存到 %s，可在配置中使用 TOKEN_SECRET=%s。\n", path, args[1])
+	} else {
+		fmt.Fprintf(msgOut, "已從 %s 刪除 %s。\n", path, args[1])
+	}
+	return exitOK
+}
+
+// printSecretUsage 函數用於輸出 secret 子命令的用法
+func printSecretUsage() {
+	fmt.Fprintln(msgOut, "用法：actool secret set <名稱>     保存密鑰 (例如 Token) 到加密的密鑰庫，配置中以 TOKEN_SECRET=<名稱> 引用")
+	fmt.Fprintln(msgOut, "      actool secret list           列出已保存的密鑰名稱")
+	fmt.Fprintln(msgOut, "      actool secret remove <名稱>  刪除密鑰")
+	fmt.Fprintln(msgOut, "密鑰庫默認為 $XDG_CONFIG_HOME/actool/secrets.enc (可用 ACTOOL_SECRETS 指定)，")
+	fmt.Fprintln(msgOut, "口令在終端中輸入，或由 ACTOOL_PASSPHRASE 環境變數提供 (例如在守護進程中)。")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTokenFromCommand 測試以平台的 shell 執行 TOKEN_COMMAND 並讀取其輸出的第一行
+func TestTokenFromCommand(t *testing.T) {
+	if token, err := tokenFromCommand("echo abc"); err != nil || token != "abc" {
+		t.Errorf("tokenFromCommand = %q, %v，應為 abc", token, err)
+	}
+	if _, err := tokenFromCommand("exit 3"); err == nil {
+		t.Error("命令失敗時應返回錯誤")
+	}
+}
+
+// TestReadHiddenLineInterrupt 測試等待輸入口令時按下 Ctrl-C 會返回 context.Canceled，而不是一直阻塞
+func TestReadHiddenLineInterrupt(t *testing.T) {
+	self, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	defer r.Close()
+	saved := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = saved }()
+
+	sent := make(chan error, 1)
+	time.AfterFunc(100*time.Millisecond, func() { sent <- self.Signal(os.Interrupt) })
+	if _, err := readHiddenLine("口令: "); !errors.Is(err, context.Canceled) {
+		if serr := <-sent; serr != nil {
+			t.Skipf("無法向自身發送中斷信號: %v", serr)
+		}
+		t.Errorf("readHiddenLine 返回 %v，應為 context.Canceled", err)
+	}
+}
+
+// TestVaultRoundTrip 測試密鑰庫加密保存後能以同一口令解密，檔案中不含明文且權限為 0600
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actool", "secrets.enc")
+	v, err := openVault(path, "口令 passphrase")
+	if err != nil || len(v.secrets) != 0 {
+		t.Fatalf("打開不存在的密鑰庫 = %+v, %v，應為空的密鑰庫", v, err)
+	}
+	v.secrets["home"] = "token-home-1234"
+	v.secrets["dorm"] = "token-dorm-5678"
+	if err := v.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("token-home")) || bytes.Contains(data, []byte("dorm")) {
+		t.Error("密鑰庫檔案中包含明文")
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("密鑰庫權限為 %v, %v，應為 0600", info.Mode().Perm(), err)
+		}
+	}
+
+	got, err := openVault(path, "口令 passphrase")
+	if err != nil {
+		t.Fatalf("openVault: %v", err)
+	}
+	if got.secrets["home"] != "token-home-1234" || got.secrets["dorm"] != "token-dorm-5678" || len(got.secrets) != 2 {
+		t.Errorf("解密後為 %v", got.secrets)
+	}
+	if names := got.names(); strings.Join(names, ",") != "dorm,home" {
+		t.Errorf("names() = %v", names)
+	}
+
+	// 每次保存使用新的鹽與隨機數
+	if err := got.save(); err != nil {
+		t.Fatal(err)
+	}
+	again, _ := os.ReadFile(path)
+	var before, after vaultFile
+	json.Unmarshal(data, &before)
+	json.Unmarshal(again, &after)
+	if bytes.Equal(before.Salt, after.Salt) || bytes.Equal(before.Nonce, after.Nonce) || bytes.Equal(before.Data, after.Data) {
+		t.Error("重新保存時應使用新的鹽與隨機數")
+	}
+}
+
+// TestVaultOpenErrors 測試口令錯誤、檔案損壞或被截斷時返回錯誤而不是部分內容
+func TestVaultOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.enc")
+	v, _ := openVault(path, "right")
+	v.secrets["home"] = "token"
+	if err := v.save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	// modified 函數用於返回修改 f 的副本後序列化的內容
+	modified := func(change func(*vaultFile)) []byte {
+		c := f
+		c.Data = bytes.Clone(f.Data)
+		change(&c)
+		out, _ := json.Marshal(c)
+		return out
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+		wantErr    string
+	}{
+		{"口令錯誤", data, "wrong", "口令錯誤或檔案已損壞"},
+		{"空口令", data, "", "口令錯誤或檔案已損壞"},
+		{"密文被修改", modified(func(c *vaultFile) { c.Data[0] ^= 1 }), "right", "口令錯誤或檔案已損壞"},
+		{"密文被截斷", modified(func(c *vaultFile) { c.Data = c.Data[:len(c.Data)-1] }), "right", "口令錯誤或檔案已損壞"},
+		{"鹽被修改", modified(func(c *vaultFile) { c.Salt = []byte("other salt value") }), "right", "口令錯誤或檔案已損壞"},
+		{"隨機數長度錯誤", modified(func(c *vaultFile) { c.Nonce = c.Nonce[:4] }), "right", "已損壞"},
+		{"不支持的版本", modified(func(c *vaultFile) { c.Version = 2 }), "right", "不支持的密鑰庫格式"},
+		{"不支持的 KDF", modified(func(c *vaultFile) { c.KDF = "scrypt" }), "right", "不支持的密鑰庫格式"},
+		{"迭代次數無效", modified(func(c *vaultFile) { c.Iterations = 0 }), "right", "不支持的密鑰庫格式"},
+		{"檔案被截斷", data[:len(data)/2], "right", "格式錯誤"},
+		{"空檔案", nil, "right", "格式錯誤"},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		v, err := openVault(path, tt.passphrase)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: openVault = %+v, %v，錯誤應包含 %q", tt.name, v, err, tt.wantErr)
+		}
+	}
+}
+
+// TestSecretResolverVault 測試以 ACTOOL_PASSPHRASE 保存密鑰後，TOKEN_SECRET 從密鑰庫讀取 Token
+func TestSecretResolverVault(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ACTOOL_SECRETS", filepath.Join(dir, "secrets.enc"))
+	t.Setenv("ACTOOL_PASSPHRASE", "passphrase")
+
+	r := newSecretResolver(filepath.Join(dir, "actool.env"))
+	lookup := func(key string) string {
+		return map[string]string{"TOKEN_SECRET": "home"}[key]
+	}
+	if _, err := r.token(lookup); err == nil || !strings.Contains(err.Error(), "密鑰庫不存在") {
+		t.Errorf("密鑰庫不存在時返回 %v", err)
+	}
+	if err := r.storeSecret("home", "token-1"); err != nil {
+		t.Fatalf("storeSecret: %v", err)
+	}
+
+	r = newSecretResolver(filepath.Join(dir, "actool.env"))
+	if token, err := r.token(lookup); err != nil || token != "token-1" {
+		t.Errorf("token = %q, %v，應為 token-1", token, err)
+	}
+	missing := func(key string) string {
+		return map[string]string{"TOKEN_SECRET": "dorm"}[key]
+	}
+	if _, err := r.token(missing); err == nil || !strings.Contains(err.Error(), `密鑰庫中沒有 "dorm"`) {
+		t.Errorf("密鑰不存在時返回 %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultServeListen 為 actool serve 默認監聽的地址，僅本機可訪問
+const defaultServeListen = "127.0.0.1:8787"
+
+// minServeTokenLength 為 SERVE_TOKEN 的最短長度
+const minServeTokenLength = 16
+
+// openAPISpec 為 actool serve 控制接口的 OpenAPI 描述，由 GET /openapi.json 提供
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// webFS 為網頁控制台的靜態檔案，由 GET / 提供，頁面在瀏覽器中以 Bearer Token 調用控制接口
+//
+//go:embed web
+var webFS embed.FS
+
+// serveHandler 函數用於返回 actool serve 的路由：網頁控制台與 GET /openapi.json 無需驗證
+// GET /metrics 及其餘交給 api 處理的請求須通過 Bearer Token 驗證
+func serveHandler(api *daemonAPI, metrics *metricsCollector, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFileFS(w, r, webFS, "web/index.html")
+	})
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	mux.Handle("GET /metrics", requireBearer(token, metrics))
+	mux.Handle("/", requireBearer(token, api.Handler()))
+	return mux
+}
+
+// requireBearer 函數用於要求請求帶有 "Authorization: Bearer <token>"，否則返回 401
+func requireBearer(token string, next http.Handler) http.Handler {
+	expected := []byte(token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, got, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="actool"`)
+			writeDaemonJSON(w, http.StatusUnauthorized, daemonError{Message: "缺少或錯誤的 Bearer Token", Kind: kindAuth})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// generateServeToken 函數用於生成隨機的 Bearer Token，未設定 SERVE_TOKEN 時使用
+func generateServeToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成 Token 失敗: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// runServe 函數用於運行 actool serve：在 listen 上提供 HTTP 控制接口，同時監聽控制 socket 以便命令行轉交命令
+// 兩者共用同一組定時器，直到收到 SIGINT/SIGTERM
+func runServe(ctx context.Context, api *daemonAPI, metrics *metricsCollector, listen, token, socketPath string, onExit exitPolicy) error {
+	tcpListener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("無法監聽 %s: %w", listen, err)
+	}
+	unixListener, err := listenUnixSocket(socketPath)
+	if err != nil {
+		tcpListener.Close()
+		return err
+	}
+	defer os.Remove(socketPath)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	servers := []*http.Server{
+		{Handler: serveHandler(api, metrics, token), ReadHeaderTimeout: 10 * time.Second},
+		{Handler: api.Handler()},
+	}
+	listeners := []net.Listener{tcpListener, unixListener}
+	errs := make(chan error, len(servers))
+	for i, server := range servers {
+		go func() {
+			errs <- server.Serve(listeners[i])
+		}()
+	}
+
+	fmt.Fprintf(msgOut, "控制接口已啟動，設備號 %s，地址：http://%s/ (OpenAPI 描述見 /openapi.json，Prometheus 指標見 /metrics)\n", api.profile.DeviceNo, tcpListener.Addr())
+	fmt.Fprintln(msgOut, "在瀏覽器中打開上述地址即可使用網頁控制台，首次使用時須輸入 Token。")
+	fmt.Fprintf(msgOut, "控制 socket：%s\n", socketPath)
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-errs:
+		stop()
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	for _, server := range servers {
+		server.Shutdown(shutdownCtx)
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		return fmt.Errorf("控制接口異常退出: %w", serveErr)
+	}
+	applyExitPolicy(api.client, api.profile.DeviceNo, api.timers, onExit)
+	fmt.Fprintln(msgOut, "控制接口已退出。")
+	return nil
+}
+
+// runServeCommand 函數用於解析 actool serve 的參數並運行控制接口
+// listen 與 token 為 SERVE_LISTEN 與 SERVE_TOKEN 的配置值，Token 不足 minServeTokenLength 個字符時拒絕啟動，未設定時每次啟動隨機生成；mqttCfg 不為 nil 時同時運行 MQTT 橋接，balanceCfg 設定了閾值或下限時同時監控電費餘額
+func runServeCommand(ctx context.Context, devices *deviceSet, profile deviceProfile, socketPath string, onExit exitPolicy, mqttCfg *mqttConfig, balanceCfg *balanceWatchConfig, listen, token string, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.StringVar(&listen, "listen", cmp.Or(listen, defaultServeListen), "HTTP 控制接口監聽的地址")
+	fs.StringVar(&socketPath, "socket", socketPath, "控制 socket 的路徑")
+	onExitValue := fs.String("on-exit", string(onExit), "退出時的處理策略：keep、off 或 off-if-timer")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	onExit, err := parseExitPolicy(*onExitValue)
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitUsage
+	}
+	if err := validateConfigValue("SERVE_LISTEN", listen); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: --listen 無效: %v\n", err)
+		return exitUsage
+	}
+	if socketPath == "" {
+		fmt.Fprintln(msgOut, "錯誤: 無法確定控制 socket 的路徑，請使用 --socket 或 ACTOOL_SOCKET 指定。")
+		return exitConfig
+	}
+	if token == "" {
+		if token, err = generateServeToken(); err != nil {
+			fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+			return exitFailure
+		}
+		fmt.Fprintln(msgOut, "未設定 SERVE_TOKEN，已生成本次運行使用的 Token：")
+		fmt.Fprintf(msgOut, "  Authorization: Bearer %s\n", token)
+	} else if err := validateConfigValue("SERVE_TOKEN", token); err != nil {
+		fmt.Fprintf(msgOut, "錯誤: SERVE_TOKEN 無效: %v，刪除該設定則每次啟動時隨機生成。\n", err)
+		return exitConfig
+	}
+
+	// 在創建設備會話前設定統計回調，使調度器等使用同一客戶端發出的請求也被統計
+	metrics := newMetricsCollector()
+	devices.base.Observe = metrics.observe
+	s := devices.open(ctx, profile)
+	metrics.session = s
+	api := &daemonAPI{client: s.client, profile: s.profile, timers: s.timers}
+	stopBridge := startMQTTBridge(ctx, mqttCfg, s)
+	stopWatcher := startBalanceWatcher(ctx, balanceCfg, s)
+	err = runServe(ctx, api, metrics, listen, token, socketPath, onExit)
+	stopWatcher()
+	stopBridge()
+	if err != nil {
+		fmt.Fprintf(msgOut, "錯誤: %v\n", err)
+		return exitCode(err)
+	}
+	return exitOK
+}
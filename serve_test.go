@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"actool/mockserver"
+)
+
+// testServeToken 為測試中使用的 SERVE_TOKEN
+const testServeToken = "0123456789abcdef-token"
+
+// newServeHandler 函數用於創建連接到模擬 hatch-api 的 actool serve 路由
+func newServeHandler(t *testing.T) http.Handler {
+	t.Helper()
+	s := newMockSession(t, mockserver.New("D1"))
+	metrics := newMetricsCollector()
+	metrics.session = s
+	api := &daemonAPI{client: s.client, profile: s.profile, timers: s.timers}
+	return serveHandler(api, metrics, testServeToken)
+}
+
+// serveRequest 函數用於以 authorization 請求頭向 handler 發送請求
+func serveRequest(handler http.Handler, method, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestRequireBearer 測試控制接口及指標要求正確的 Bearer Token，拒絕時返回 401 及 WWW-Authenticate
+func TestRequireBearer(t *testing.T) {
+	handler := newServeHandler(t)
+	tests := []struct {
+		authorization string
+		ok            bool
+	}{
+		{"", false},
+		{"Bearer", false},
+		{"Bearer ", false},
+		{"Bearer wrong-token-0123456789", false},
+		{"Bearer " + testServeToken + "x", false},
+		{"Bearer " + testServeToken[:len(testServeToken)-1], false},
+		{"Basic " + testServeToken, false},
+		{testServeToken, false},
+		{"Bearer " + testServeToken, true},
+		{"bearer " + testServeToken, true},
+		{"Bearer  " + testServeToken + " ", true},
+	}
+	for _, path := range []string{"/status", "/metrics"} {
+		for _, tt := range tests {
+			rec := serveRequest(handler, http.MethodGet, path, tt.authorization)
+			if tt.ok {
+				if rec.Code != http.StatusOK {
+					t.Errorf("GET %s (%q) 返回 %d，應通過驗證: %s", path, tt.authorization, rec.Code, rec.Body)
+				}
+				continue
+			}
+			var resp daemonError
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if rec.Code != http.StatusUnauthorized || resp.Kind != kindAuth {
+				t.Errorf("GET %s (%q) 返回 %d %+v，應為 401 auth", path, tt.authorization, rec.Code, resp)
+			}
+			if rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("GET %s (%q) 的 401 響應缺少 WWW-Authenticate", path, tt.authorization)
+			}
+		}
+	}
+}
+
+// TestServePublicRoutes 測試網頁控制台與 OpenAPI 描述無需 Token
+func TestServePublicRoutes(t *testing.T) {
+	handler := newServeHandler(t)
+
+	rec := serveRequest(handler, http.MethodGet, "/", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("GET / 返回 %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/openapi.json", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /openapi.json 返回 %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("OpenAPI 描述不是有效的 JSON: %v", err)
+	}
+
+	if rec := serveRequest(handler, http.MethodGet, "/index.html", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /index.html 返回 %d，控制台以外的路徑應要求 Token", rec.Code)
+	}
+}
+
+// TestServeOpenAPIRoutes 測試 OpenAPI 描述中的每個路徑及方法均有對應的路由，且要求 Bearer Token
+func TestServeOpenAPIRoutes(t *testing.T) {
+	var spec struct {
+		Paths    map[string]map[string]json.RawMessage `json:"paths"`
+		Security []map[string][]string                 `json:"security"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("OpenAPI 描述格式錯誤: %v", err)
+	}
+	if len(spec.Security) == 0 || spec.Security[0]["bearerAuth"] == nil {
+		t.Errorf("OpenAPI 描述未聲明 Bearer 驗證: %v", spec.Security)
+	}
+	if len(spec.Paths) == 0 {
+		t.Fatal("OpenAPI 描述沒有路徑")
+	}
+
+	handler := newServeHandler(t)
+	for path, methods := range spec.Paths {
+		target := strings.ReplaceAll(path, "{id}", "999")
+		for method := range methods {
+			method = strings.ToUpper(method)
+			if rec := serveRequest(handler, method, target, ""); rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s 未帶 Token 時返回 %d，應為 401", method, path, rec.Code)
+			}
+			// 路由不存在時 ServeMux 返回純文本的 404 或 405，控制接口的響應均為 JSON 或指標
+			rec := serveRequest(handler, method, target, "Bearer "+testServeToken)
+			contentType := rec.Header().Get("Content-Type")
+			if rec.Code == http.StatusMethodNotAllowed || (rec.Code == http.StatusNotFound && !strings.Contains(contentType, "json")) {
+				t.Errorf("%s %s 沒有對應的路由: %d %s", method, path, rec.Code, rec.Body)
+			}
+		}
+	}
+}
+
+// TestServeTokenTooShort 測試 SERVE_TOKEN 不足 16 個字符時拒絕啟動
+func TestServeTokenTooShort(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "actool.sock")
+	code := runServeCommand(context.Background(), nil, deviceProfile{}, socket, exitKeep, nil, nil, "127.0.0.1:0", "short-token", nil)
+	if code != exitConfig {
+		t.Errorf("SERVE_TOKEN 過短時退出碼為 %d，應為 %d", code, exitConfig)
+	}
+}
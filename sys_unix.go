@@ -0,0 +1,53 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand 函數用於創建以 sh -c 執行 command 的命令，用於 TOKEN_COMMAND 與 NOTIFY_COMMAND
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// setTerminalEcho 函數用於通過 stty 開啟或關閉終端回顯
+func setTerminalEcho(on bool) error {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// readableByOthers 函數用於判斷檔案是否可被同組或其他用戶讀取，並返回其權限
+func readableByOthers(path string) (os.FileMode, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return info.Mode().Perm(), info.Mode().Perm()&0o044 != 0
+}
+
+// lockFileExclusive 函數用於以 flock 取得檔案的排他鎖，wait 為 false 且鎖已被持有時返回 errLocked
+func lockFileExclusive(f *os.File, wait bool) error {
+	how := syscall.LOCK_EX
+	if !wait {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		switch err {
+		case syscall.EINTR:
+			continue
+		case syscall.EWOULDBLOCK:
+			return errLocked
+		}
+		return err
+	}
+}
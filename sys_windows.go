@@ -0,0 +1,76 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// shellCommand 函數用於創建以 cmd /c 執行 command 的命令，用於 TOKEN_COMMAND 與 NOTIFY_COMMAND
+// cmd.exe 不按 CommandLineToArgvW 的規則解析參數，因此自行構造完整的命令行
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	comspec := cmp.Or(os.Getenv("ComSpec"), "cmd.exe")
+	cmd := exec.CommandContext(ctx, comspec)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: syscall.EscapeArg(comspec) + ` /d /s /c "` + command + `"`}
+	return cmd
+}
+
+// enableEchoInput 為控制台輸入模式中的 ENABLE_ECHO_INPUT 標誌
+const enableEchoInput = 0x0004
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+	procLockFileEx     = kernel32.NewProc("LockFileEx")
+)
+
+// setTerminalEcho 函數用於通過 SetConsoleMode 開啟或關閉控制台回顯
+func setTerminalEcho(on bool) error {
+	h := syscall.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return err
+	}
+	if on {
+		mode |= enableEchoInput
+	} else {
+		mode &^= enableEchoInput
+	}
+	if r, _, err := procSetConsoleMode.Call(uintptr(h), uintptr(mode)); r == 0 {
+		return err
+	}
+	return nil
+}
+
+// readableByOthers 函數在 Windows 上總是返回 false
+// 檔案的訪問權限由 ACL 控制，權限位無法反映是否可被其他用戶讀取
+func readableByOthers(path string) (os.FileMode, bool) {
+	return 0, false
+}
+
+// LockFileEx 的標誌及鎖已被持有時的錯誤代碼
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// lockFileExclusive 函數用於以 LockFileEx 取得檔案的排他鎖，wait 為 false 且鎖已被持有時返回 errLocked
+func lockFileExclusive(f *os.File, wait bool) error {
+	flags := uintptr(lockfileExclusiveLock)
+	if !wait {
+		flags |= lockfileFailImmediately
+	}
+	var overlapped syscall.Overlapped
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		if err == errorLockViolation {
+			return errLocked
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"actool/client"
+)
+
+// persistedTimer 結構體為狀態檔案中保存的一個定時動作
+// 舊版本的檔案沒有 command 字段，此時視為自動關閉
+type persistedTimer struct {
+	ID          int         `json:"id,omitempty"`
+	DeviceNo    string      `json:"deviceNo"`
+	Command     string      `json:"command,omitempty"`
+	EndTime     time.Time   `json:"endTime"`
+	Duration    string      `json:"duration,omitempty"` // 例如 "2h0m0s"
+	Description string      `json:"description"`
+	Settings    []acSetting `json:"settings,omitempty"`
+}
+
+// action 方法用於將保存的記錄轉換為定時動作
+func (t persistedTimer) action() (scheduledAction, error) {
+	a := scheduledAction{ID: t.ID, Command: t.Command, At: t.EndTime, Description: t.Description, Settings: t.Settings}
+	if a.Command == "" {
+		a.Command = client.CommandAirClose
+	}
+	if t.Duration != "" {
+		d, err := time.ParseDuration(t.Duration)
+		if err != nil {
+			return a, fmt.Errorf("定時動作的持續時間無效 %q: %w", t.Duration, err)
+		}
+		a.Duration = d
+	}
+	return a, nil
+}
+
+// persistedRule 結構體為狀態檔案中保存的一條定期任務
+type persistedRule struct {
+	ID       int    `json:"id"`
+	DeviceNo string `json:"deviceNo"`
+	Command  string `json:"command"`
+	Spec     string `json:"spec"`
+}
+
+// timerFile 結構體為狀態檔案的整體格式
+type timerFile struct {
+	Timers    []persistedTimer `json:"timers"`
+	Schedules []persistedRule  `json:"schedules,omitempty"`
+}
+
+// timerStore 結構體用於將待執行的定時器保存到磁碟，以便重啟後恢復
+// 多個設備及多個 actool 進程 (交互模式、daemon 等) 共用同一檔案，各自只替換自己的記錄，
+// 因此讀寫時除進程內的互斥鎖外，還須持有檔案鎖 (path + ".lock")
+// 每台設備的定時器只由認領 (Claim) 了該設備的一個進程執行及保存，以免過期的定時器被重複執行
+type timerStore struct {
+	path  string
+	mu    sync.Mutex
+	owned map[string]*os.File // 已認領的設備及其鎖檔案，進程退出時由系統釋放
+}
+
+// errLocked 表示檔案鎖已被其他進程持有
+var errLocked = errors.New("檔案已被其他進程鎖定")
+
+// lockFile 函數用於打開 (必要時以 0600 創建) 鎖檔案並取得排他鎖，關閉返回的檔案即釋放
+// wait 為 false 時不等待，鎖已被持有則返回 errLocked
+func lockFile(path string, wait bool) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("無法創建目錄 %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("無法打開鎖檔案 %s: %w", path, err)
+	}
+	if err := lockFileExclusive(f, wait); err != nil {
+		f.Close()
+		if errors.Is(err, errLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("無法鎖定檔案 %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Claim 方法用於認領設備的定時器，之後只有本進程會執行及保存該設備的定時器
+// 設備已被另一個仍在運行的進程認領時返回 errLocked；同一進程重複認領直接成功
+func (st *timerStore) Claim(deviceNo string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.owned[deviceNo]; ok {
+		return nil
+	}
+	// 設備號作為檔名的一部分，以十六進制編碼避免特殊字元
+	f, err := lockFile(fmt.Sprintf("%s.%x.lock", st.path, deviceNo), false)
+	if err != nil {
+		return err
+	}
+	if st.owned == nil {
+		st.owned = make(map[string]*os.File)
+	}
+	st.owned[deviceNo] = f
+	return nil
+}
+
+// Close 方法用於釋放已認領的所有設備
+func (st *timerStore) Close() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for deviceNo, f := range st.owned {
+		f.Close()
+		delete(st.owned, deviceNo)
+	}
+}
+
+// lockPath 方法用於在讀寫狀態檔案期間持有檔案鎖，調用前須持有 st.mu
+func (st *timerStore) lockPath(fn func() error) error {
+	f, err := lockFile(st.path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return fn()
+}
+
+// defaultTimerStorePath 函數用於返回狀態檔案的默認路徑
+// 優先使用 $XDG_STATE_HOME/actool/timers.json，否則為 ~/.local/state/actool/timers.json
+func defaultTimerStorePath() (string, error) {
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("無法確定用戶主目錄: %w", err)
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(stateHome, "actool", "timers.json"), nil
+}
+
+// load 方法用於讀取狀態檔案，檔案不存在時返回空內容
+func (st *timerStore) load() (*timerFile, error) {
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &timerFile{}, nil
+		}
+		return nil, fmt.Errorf("無法讀取定時器狀態檔案 %s: %w", st.path, err)
+	}
+	var file timerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析定時器狀態檔案 %s 失敗: %w", st.path, err)
+	}
+	return &file, nil
+}
+
+// Load 方法用於讀取指定設備已保存的定時器及定期任務
+func (st *timerStore) Load(deviceNo string) ([]persistedTimer, []persistedRule, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var file *timerFile
+	err := st.lockPath(func() (err error) {
+		file, err = st.load()
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var timers []persistedTimer
+	for _, t := range file.Timers {
+		if t.DeviceNo == deviceNo {
+			timers = append(timers, t)
+		}
+	}
+	var rules []persistedRule
+	for _, r := range file.Schedules {
+		if r.DeviceNo == deviceNo {
+			rules = append(rules, r)
+		}
+	}
+	return timers, rules, nil
+}
+
+// Save 方法用於以 actions 及 rules 替換指定設備已保存的定時動作及定期任務
+func (st *timerStore) Save(deviceNo string, actions []scheduledAction, rules []scheduleRule) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.lockPath(func() error {
+		return st.saveLocked(deviceNo, actions, rules)
+	})
+}
+
+// saveLocked 方法用於讀取狀態檔案並替換指定設備的記錄後寫回，調用前須持有 st.mu 及檔案鎖
+func (st *timerStore) saveLocked(deviceNo string, actions []scheduledAction, rules []scheduleRule) error {
+	file, err := st.load()
+	if err != nil {
+		return err
+	}
+	timers := make([]persistedTimer, 0, len(file.Timers))
+	for _, existing := range file.Timers {
+		if existing.DeviceNo != deviceNo {
+			timers = append(timers, existing)
+		}
+	}
+	for _, a := range actions {
+		saved := persistedTimer{
+			ID:          a.ID,
+			DeviceNo:    deviceNo,
+			Command:     a.Command,
+			EndTime:     a.At,
+			Description: a.Description,
+			Settings:    a.Settings,
+		}
+		if a.Duration > 0 {
+			saved.Duration = a.Duration.String()
+		}
+		timers = append(timers, saved)
+	}
+	file.Timers = timers
+
+	schedules := make([]persistedRule, 0, len(file.Schedules))
+	for _, existing := range file.Schedules {
+		if existing.DeviceNo != deviceNo {
+			schedules = append(schedules, existing)
+		}
+	}
+	for _, rule := range rules {
+		schedules = append(schedules, persistedRule{ID: rule.ID, DeviceNo: deviceNo, Command: rule.Command, Spec: rule.Spec})
+	}
+	file.Schedules = schedules
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化定時器狀態失敗: %w", err)
+	}
+	return writeFileAtomic(st.path, data)
+}
+
+// writeFileAtomic 函數用於先寫入臨時檔案 (權限 0600) 再重命名，避免寫入中斷時損壞原檔案
+// 寫入的檔案僅當前用戶可讀寫，亦用於保存配置、Token 等敏感內容；目錄不存在時以 0700 創建
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("無法創建目錄 %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("無法創建臨時檔案: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("寫入臨時檔案失敗: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("寫入臨時檔案失敗: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("無法保存檔案 %s: %w", path, err)
+	}
+	return nil
+}
+
+// overdueFireTimeout 為啟動時執行過期關閉動作的最長等待時間
+const overdueFireTimeout = 30 * time.Second
+
+// attachTimerStore 函數用於為調度器啟用持久化，並恢復上次保存的定時動作及定期任務
+// 已過期的關閉動作會立即同步執行，過期的開啟動作會被丟棄，未到期的則重新排程
+// 設備的定時器已由另一個進程 (例如 daemon) 管理時，不恢復也不保存，僅輸出警告
+func attachTimerStore(ctx context.Context, timers *scheduler, store *timerStore, deviceNo string) error {
+	if err := store.Claim(deviceNo); err != nil {
+		if errors.Is(err, errLocked) {
+			fmt.Fprintf(msgOut, "警告: 設備 %s 的定時器由另一個 actool 進程 (例如 daemon) 管理，本次設定的定時器不會被保存。\n", deviceNo)
+			return nil
+		}
+		return err
+	}
+	saved, savedRules, err := store.Load(deviceNo)
+	if err != nil {
+		return err
+	}
+	timers.persist = func(actions []scheduledAction, rules []scheduleRule) {
+		if err := store.Save(deviceNo, actions, rules); err != nil {
+			fmt.Fprintf(msgOut, "警告: 無法保存定時器狀態: %v\n", err)
+		}
+	}
+	for _, rule := range savedRules {
+		if err := timers.restoreRule(scheduleRule{ID: rule.ID, Command: rule.Command, Spec: rule.Spec}); err != nil {
+			fmt.Fprintf(msgOut, "警告: %v\n", err)
+		}
+	}
+
+	overdue := false
+	for _, t := range saved {
+		a, err := t.action()
+		if err != nil {
+			fmt.Fprintf(msgOut, "警告: %v\n", err)
+			continue
+		}
+		when := a.At.Local().Format("2006-01-02 15:04:05")
+		switch {
+		case time.Now().Before(a.At):
+			fmt.Fprintf(msgOut, "已恢復定時器 #%d (%s)，將在 %s %s。\n", a.ID, a.Description, when, commandLabel(a.Command))
+		case a.Command == client.CommandAirOpen:
+			// 錯過的開啟動作不再補執行，以免在無人時意外開啟空調
+			fmt.Fprintf(msgOut, "已丟棄過期的定時器 #%d (原定於 %s %s)。\n", a.ID, when, commandLabel(a.Command))
+			continue
+		default:
+			fmt.Fprintf(msgOut, "發現已過期的定時器 #%d (%s，原定於 %s %s)。\n", a.ID, a.Description, when, commandLabel(a.Command))
+			overdue = true
+		}
+		timers.Schedule(a)
+	}
+	if overdue {
+		// 限制等待時間，避免 hatch-api 無法連接時阻塞啟動，未完成的關閉動作由後台調度器繼續重試
+		fireCtx, cancel := context.WithTimeout(ctx, overdueFireTimeout)
+		defer cancel()
+		timers.FireDue(fireCtx)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"actool/client"
+)
+
+// TestTimerStoreSaveLoad 測試保存後重新讀取定時器，多個設備共用檔案時只替換各自的記錄
+func TestTimerStoreSaveLoad(t *testing.T) {
+	store := &timerStore{path: filepath.Join(t.TempDir(), "actool", "timers.json")}
+	if timers, rules, err := store.Load("D1"); err != nil || len(timers) != 0 || len(rules) != 0 {
+		t.Fatalf("檔案不存在時 Load = %v, %v, %v", timers, rules, err)
+	}
+
+	at := time.Date(2025, 7, 1, 23, 0, 0, 0, time.Local)
+	actions := []scheduledAction{
+		{ID: 1, Command: client.CommandAirClose, At: at, Description: "2小時"},
+		{ID: 2, Command: client.CommandAirOpen, At: at.Add(-time.Hour), Duration: 90 * time.Minute, Settings: []acSetting{{Kind: "temp", Value: "26"}}},
+	}
+	rules := []scheduleRule{{ID: 3, Command: client.CommandAirClose, Spec: "0 23 * * *"}}
+	if err := store.Save("D1", actions, rules); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Save("D2", actions[:1], nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Save("D1", actions, rules); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if info, err := os.Stat(store.path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("狀態檔案權限為 %v, %v，應為 0600", info.Mode().Perm(), err)
+	}
+
+	timers, savedRules, err := store.Load("D1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(timers) != 2 || len(savedRules) != 1 || savedRules[0].Spec != "0 23 * * *" {
+		t.Fatalf("讀取到 %+v, %+v", timers, savedRules)
+	}
+	for i, saved := range timers {
+		a, err := saved.action()
+		if err != nil {
+			t.Fatalf("action: %v", err)
+		}
+		want := actions[i]
+		if a.ID != want.ID || a.Command != want.Command || !a.At.Equal(want.At) || a.Duration != want.Duration ||
+			a.Description != want.Description || !slices.Equal(a.Settings, want.Settings) {
+			t.Errorf("定時器 #%d 讀取為 %+v，應為 %+v", want.ID, a, want)
+		}
+	}
+	if timers, _, _ := store.Load("D2"); len(timers) != 1 {
+		t.Errorf("D2 的定時器被覆蓋: %+v", timers)
+	}
+}
+
+// TestPersistedTimerLegacy 測試舊版本沒有 command 字段的記錄視為自動關閉，持續時間無效時返回錯誤
+func TestPersistedTimerLegacy(t *testing.T) {
+	a, err := persistedTimer{DeviceNo: "D1", EndTime: time.Now()}.action()
+	if err != nil || a.Command != client.CommandAirClose {
+		t.Errorf("舊版本記錄轉換為 %+v, %v", a, err)
+	}
+	if _, err := (persistedTimer{Command: client.CommandAirOpen, Duration: "兩小時"}).action(); err == nil {
+		t.Error("無效的持續時間未返回錯誤")
+	}
+}
+
+// TestAttachTimerStore 測試重啟後恢復定時器：未到期的重新排程，過期的關閉動作立即執行，過期的開啟動作被丟棄，之後的修改會被保存
+func TestAttachTimerStore(t *testing.T) {
+	store := &timerStore{path: filepath.Join(t.TempDir(), "timers.json")}
+	t.Cleanup(store.Close)
+	now := time.Now()
+	err := store.Save("D1", []scheduledAction{
+		{ID: 1, Command: client.CommandAirClose, At: now.Add(-time.Minute)},
+		{ID: 2, Command: client.CommandAirOpen, At: now.Add(-time.Minute)},
+		{ID: 3, Command: client.CommandAirOpen, At: now.Add(time.Hour), Duration: time.Hour},
+	}, []scheduleRule{{ID: 4, Command: client.CommandAirClose, Spec: "0 23 * * *"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fired []int
+	timers := newScheduler(func(ctx context.Context, a scheduledAction) error {
+		fired = append(fired, a.ID)
+		return nil
+	}, nil)
+	if err := attachTimerStore(context.Background(), timers, store, "D1"); err != nil {
+		t.Fatalf("attachTimerStore: %v", err)
+	}
+	if !slices.Equal(fired, []int{1}) {
+		t.Errorf("啟動時執行了 %v，應只執行過期的關閉動作 #1", fired)
+	}
+	actions := timers.Actions()
+	if len(actions) != 1 || actions[0].ID != 3 || actions[0].Duration != time.Hour {
+		t.Errorf("恢復後的定時動作為 %+v", actions)
+	}
+	if rules := timers.Rules(); len(rules) != 1 || rules[0].ID != 4 || rules[0].Next.IsZero() {
+		t.Errorf("恢復後的定期任務為 %+v", rules)
+	}
+
+	// 新的定時器編號不與已恢復的重複，且修改後立即保存
+	added := timers.Schedule(scheduledAction{Command: client.CommandAirClose, At: now.Add(2 * time.Hour)})
+	if added.ID <= 3 {
+		t.Errorf("新定時器的編號為 %d，與已恢復的編號重複", added.ID)
+	}
+	saved, _, err := store.Load("D1")
+	if err != nil || len(saved) != 2 {
+		t.Errorf("保存的定時器為 %+v, %v", saved, err)
+	}
+}
+
+// TestTimerStoreTwoProcesses 測試兩個進程 (以兩個 timerStore 模擬) 共用同一狀態檔案：
+// 設備的定時器只由先認領的一方執行及保存，另一方不重複執行過期的定時器，且並發保存不會覆蓋彼此的記錄
+func TestTimerStoreTwoProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.json")
+	first, second := &timerStore{path: path}, &timerStore{path: path}
+	t.Cleanup(first.Close)
+	t.Cleanup(second.Close)
+	overdue := scheduledAction{ID: 1, Command: client.CommandAirClose, At: time.Now().Add(-time.Minute)}
+	if err := first.Save("D1", []scheduledAction{overdue}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var fired []string
+	attach := func(store *timerStore, name string) *scheduler {
+		timers := newScheduler(func(ctx context.Context, a scheduledAction) error {
+			fired = append(fired, name)
+			return nil
+		}, nil)
+		if err := attachTimerStore(context.Background(), timers, store, "D1"); err != nil {
+			t.Fatalf("attachTimerStore: %v", err)
+		}
+		return timers
+	}
+	attach(first, "first")
+	timers := attach(second, "second")
+	if !slices.Equal(fired, []string{"first"}) {
+		t.Errorf("過期的定時器由 %v 執行，應只由先認領的一方執行一次", fired)
+	}
+	if err := second.Claim("D1"); err != errLocked {
+		t.Errorf("重複認領返回 %v，應為 errLocked", err)
+	}
+
+	// 未認領的一方修改定時器不會覆蓋已保存的記錄
+	if err := first.Save("D1", []scheduledAction{{ID: 5, Command: client.CommandAirClose, At: time.Now().Add(time.Hour)}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	timers.Schedule(scheduledAction{Command: client.CommandAirOpen, At: time.Now().Add(time.Hour)})
+	if saved, _, _ := first.Load("D1"); len(saved) != 1 || saved[0].ID != 5 {
+		t.Errorf("保存的定時器為 %+v，被未認領的一方覆蓋", saved)
+	}
+
+	// 並發保存不同設備時不丟失記錄
+	var wg sync.WaitGroup
+	for i := range 20 {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deviceNo := fmt.Sprintf("E%d", i)
+			if err := store.Save(deviceNo, []scheduledAction{{ID: 1, Command: client.CommandAirClose, At: time.Now()}}, nil); err != nil {
+				t.Errorf("Save(%s): %v", deviceNo, err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range 20 {
+		if saved, _, _ := first.Load(fmt.Sprintf("E%d", i)); len(saved) != 1 {
+			t.Errorf("設備 E%d 的記錄丟失", i)
+		}
+	}
+
+	// 先認領的一方釋放後，另一方可以認領
+	first.Close()
+	if err := second.Claim("D1"); err != nil {
+		t.Errorf("釋放後認領返回 %v", err)
+	}
+}